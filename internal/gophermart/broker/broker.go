@@ -0,0 +1,64 @@
+package broker
+
+import (
+	"sync"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
+)
+
+const subscriberBuffer int = 64
+
+// Broker fans order events out to the subscribers of the event's user.
+// A subscriber that does not keep up is dropped: its channel is closed and
+// the client is expected to reconnect with Last-Event-ID.
+type Broker struct {
+	subs map[string]map[chan models.OrderEvent]struct{}
+	mu   sync.Mutex
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subs: make(map[string]map[chan models.OrderEvent]struct{}),
+	}
+}
+
+func (b *Broker) Subscribe(userid string) (<-chan models.OrderEvent, func()) {
+	ch := make(chan models.OrderEvent, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.subs[userid] == nil {
+		b.subs[userid] = make(map[chan models.OrderEvent]struct{})
+	}
+	b.subs[userid][ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.remove(userid, ch)
+	}
+}
+
+func (b *Broker) Publish(e models.OrderEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs[e.UserID] {
+		select {
+		case ch <- e:
+		default:
+			b.remove(e.UserID, ch)
+		}
+	}
+}
+
+func (b *Broker) remove(userid string, ch chan models.OrderEvent) {
+	if _, ok := b.subs[userid][ch]; !ok {
+		return
+	}
+	delete(b.subs[userid], ch)
+	if len(b.subs[userid]) == 0 {
+		delete(b.subs, userid)
+	}
+	close(ch)
+}
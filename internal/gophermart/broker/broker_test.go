@@ -0,0 +1,44 @@
+package broker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
+)
+
+func TestBroker(t *testing.T) {
+	b := NewBroker()
+
+	ch1, unsubscribe1 := b.Subscribe("user01")
+	ch2, unsubscribe2 := b.Subscribe("user02")
+	defer unsubscribe2()
+
+	b.Publish(models.OrderEvent{ID: 1, UserID: "user01", Number: "2377225624"})
+
+	assert.Equal(t, int64(1), (<-ch1).ID)
+	assert.Empty(t, ch2, "events must only reach the order owner")
+
+	unsubscribe1()
+	_, ok := <-ch1
+	assert.False(t, ok, "channel must be closed after unsubscribe")
+	unsubscribe1()
+}
+
+func TestBrokerSlowSubscriber(t *testing.T) {
+	b := NewBroker()
+
+	ch, unsubscribe := b.Subscribe("user01")
+	defer unsubscribe()
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		b.Publish(models.OrderEvent{ID: int64(i), UserID: "user01"})
+	}
+
+	received := 0
+	for range ch {
+		received++
+	}
+	assert.Equal(t, subscriberBuffer, received, "slow subscriber must be dropped")
+}
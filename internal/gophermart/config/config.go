@@ -4,14 +4,16 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 
+	"github.com/vkupriya/go-gophermart/internal/gophermart/logging"
+	"github.com/vkupriya/go-gophermart/internal/gophermart/metrics"
 	models "github.com/vkupriya/go-gophermart/internal/gophermart/models"
 )
 
@@ -26,156 +28,234 @@ const (
 	defaultAccrualInterval       time.Duration = 10 * time.Second
 	defaultAccrualWorkers        int64         = 3
 	defaultTimeoutServerShutdown time.Duration = 5 * time.Second
-	defaultTimeoutShutdown       time.Duration = 10 * time.Second
+	defaultTimeoutShutdown       time.Duration = 15 * time.Second
+	defaultDrainDelay            time.Duration = 5 * time.Second
 	defaultAccrualWorkerRetry    time.Duration = 15 * time.Second
+	defaultOrdersBatchSize       int64         = 1000
+	defaultOrdersBatchMaxBytes   int64         = 1 << 20
+	defaultOrderMaxLength        int64         = 200
+	defaultIdempotencyTTL        time.Duration = 24 * time.Hour
+	defaultIdempotencyCleanup    time.Duration = 1 * time.Hour
+	defaultIdempotencyLease      time.Duration = 1 * time.Minute
+	defaultWebhookInterval       time.Duration = 5 * time.Second
+	defaultWebhookTimeout        time.Duration = 10 * time.Second
+	defaultWebhookBackoff        time.Duration = 30 * time.Second
+	defaultWebhookBackoffMax     time.Duration = 1 * time.Hour
+	defaultWebhookMaxAttempts    int64         = 10
+	defaultWebhookBatchSize      int64         = 100
+	defaultExpiryInterval        time.Duration = 1 * time.Hour
+	defaultTierInterval          time.Duration = 24 * time.Hour
+	defaultRiskRetryAfter        time.Duration = 1 * time.Minute
+	defaultHealthTimeout         time.Duration = 1 * time.Second
+	defaultReadHeaderTimeout     time.Duration = 5 * time.Second
+	defaultReadTimeout           time.Duration = 30 * time.Second
+	defaultWriteTimeout          time.Duration = 30 * time.Second
+	defaultIdleTimeout           time.Duration = 120 * time.Second
+	defaultMaxHeaderBytes        int64         = 1 << 20
+	defaultTracingExporter       string        = "none"
+	defaultLogLevel              string        = "debug"
+	defaultTracingSampleRatio    float64       = 1
 )
 
+// defaultTiers are used when the config file has no tiers section.
+var defaultTiers = []models.Tier{
+	{Name: "silver", Threshold: 1000, Multiplier: 1.1},
+	{Name: "gold", Threshold: 5000, Multiplier: 1.25},
+	{Name: "platinum", Threshold: 20000, Multiplier: 1.5},
+}
+
+// NewConfig loads the configuration of the service from the command line,
+// the environment and the config file.
 func NewConfig() (*models.Config, error) {
-	logConfig := zap.NewDevelopmentConfig()
-	logger, err := logConfig.Build()
+	c, err := Load(os.Args[1:], os.LookupEnv)
 	if err != nil {
-		return &models.Config{}, fmt.Errorf("failed to initialize Logger: %w", err)
+		return nil, err
 	}
 
-	// Opening config file if present
-	viper.AddConfigPath("./")
-	viper.AddConfigPath("$HOME/")
-	viper.SetConfigName(".env")
-	viper.SetConfigType("yaml")
-	err = viper.ReadInConfig()
+	level, err := zap.ParseAtomicLevel(c.LogLevel)
 	if err != nil {
-		fmt.Println("Error:", err)
-		// if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-		// 	logger.Sugar().Infof("configuration .env file not found")
-		// } else {
-		// 	logger.Sugar().Info("Failed to open config file", zap.Error(err))
-		// }
-	}
-
-	vJWTKey := viper.GetString("server.JWTKey")
-	vJWTTokenTTL := viper.GetInt64("server.JWTTokenTTL")
-	vAddress := viper.GetString("server.Address")
-	vTimeoutServerShutdown := viper.GetInt64("server.TimeoutServerShutdown")
-	vTimeoutShutdown := viper.GetInt64("server.TimeoutShutdown")
-	vAccrualAddress := viper.GetString("accrual.Address")
-	vHTTPTimeout := viper.GetInt64("accrual.HTTPTimeout")
-	vInterval := viper.GetInt64("accrual.Interval")
-	vWorkers := viper.GetInt64("accrual.Workers")
-	vWorkerRetry := viper.GetInt64("accrual.WorkerRetry")
-
-	a := flag.String("a", defaultAddress, "Gophermart server host address and port.")
-	r := flag.String("r", defaultAccrualURL, "Accrual server address and port")
-	w := flag.Int64("w", defaultAccrualWorkers, "Number of Accrual processing workers")
-	d := flag.String("d", "", "PostgreSQL DSN")
-
-	flag.Parse()
-
-	if *a == defaultAddress {
-		if envAddr, ok := os.LookupEnv("RUN_ADDRESS"); ok {
-			a = &envAddr
-		} else if vAddress != "" {
-			a = &vAddress
-		}
+		return nil, fmt.Errorf("failed to parse log level: %w", err)
 	}
-
-	if *r == defaultAccrualURL {
-		if envAccrualAddr, ok := os.LookupEnv("ACCRUAL_SYSTEM_ADDRESS"); ok {
-			r = &envAccrualAddr
-		} else if vAccrualAddress != "" {
-			r = &vAccrualAddress
-		}
+	logger, err := logging.New(c.LogFormat, level)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize Logger: %w", err)
 	}
+	c.Logger = logger
+	c.AtomicLevel = level
+	c.Metrics = metrics.New()
+	return c, nil
+}
 
-	if *w == defaultAccrualWorkers {
-		if envAccrualWorkers, ok := os.LookupEnv("ACCRUAL_WORKERS"); ok {
-			envAccrualWorkers, err := strconv.ParseInt(envAccrualWorkers, 10, 64)
-			if err != nil {
-				return nil, errors.New("failed to convert env var ACCRUAL_WORKERS to integer")
-			}
-			w = &envAccrualWorkers
-		} else if vWorkers != 0 {
-			*w = vWorkers
+// Load builds the configuration from, in increasing order of precedence,
+// the defaults, the config file, env variables and command line flags. The
+// config file is given by -config or found as .env in ./ or $HOME. All
+// invalid settings are reported together.
+func Load(args []string, lookupEnv func(string) (string, bool)) (*models.Config, error) {
+	c := defaults()
+	settings := schema(c)
+
+	fs := flag.NewFlagSet("gophermart", flag.ContinueOnError)
+	configFile := fs.String("config", "", "Path of the YAML config file, ./.env or $HOME/.env if empty.")
+	printConfig := fs.Bool("print-config", false, "Print the effective configuration and exit.")
+	flags := make(map[string]*string)
+	for _, s := range settings {
+		if s.flag != "" {
+			flags[s.flag] = fs.String(s.flag, s.value.String(), s.usage)
 		}
 	}
-
-	if !strings.Contains(*r, "://") {
-		*r = "http://" + *r
+	if err := fs.Parse(args); err != nil {
+		return nil, fmt.Errorf("failed to parse flags: %w", err)
 	}
+	// only flags given on the command line override other layers, even if
+	// equal to the default
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
 
-	if *d == "" {
-		if envDSN, ok := os.LookupEnv("DATABASE_URI"); ok {
-			d = &envDSN
-		} else {
-			return &models.Config{}, errors.New("postgreSQL DSN is missing")
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if *configFile != "" {
+		v.SetConfigFile(*configFile)
+	} else {
+		v.SetConfigName(".env")
+		v.AddConfigPath("./")
+		v.AddConfigPath("$HOME/")
+	}
+	if err := v.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if *configFile != "" || !errors.As(err, &notFound) {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
 		}
 	}
+	c.ConfigFile = v.ConfigFileUsed()
 
-	var JWTKey string
-	if envJWT, ok := os.LookupEnv("JWT"); ok {
-		JWTKey = envJWT
-	} else {
-		if vJWTKey != "" {
-			JWTKey = vJWTKey
-		} else {
-			JWTKey = defaultJWTKey
+	var errs []error
+	for _, s := range settings {
+		if v.IsSet(s.key) {
+			if err := s.value.SetFile(v, s.key); err != nil {
+				errs = append(errs, fmt.Errorf("%s in %s: %w", s.key, c.ConfigFile, err))
+			}
+		}
+		if s.env != "" {
+			if env, ok := lookupEnv(s.env); ok {
+				if err := s.value.Set(env); err != nil {
+					errs = append(errs, fmt.Errorf("env %s: %w", s.env, err))
+				}
+			}
+		}
+		if given[s.flag] {
+			if err := s.value.Set(*flags[s.flag]); err != nil {
+				errs = append(errs, fmt.Errorf("flag -%s: %w", s.flag, err))
+			}
 		}
 	}
 
-	var JWTTokenTTL time.Duration
-	if vJWTTokenTTL != 0 {
-		JWTTokenTTL = time.Duration(vJWTTokenTTL) * time.Second
-	} else {
-		JWTTokenTTL = defaultJWTTokenTTL
+	if c.AccrualAddress != "" && !strings.Contains(c.AccrualAddress, "://") {
+		c.AccrualAddress = "http://" + c.AccrualAddress
 	}
+	c.PrintConfig = *printConfig
 
-	var TimeoutServerShutdown time.Duration
-	if vTimeoutServerShutdown != 0 {
-		TimeoutServerShutdown = time.Duration(vTimeoutServerShutdown) * time.Second
-	} else {
-		TimeoutServerShutdown = defaultTimeoutServerShutdown
+	errs = append(errs, validate(c, settings)...)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
 	}
+	return c, nil
+}
 
-	var TimeoutShutdown time.Duration
-	if vTimeoutShutdown != 0 {
-		TimeoutShutdown = time.Duration(vTimeoutShutdown) * time.Second
-	} else {
-		TimeoutShutdown = defaultTimeoutShutdown
+func defaults() *models.Config {
+	return &models.Config{
+		Address:               defaultAddress,
+		LogFormat:             logging.FormatConsole,
+		LogLevel:              defaultLogLevel,
+		ContextTimeout:        defaultContextTimeout,
+		JWTKey:                defaultJWTKey,
+		JWTTokenTTL:           defaultJWTTokenTTL,
+		AccrualAddress:        defaultAccrualURL,
+		AccrualHTTPTimeout:    defaultAccrualHTTPTimeout,
+		AccrualRetryAfter:     defaultAccrualRetryAfter,
+		AccrualInterval:       defaultAccrualInterval,
+		AccrualWorkers:        defaultAccrualWorkers,
+		TimeoutServerShutdown: defaultTimeoutServerShutdown,
+		TimeoutShutdown:       defaultTimeoutShutdown,
+		DrainDelay:            defaultDrainDelay,
+		AccrualWorkerRetry:    defaultAccrualWorkerRetry,
+		OrdersBatchSize:       defaultOrdersBatchSize,
+		OrdersBatchMaxBytes:   defaultOrdersBatchMaxBytes,
+		OrderMaxLength:        defaultOrderMaxLength,
+		IdempotencyTTL:        defaultIdempotencyTTL,
+		IdempotencyCleanup:    defaultIdempotencyCleanup,
+		IdempotencyLease:      defaultIdempotencyLease,
+		WebhookInterval:       defaultWebhookInterval,
+		WebhookTimeout:        defaultWebhookTimeout,
+		WebhookBackoff:        defaultWebhookBackoff,
+		WebhookBackoffMax:     defaultWebhookBackoffMax,
+		WebhookMaxAttempts:    defaultWebhookMaxAttempts,
+		WebhookBatchSize:      defaultWebhookBatchSize,
+		ExpiryInterval:        defaultExpiryInterval,
+		Tiers:                 defaultTiers,
+		TierInterval:          defaultTierInterval,
+		RiskRetryAfter:        defaultRiskRetryAfter,
+		HealthTimeout:         defaultHealthTimeout,
+		ReadHeaderTimeout:     defaultReadHeaderTimeout,
+		ReadTimeout:           defaultReadTimeout,
+		WriteTimeout:          defaultWriteTimeout,
+		IdleTimeout:           defaultIdleTimeout,
+		MaxHeaderBytes:        defaultMaxHeaderBytes,
+		TracingExporter:       defaultTracingExporter,
+		TracingSampleRatio:    defaultTracingSampleRatio,
 	}
+}
 
-	var AccrualHTTPTimeout time.Duration
-	if vHTTPTimeout != 0 {
-		AccrualHTTPTimeout = time.Duration(vHTTPTimeout) * time.Second
-	} else {
-		AccrualHTTPTimeout = defaultAccrualHTTPTimeout
+// validate checks every setting and the rules spanning several of them.
+func validate(c *models.Config, settings []setting) []error {
+	var errs []error
+	for _, s := range settings {
+		if s.check == nil {
+			continue
+		}
+		if err := s.check(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.key, err))
+		}
 	}
-
-	var AccrualInterval time.Duration
-	if vInterval != 0 {
-		AccrualInterval = time.Duration(vInterval) * time.Second
-	} else {
-		AccrualInterval = defaultAccrualInterval
+	if c.WithdrawalMin > 0 && c.WithdrawalMax > 0 && c.WithdrawalMin > c.WithdrawalMax {
+		errs = append(errs, fmt.Errorf("withdrawals.Min: %v exceeds withdrawals.Max %v",
+			c.WithdrawalMin, c.WithdrawalMax))
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, errors.New("server.TLSCertFile and server.TLSKeyFile must be set together"))
+	}
+	if c.TLSClientCAFile != "" && c.TLSCertFile == "" {
+		errs = append(errs, errors.New("server.TLSClientCAFile: requires server.TLSCertFile and server.TLSKeyFile"))
 	}
+	if c.WebhookBackoff > c.WebhookBackoffMax {
+		errs = append(errs, fmt.Errorf("webhooks.Backoff: %s exceeds webhooks.BackoffMax %s",
+			c.WebhookBackoff, c.WebhookBackoffMax))
+	}
+	return errs
+}
 
-	var AccrualWorkerRetry time.Duration
-	if vWorkerRetry != 0 {
-		AccrualWorkerRetry = time.Duration(vWorkerRetry) * time.Second
-	} else {
-		AccrualWorkerRetry = defaultAccrualWorkerRetry
+// Print writes the effective configuration in the config file syntax, with
+// secrets redacted.
+func Print(w io.Writer, c *models.Config) error {
+	var b strings.Builder
+	if c.ConfigFile != "" {
+		fmt.Fprintf(&b, "# loaded from %s\n", c.ConfigFile)
 	}
-	return &models.Config{
-		Address:               *a,
-		Logger:                logger,
-		PostgresDSN:           *d,
-		ContextTimeout:        defaultContextTimeout,
-		JWTKey:                JWTKey,
-		JWTTokenTTL:           JWTTokenTTL,
-		AccrualAddress:        *r,
-		AccrualHTTPTimeout:    AccrualHTTPTimeout,
-		AccrualRetryAfter:     defaultAccrualRetryAfter,
-		AccrualInterval:       AccrualInterval,
-		AccrualWorkers:        *w,
-		TimeoutServerShutdown: TimeoutServerShutdown,
-		TimeoutShutdown:       TimeoutShutdown,
-		AccrualWorkerRetry:    AccrualWorkerRetry,
-	}, nil
+	section := ""
+	for _, s := range schema(c) {
+		name, key, nested := strings.Cut(s.key, ".")
+		if !nested {
+			fmt.Fprintf(&b, "\n%s: %s\n", name, s.value)
+			section = ""
+			continue
+		}
+		if name != section {
+			fmt.Fprintf(&b, "\n%s:\n", name)
+			section = name
+		}
+		fmt.Fprintf(&b, "  %s: %s\n", key, s.display())
+	}
+	if _, err := io.WriteString(w, strings.TrimPrefix(b.String(), "\n")); err != nil {
+		return fmt.Errorf("failed to print config: %w", err)
+	}
+	return nil
 }
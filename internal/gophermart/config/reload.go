@@ -0,0 +1,185 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
+)
+
+// Editors and config management tools often write a file in several steps,
+// the reload waits for the file to settle.
+const reloadDebounce time.Duration = 500 * time.Millisecond
+
+// Change is a setting whose value differs between the running configuration
+// and the reloaded one. Secrets are redacted in Old and New.
+type Change struct {
+	Key     string
+	Old     string
+	New     string
+	Applied bool
+}
+
+// Reloader re-reads the configuration from the same layers as Load. Changes
+// of reloadable settings are applied to the running service, changes of other
+// settings are logged and only take effect after a restart.
+type Reloader struct {
+	current   *models.Config
+	lookupEnv func(string) (string, bool)
+	args      []string
+	apply     []func(*models.Config)
+	mu        sync.Mutex
+}
+
+// NewReloader returns a Reloader for the configuration c loaded from args and
+// lookupEnv. The apply funcs receive the new configuration after each reload
+// that changed a reloadable setting.
+func NewReloader(
+	args []string,
+	lookupEnv func(string) (string, bool),
+	c *models.Config,
+	apply ...func(*models.Config),
+) *Reloader {
+	return &Reloader{
+		current:   c,
+		lookupEnv: lookupEnv,
+		args:      args,
+		apply:     apply,
+	}
+}
+
+// Reload loads the configuration again and applies the reloadable changes.
+// An invalid configuration is rejected as a whole and the running one kept.
+func (r *Reloader) Reload() ([]Change, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := Load(r.args, r.lookupEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := *r.current
+	current, loaded, target := schema(r.current), schema(next), schema(&merged)
+	var changes []Change
+	for i, s := range current {
+		if s.value.String() == loaded[i].value.String() {
+			continue
+		}
+		changes = append(changes, Change{
+			Key:     s.key,
+			Old:     s.display(),
+			New:     loaded[i].display(),
+			Applied: s.reloadable,
+		})
+		if s.reloadable {
+			target[i].value.copyFrom(loaded[i].value)
+		}
+	}
+
+	applied := false
+	for _, ch := range changes {
+		applied = applied || ch.Applied
+	}
+	if !applied {
+		return changes, nil
+	}
+
+	if merged.LogLevel != r.current.LogLevel {
+		if err := merged.AtomicLevel.UnmarshalText([]byte(merged.LogLevel)); err != nil {
+			return nil, fmt.Errorf("failed to set log level: %w", err)
+		}
+	}
+	r.current = &merged
+	for _, apply := range r.apply {
+		apply(&merged)
+	}
+	return changes, nil
+}
+
+// Run reloads the configuration on SIGHUP and whenever the config file
+// changes, until ctx is done.
+func (r *Reloader) Run(ctx context.Context) error {
+	logger := r.current.Logger
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var (
+		events <-chan fsnotify.Event
+		errs   <-chan error
+		file   string
+	)
+	if r.current.ConfigFile != "" {
+		var err error
+		file, err = filepath.Abs(r.current.ConfigFile)
+		if err != nil {
+			return fmt.Errorf("failed to resolve config file path: %w", err)
+		}
+		w, err := fsnotify.NewWatcher()
+		if err != nil {
+			return fmt.Errorf("failed to create config file watcher: %w", err)
+		}
+		defer func() {
+			if err := w.Close(); err != nil {
+				logger.Sugar().Errorw("failed to close config file watcher", zap.Error(err))
+			}
+		}()
+		// watch the directory, as files replaced by a rename drop their own watch
+		if err := w.Add(filepath.Dir(file)); err != nil {
+			return fmt.Errorf("failed to watch config file: %w", err)
+		}
+		events, errs = w.Events, w.Errors
+	}
+
+	debounce := time.NewTimer(reloadDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hup:
+			r.reloadAndLog("SIGHUP")
+		case ev := <-events:
+			if filepath.Clean(ev.Name) == file && !ev.Has(fsnotify.Chmod) {
+				debounce.Reset(reloadDebounce)
+			}
+		case err := <-errs:
+			logger.Sugar().Warnw("config file watcher error", zap.Error(err))
+		case <-debounce.C:
+			r.reloadAndLog("config file change")
+		}
+	}
+}
+
+func (r *Reloader) reloadAndLog(trigger string) {
+	logger := r.current.Logger
+
+	changes, err := r.Reload()
+	if err != nil {
+		logger.Error("failed to reload configuration, keeping the running one",
+			zap.String("trigger", trigger), zap.Error(err))
+		return
+	}
+	for _, ch := range changes {
+		fields := []zap.Field{zap.String("key", ch.Key), zap.String("old", ch.Old), zap.String("new", ch.New)}
+		if ch.Applied {
+			logger.Info("setting reloaded", fields...)
+		} else {
+			logger.Warn("setting cannot be reloaded, restart to apply", fields...)
+		}
+	}
+	logger.Info("configuration reloaded", zap.String("trigger", trigger), zap.Int("changes", len(changes)))
+}
@@ -0,0 +1,517 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/logging"
+	models "github.com/vkupriya/go-gophermart/internal/gophermart/models"
+	"github.com/vkupriya/go-gophermart/internal/gophermart/tracing"
+)
+
+// setting is a configuration value that can be set in the config file under
+// key, by the env variable env and by the command line flag flag, in this
+// order of precedence. Empty env or flag means the layer does not apply.
+// Reloadable settings are applied on reload, changes of other settings
+// need a restart.
+type setting struct {
+	value      value
+	check      func() error
+	redact     func(string) string
+	key        string
+	env        string
+	flag       string
+	usage      string
+	reloadable bool
+}
+
+// display returns the value in the config file syntax with secrets redacted.
+func (s setting) display() string {
+	val := s.value.String()
+	if s.redact != nil {
+		raw, _ := strconv.Unquote(val)
+		val = strconv.Quote(s.redact(raw))
+	}
+	return val
+}
+
+// schema binds every setting to its field of c.
+func schema(c *models.Config) []setting {
+	return []setting{
+		{key: "server.Address", env: "RUN_ADDRESS", flag: "a", value: stringVar(&c.Address),
+			usage: "Gophermart server host address and port.", check: required(&c.Address)},
+		{key: "server.GRPCAddress", env: "GRPC_ADDRESS", flag: "g", value: stringVar(&c.GRPCAddress),
+			usage: "Gophermart gRPC server host address and port, disabled if empty."},
+		{key: "server.MetricsAddress", env: "METRICS_ADDRESS", flag: "m", value: stringVar(&c.MetricsAddress),
+			usage: "Metrics listener host address and port, disabled if empty unless MetricsPublic is set."},
+		{key: "server.MetricsPublic", env: "METRICS_PUBLIC", value: boolVar(&c.MetricsPublic),
+			usage: "Serve /metrics on the main address when MetricsAddress is empty."},
+		{key: "server.TLSCertFile", env: "TLS_CERT_FILE", value: stringVar(&c.TLSCertFile),
+			usage: "PEM certificate chain of the server, TLS is disabled if empty. Reloaded on change."},
+		{key: "server.TLSKeyFile", env: "TLS_KEY_FILE", value: stringVar(&c.TLSKeyFile),
+			usage: "PEM private key of the server certificate. Reloaded on change."},
+		{key: "server.TLSClientCAFile", env: "TLS_CLIENT_CA_FILE", value: stringVar(&c.TLSClientCAFile),
+			usage: "PEM CA bundle verifying client certificates, required by admin routes if set."},
+		{key: "server.ReadHeaderTimeout", env: "READ_HEADER_TIMEOUT", value: durationVar(&c.ReadHeaderTimeout),
+			usage: "Time to read request headers, 0 disables the limit.", check: nonNegative(&c.ReadHeaderTimeout)},
+		{key: "server.ReadTimeout", env: "READ_TIMEOUT", value: durationVar(&c.ReadTimeout),
+			usage: "Time to read a whole request, 0 disables the limit.", check: nonNegative(&c.ReadTimeout)},
+		{key: "server.WriteTimeout", env: "WRITE_TIMEOUT", value: durationVar(&c.WriteTimeout),
+			usage: "Time to write a response, 0 disables the limit. Not applied to event streams.",
+			check: nonNegative(&c.WriteTimeout)},
+		{key: "server.IdleTimeout", env: "IDLE_TIMEOUT", value: durationVar(&c.IdleTimeout),
+			usage: "Time a keep-alive connection waits for the next request, 0 uses ReadTimeout.",
+			check: nonNegative(&c.IdleTimeout)},
+		{key: "server.MaxHeaderBytes", env: "MAX_HEADER_BYTES", value: int64Var(&c.MaxHeaderBytes),
+			usage: "Maximum size of request headers.", check: positive(&c.MaxHeaderBytes)},
+		{key: "server.JWTKey", env: "JWT", value: stringVar(&c.JWTKey),
+			usage: "Key signing the JWT tokens.", check: required(&c.JWTKey), redact: redactAll},
+		{key: "server.JWTTokenTTL", env: "JWT_TOKEN_TTL", value: durationVar(&c.JWTTokenTTL),
+			usage: "Lifetime of issued JWT tokens.", check: positive(&c.JWTTokenTTL)},
+		{key: "server.ContextTimeout", env: "CONTEXT_TIMEOUT", value: durationVar(&c.ContextTimeout),
+			usage: "Timeout of every DB operation.", check: positive(&c.ContextTimeout)},
+		{key: "server.TimeoutServerShutdown", env: "TIMEOUT_SERVER_SHUTDOWN",
+			value: durationVar(&c.TimeoutServerShutdown),
+			usage: "Time the servers get to finish in-flight requests on shutdown.",
+			check: positive(&c.TimeoutServerShutdown)},
+		{key: "server.TimeoutShutdown", env: "TIMEOUT_SHUTDOWN", value: durationVar(&c.TimeoutShutdown),
+			usage: "Time the service gets to shut down gracefully, DrainDelay included.",
+			check: positive(&c.TimeoutShutdown)},
+		{key: "server.DrainDelay", env: "DRAIN_DELAY", value: durationVar(&c.DrainDelay),
+			usage: "Time readiness fails before the servers shut down, at least one readiness probe period.",
+			check: nonNegative(&c.DrainDelay)},
+		{key: "server.OrdersBatchSize", env: "ORDERS_BATCH_SIZE", value: int64Var(&c.OrdersBatchSize),
+			usage: "Maximum number of orders in a batch upload.", check: positive(&c.OrdersBatchSize)},
+		{key: "server.OrdersBatchMaxBytes", env: "ORDERS_BATCH_MAX_BYTES", value: int64Var(&c.OrdersBatchMaxBytes),
+			usage: "Maximum size of a batch upload body.", check: positive(&c.OrdersBatchMaxBytes)},
+		{key: "server.OrderMaxLength", env: "ORDER_MAX_LENGTH", value: int64Var(&c.OrderMaxLength),
+			usage: "Maximum number of digits of an order number.", check: positive(&c.OrderMaxLength)},
+		{key: "server.IdempotencyTTL", env: "IDEMPOTENCY_TTL", value: durationVar(&c.IdempotencyTTL),
+			usage: "Time idempotent responses are replayed.", check: positive(&c.IdempotencyTTL)},
+		{key: "server.IdempotencyLease", env: "IDEMPOTENCY_LEASE", value: durationVar(&c.IdempotencyLease),
+			usage: "Time after which a request still in progress is treated as abandoned.",
+			check: positive(&c.IdempotencyLease)},
+		{key: "server.IdempotencyCleanup", env: "IDEMPOTENCY_CLEANUP", value: durationVar(&c.IdempotencyCleanup),
+			usage: "Interval between purges of expired idempotency keys.", check: positive(&c.IdempotencyCleanup)},
+		{key: "server.AdminUsers", env: "ADMIN_USERS", value: listVar(&c.AdminUsers),
+			usage: "Logins of registered users granted the admin role on start, comma separated. Others lose it."},
+		{key: "server.AdjustmentApproval", env: "ADJUSTMENT_APPROVAL", value: float32Var(&c.AdjustmentApproval),
+			usage: "Adjustments above this amount need a second admin, 0 disables approval.",
+			check: nonNegative(&c.AdjustmentApproval)},
+		{key: "server.PointsExpiryMonths", env: "POINTS_EXPIRY_MONTHS", value: int64Var(&c.PointsExpiryMonths),
+			usage: "Accrued points expire after this many months, 0 disables expiry.",
+			check: nonNegative(&c.PointsExpiryMonths)},
+		{key: "server.ExpiryInterval", env: "EXPIRY_INTERVAL", value: durationVar(&c.ExpiryInterval),
+			usage: "Interval between expiry runs.", check: positive(&c.ExpiryInterval)},
+		{key: "server.TierInterval", env: "TIER_INTERVAL", value: durationVar(&c.TierInterval),
+			usage: "Interval between tier recalculations, counted from the last run recorded in the DB.",
+			check: positive(&c.TierInterval)},
+		{key: "server.ReferrerBonus", env: "REFERRER_BONUS", value: float32Var(&c.ReferrerBonus),
+			usage: "Points for the referrer when the referee's first order is credited.",
+			check: nonNegative(&c.ReferrerBonus)},
+		{key: "server.RefereeBonus", env: "REFEREE_BONUS", value: float32Var(&c.RefereeBonus),
+			usage: "Points for the referee on their first credited order.", check: nonNegative(&c.RefereeBonus)},
+		{key: "server.TransferDailyLimit", env: "TRANSFER_DAILY_LIMIT", value: float32Var(&c.TransferDailyLimit),
+			reloadable: true, usage: "Points a user may transfer per day, 0 disables the limit.",
+			check: nonNegative(&c.TransferDailyLimit)},
+		{key: "server.HealthTimeout", env: "HEALTH_TIMEOUT", value: durationVar(&c.HealthTimeout),
+			usage: "Bound of every readiness dependency check.", check: positive(&c.HealthTimeout)},
+
+		{key: "database.DSN", env: "DATABASE_URI", flag: "d", value: stringVar(&c.PostgresDSN),
+			usage: "PostgreSQL DSN", check: required(&c.PostgresDSN), redact: redactDSN},
+
+		{key: "accrual.Address", env: "ACCRUAL_SYSTEM_ADDRESS", flag: "r", value: stringVar(&c.AccrualAddress),
+			usage: "Accrual server address and port", check: required(&c.AccrualAddress)},
+		{key: "accrual.HTTPTimeout", env: "ACCRUAL_HTTP_TIMEOUT", value: durationVar(&c.AccrualHTTPTimeout),
+			usage: "Timeout of requests to the accrual service.", check: positive(&c.AccrualHTTPTimeout)},
+		{key: "accrual.RetryAfter", env: "ACCRUAL_RETRY_AFTER", value: durationVar(&c.AccrualRetryAfter), reloadable: true,
+			usage: "Backoff after 429 Too Many Requests without a Retry-After header.",
+			check: positive(&c.AccrualRetryAfter)},
+		{key: "accrual.Interval", env: "ACCRUAL_INTERVAL", value: durationVar(&c.AccrualInterval), reloadable: true,
+			usage: "Interval between claims of unprocessed orders.", check: positive(&c.AccrualInterval)},
+		{key: "accrual.Workers", env: "ACCRUAL_WORKERS", flag: "w", value: int64Var(&c.AccrualWorkers), reloadable: true,
+			usage: "Number of Accrual processing workers", check: positive(&c.AccrualWorkers)},
+		{key: "accrual.WorkerRetry", env: "ACCRUAL_WORKER_RETRY", value: durationVar(&c.AccrualWorkerRetry), reloadable: true,
+			usage: "Interval the workers check whether the backoff is over.", check: positive(&c.AccrualWorkerRetry)},
+
+		{key: "webhooks.Interval", env: "WEBHOOK_INTERVAL", value: durationVar(&c.WebhookInterval), reloadable: true,
+			usage: "Interval between webhook deliveries.", check: positive(&c.WebhookInterval)},
+		{key: "webhooks.HTTPTimeout", env: "WEBHOOK_HTTP_TIMEOUT", value: durationVar(&c.WebhookTimeout),
+			usage: "Timeout of webhook requests.", check: positive(&c.WebhookTimeout)},
+		{key: "webhooks.Backoff", env: "WEBHOOK_BACKOFF", value: durationVar(&c.WebhookBackoff), reloadable: true,
+			usage: "Backoff after a failed delivery, doubled on every attempt.", check: positive(&c.WebhookBackoff)},
+		{key: "webhooks.BackoffMax", env: "WEBHOOK_BACKOFF_MAX", value: durationVar(&c.WebhookBackoffMax), reloadable: true,
+			usage: "Maximum backoff between delivery attempts.", check: positive(&c.WebhookBackoffMax)},
+		{key: "webhooks.MaxAttempts", env: "WEBHOOK_MAX_ATTEMPTS", value: int64Var(&c.WebhookMaxAttempts), reloadable: true,
+			usage: "Delivery attempts before a message is dropped.", check: positive(&c.WebhookMaxAttempts)},
+		{key: "webhooks.BatchSize", env: "WEBHOOK_BATCH_SIZE", value: int64Var(&c.WebhookBatchSize), reloadable: true,
+			usage: "Deliveries per interval.", check: positive(&c.WebhookBatchSize)},
+
+		{key: "withdrawals.Min", env: "WITHDRAWAL_MIN", value: float32Var(&c.WithdrawalMin), reloadable: true,
+			usage: "Minimum amount of a withdrawal or transfer, 0 disables the limit.", check: nonNegative(&c.WithdrawalMin)},
+		{key: "withdrawals.Max", env: "WITHDRAWAL_MAX", value: float32Var(&c.WithdrawalMax), reloadable: true,
+			usage: "Maximum amount of a withdrawal or transfer, 0 disables the limit.", check: nonNegative(&c.WithdrawalMax)},
+		{key: "withdrawals.Daily", env: "WITHDRAWAL_DAILY", value: float32Var(&c.WithdrawalDaily), reloadable: true,
+			usage: "Maximum amount withdrawn or transferred per calendar day, 0 disables the limit.",
+			check: nonNegative(&c.WithdrawalDaily)},
+		{key: "withdrawals.Monthly", env: "WITHDRAWAL_MONTHLY", value: float32Var(&c.WithdrawalMonthly), reloadable: true,
+			usage: "Maximum amount withdrawn or transferred per calendar month, 0 disables the limit.",
+			check: nonNegative(&c.WithdrawalMonthly)},
+		{key: "withdrawals.Cooldown", env: "WITHDRAWAL_COOLDOWN", value: durationVar(&c.WithdrawalCooldown), reloadable: true,
+			usage: "Withdrawals and transfers are held this long after a password change or login from a new IP.",
+			check: nonNegative(&c.WithdrawalCooldown)},
+
+		{key: "risk.Delay", env: "RISK_DELAY", value: int64Var(&c.RiskDelay), reloadable: true,
+			usage: "Risk score delaying uploads and withdrawals, 0 disables the action.", check: score(&c.RiskDelay)},
+		{key: "risk.Review", env: "RISK_REVIEW", value: int64Var(&c.RiskReview), reloadable: true,
+			usage: "Risk score holding withdrawals for review, 0 disables the action.", check: score(&c.RiskReview)},
+		{key: "risk.Block", env: "RISK_BLOCK", value: int64Var(&c.RiskBlock), reloadable: true,
+			usage: "Risk score refusing uploads and withdrawals, 0 disables the action.", check: score(&c.RiskBlock)},
+		{key: "risk.RetryAfter", env: "RISK_RETRY_AFTER", value: durationVar(&c.RiskRetryAfter), reloadable: true,
+			usage: "Retry-After of delayed requests.", check: positive(&c.RiskRetryAfter)},
+
+		{key: "logging.Format", env: "LOG_FORMAT", value: stringVar(&c.LogFormat),
+			usage: "Log format, console or json.", check: oneOf(&c.LogFormat, logging.FormatConsole, logging.FormatJSON)},
+		{key: "logging.Level", env: "LOG_LEVEL", value: stringVar(&c.LogLevel), reloadable: true,
+			usage: "Minimum level logged, debug, info, warn or error.",
+			check: oneOf(&c.LogLevel, "debug", "info", "warn", "error")},
+
+		{key: "tracing.Exporter", env: "TRACING_EXPORTER", value: stringVar(&c.TracingExporter),
+			usage: "Span exporter, none, stdout or otlp.",
+			check: oneOf(&c.TracingExporter, tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP)},
+		{key: "tracing.Endpoint", env: "TRACING_ENDPOINT", value: stringVar(&c.TracingEndpoint),
+			usage: "OTLP gRPC collector, OTEL_EXPORTER_OTLP_ENDPOINT if empty."},
+		{key: "tracing.Insecure", env: "TRACING_INSECURE", value: boolVar(&c.TracingInsecure),
+			usage: "Connect to the OTLP collector without TLS."},
+		{key: "tracing.SampleRatio", env: "TRACING_SAMPLE_RATIO", value: float64Var(&c.TracingSampleRatio),
+			usage: "Share of new traces recorded.", check: ratio(&c.TracingSampleRatio)},
+
+		{key: "tiers", value: tiersVar(&c.Tiers),
+			usage: "Loyalty tiers, only set in the config file.", check: validTiers(&c.Tiers)},
+	}
+}
+
+// value parses a setting from env variables, flags and the config file and
+// prints it in the config file syntax.
+type value interface {
+	Set(s string) error
+	SetFile(v *viper.Viper, key string) error
+	String() string
+	// copyFrom sets the value to the one of src, a value of the same type.
+	copyFrom(src value)
+}
+
+type stringValue struct{ p *string }
+
+func stringVar(p *string) value { return stringValue{p} }
+
+func (v stringValue) Set(s string) error {
+	*v.p = s
+	return nil
+}
+
+func (v stringValue) SetFile(vp *viper.Viper, key string) error {
+	s, err := cast.ToStringE(vp.Get(key))
+	if err != nil {
+		return fmt.Errorf("not a string: %w", err)
+	}
+	*v.p = s
+	return nil
+}
+
+func (v stringValue) String() string { return strconv.Quote(*v.p) }
+
+func (v stringValue) copyFrom(src value) { *v.p = *src.(stringValue).p }
+
+type int64Value struct{ p *int64 }
+
+func int64Var(p *int64) value { return int64Value{p} }
+
+func (v int64Value) Set(s string) error {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("not an integer: %w", err)
+	}
+	*v.p = n
+	return nil
+}
+
+func (v int64Value) SetFile(vp *viper.Viper, key string) error {
+	n, err := cast.ToInt64E(vp.Get(key))
+	if err != nil {
+		return fmt.Errorf("not an integer: %w", err)
+	}
+	*v.p = n
+	return nil
+}
+
+func (v int64Value) String() string { return strconv.FormatInt(*v.p, 10) }
+
+func (v int64Value) copyFrom(src value) { *v.p = *src.(int64Value).p }
+
+type float32Value struct{ p *float32 }
+
+func float32Var(p *float32) value { return float32Value{p} }
+
+func (v float32Value) Set(s string) error {
+	f, err := strconv.ParseFloat(s, 32)
+	if err != nil {
+		return fmt.Errorf("not a number: %w", err)
+	}
+	*v.p = float32(f)
+	return nil
+}
+
+func (v float32Value) SetFile(vp *viper.Viper, key string) error {
+	f, err := cast.ToFloat32E(vp.Get(key))
+	if err != nil {
+		return fmt.Errorf("not a number: %w", err)
+	}
+	*v.p = f
+	return nil
+}
+
+func (v float32Value) String() string { return strconv.FormatFloat(float64(*v.p), 'f', -1, 32) }
+
+func (v float32Value) copyFrom(src value) { *v.p = *src.(float32Value).p }
+
+type float64Value struct{ p *float64 }
+
+func float64Var(p *float64) value { return float64Value{p} }
+
+func (v float64Value) Set(s string) error {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("not a number: %w", err)
+	}
+	*v.p = f
+	return nil
+}
+
+func (v float64Value) SetFile(vp *viper.Viper, key string) error {
+	f, err := cast.ToFloat64E(vp.Get(key))
+	if err != nil {
+		return fmt.Errorf("not a number: %w", err)
+	}
+	*v.p = f
+	return nil
+}
+
+func (v float64Value) String() string { return strconv.FormatFloat(*v.p, 'f', -1, 64) }
+
+func (v float64Value) copyFrom(src value) { *v.p = *src.(float64Value).p }
+
+type boolValue struct{ p *bool }
+
+func boolVar(p *bool) value { return boolValue{p} }
+
+func (v boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("not a boolean: %w", err)
+	}
+	*v.p = b
+	return nil
+}
+
+func (v boolValue) SetFile(vp *viper.Viper, key string) error {
+	b, err := cast.ToBoolE(vp.Get(key))
+	if err != nil {
+		return fmt.Errorf("not a boolean: %w", err)
+	}
+	*v.p = b
+	return nil
+}
+
+func (v boolValue) String() string { return strconv.FormatBool(*v.p) }
+
+func (v boolValue) copyFrom(src value) { *v.p = *src.(boolValue).p }
+
+// durationValue accepts whole seconds, as in earlier config files, or a Go
+// duration such as "1m30s".
+type durationValue struct{ p *time.Duration }
+
+func durationVar(p *time.Duration) value { return durationValue{p} }
+
+func (v durationValue) Set(s string) error {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		*v.p = time.Duration(n) * time.Second
+		return nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("not seconds or a duration: %w", err)
+	}
+	*v.p = d
+	return nil
+}
+
+func (v durationValue) SetFile(vp *viper.Viper, key string) error {
+	s, err := cast.ToStringE(vp.Get(key))
+	if err != nil {
+		return fmt.Errorf("not seconds or a duration: %w", err)
+	}
+	return v.Set(s)
+}
+
+func (v durationValue) String() string { return strconv.Quote(v.p.String()) }
+
+func (v durationValue) copyFrom(src value) { *v.p = *src.(durationValue).p }
+
+// listValue is a comma separated list in env variables and flags.
+type listValue struct{ p *[]string }
+
+func listVar(p *[]string) value { return listValue{p} }
+
+func (v listValue) Set(s string) error {
+	*v.p = nil
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*v.p = append(*v.p, item)
+		}
+	}
+	return nil
+}
+
+func (v listValue) SetFile(vp *viper.Viper, key string) error {
+	if s, ok := vp.Get(key).(string); ok {
+		return v.Set(s)
+	}
+	items, err := cast.ToStringSliceE(vp.Get(key))
+	if err != nil {
+		return fmt.Errorf("not a list: %w", err)
+	}
+	*v.p = items
+	return nil
+}
+
+func (v listValue) String() string {
+	items := make([]string, 0, len(*v.p))
+	for _, item := range *v.p {
+		items = append(items, strconv.Quote(item))
+	}
+	return "[" + strings.Join(items, ", ") + "]"
+}
+
+func (v listValue) copyFrom(src value) { *v.p = *src.(listValue).p }
+
+type tiersValue struct{ p *[]models.Tier }
+
+func tiersVar(p *[]models.Tier) value { return tiersValue{p} }
+
+func (v tiersValue) Set(string) error {
+	return errors.New("tiers can only be set in the config file")
+}
+
+func (v tiersValue) SetFile(vp *viper.Viper, key string) error {
+	var tiers []models.Tier
+	if err := vp.UnmarshalKey(key, &tiers); err != nil {
+		return fmt.Errorf("not a list of tiers: %w", err)
+	}
+	*v.p = tiers
+	return nil
+}
+
+func (v tiersValue) String() string {
+	items := make([]string, 0, len(*v.p))
+	for _, t := range *v.p {
+		items = append(items, fmt.Sprintf("{name: %s, threshold: %s, multiplier: %s}", strconv.Quote(t.Name),
+			strconv.FormatFloat(float64(t.Threshold), 'f', -1, 32),
+			strconv.FormatFloat(float64(t.Multiplier), 'f', -1, 32)))
+	}
+	return "[" + strings.Join(items, ", ") + "]"
+}
+
+func (v tiersValue) copyFrom(src value) { *v.p = *src.(tiersValue).p }
+
+func required(p *string) func() error {
+	return func() error {
+		if *p == "" {
+			return errors.New("is required")
+		}
+		return nil
+	}
+}
+
+func positive[T int64 | float32 | float64 | time.Duration](p *T) func() error {
+	return func() error {
+		if *p <= 0 {
+			return fmt.Errorf("must be positive, got %v", *p)
+		}
+		return nil
+	}
+}
+
+func nonNegative[T int64 | float32 | float64 | time.Duration](p *T) func() error {
+	return func() error {
+		if *p < 0 {
+			return fmt.Errorf("must not be negative, got %v", *p)
+		}
+		return nil
+	}
+}
+
+func score(p *int64) func() error {
+	return func() error {
+		if *p < 0 || *p > 100 {
+			return fmt.Errorf("must be between 0 and 100, got %d", *p)
+		}
+		return nil
+	}
+}
+
+func ratio(p *float64) func() error {
+	return func() error {
+		if *p < 0 || *p > 1 {
+			return fmt.Errorf("must be between 0 and 1, got %v", *p)
+		}
+		return nil
+	}
+}
+
+func oneOf(p *string, allowed ...string) func() error {
+	return func() error {
+		for _, a := range allowed {
+			if *p == a {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s, got %q", strings.Join(allowed, ", "), *p)
+	}
+}
+
+func validTiers(p *[]models.Tier) func() error {
+	return func() error {
+		for _, t := range *p {
+			if t.Name == "" || t.Multiplier <= 0 {
+				return fmt.Errorf("invalid tier %q: name and positive multiplier are required", t.Name)
+			}
+		}
+		return nil
+	}
+}
+
+const redacted string = "xxxxx"
+
+func redactAll(string) string { return redacted }
+
+var dsnPassword = regexp.MustCompile(`password=\S+`)
+
+// redactDSN hides the password of a URL or key/value DSN.
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+			return u.String()
+		}
+	}
+	return dsnPassword.ReplaceAllString(dsn, "password="+redacted)
+}
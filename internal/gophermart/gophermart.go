@@ -4,19 +4,24 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"go.uber.org/zap"
 
 	"golang.org/x/sync/errgroup"
 
 	"github.com/vkupriya/go-gophermart/internal/gophermart/config"
+	"github.com/vkupriya/go-gophermart/internal/gophermart/grpcserver"
 	"github.com/vkupriya/go-gophermart/internal/gophermart/server"
 	"github.com/vkupriya/go-gophermart/internal/gophermart/server/handlers"
 	"github.com/vkupriya/go-gophermart/internal/gophermart/service"
 	"github.com/vkupriya/go-gophermart/internal/gophermart/storage"
+	"github.com/vkupriya/go-gophermart/internal/gophermart/tracing"
 )
 
 func Start() (err error) {
@@ -25,6 +30,12 @@ func Start() (err error) {
 	if err != nil {
 		return fmt.Errorf("failed to initialize config: %w", err)
 	}
+	if cfg.PrintConfig {
+		if err := config.Print(os.Stdout, cfg); err != nil {
+			return fmt.Errorf("failed to print config: %w", err)
+		}
+		return nil
+	}
 
 	logger := cfg.Logger
 	rootCtx, cancelCtx := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
@@ -40,28 +51,66 @@ func Start() (err error) {
 		logger.Sugar().Error("failed to gracefully shutdown the service")
 	})
 
+	shutdownTracing, err := tracing.Setup(rootCtx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracing: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.TimeoutShutdown)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Sugar().Errorw("failed to flush traces", zap.Error(err))
+		}
+	}()
+
 	s, err := storage.NewPostgresDB(cfg.PostgresDSN)
 	if err != nil {
 		return fmt.Errorf("failed to initialize PostgresDB: %w", err)
 	}
 
+	cfg.Metrics.RegisterPool(s.Stat)
+	cfg.Metrics.RegisterOrders(func(ctx context.Context) (map[string]int64, error) {
+		return s.OrdersCountByStatus(ctx, cfg)
+	})
+
 	svc := service.NewGophermartService(s, cfg)
+	if err := svc.AdminsSync(rootCtx); err != nil {
+		return fmt.Errorf("failed to initialize admin users: %w", err)
+	}
 
-	h := handlers.NewGophermartHandler(svc, cfg.Logger)
-	r := handlers.NewGophermartRouter(cfg, h)
+	h := handlers.NewGophermartHandler(svc, cfg)
+	r, err := handlers.NewGophermartRouter(cfg, h)
+	if err != nil {
+		return fmt.Errorf("failed to initialize router: %w", err)
+	}
 	srv := server.NewServer(cfg, r)
 
+	if cfg.TLSCertFile != "" {
+		certs, err := server.NewCertificates(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to initialize TLS: %w", err)
+		}
+		srv.TLSConfig = certs.TLSConfig()
+
+		g.Go(func() error {
+			if err := certs.Run(ctx); err != nil {
+				return fmt.Errorf("certificate reloader has failed: %w", err)
+			}
+			return nil
+		})
+	}
+
 	logger.Sugar().Infow(
 		"Starting server",
 		"addr", cfg.Address,
+		"tls", srv.TLSConfig != nil,
 	)
 
+	reloader := config.NewReloader(os.Args[1:], os.LookupEnv, cfg, svc.Reconfigure, h.Reconfigure)
 	g.Go(func() error {
-		defer logger.Sugar().Info("closed DB")
-
-		<-ctx.Done()
-
-		s.Close()
+		if err := reloader.Run(ctx); err != nil {
+			return fmt.Errorf("config reloader has failed: %w", err)
+		}
 		return nil
 	})
 
@@ -82,7 +131,13 @@ func Start() (err error) {
 				}
 			}
 		}()
-		if err = srv.ListenAndServe(); err != nil {
+		if srv.TLSConfig != nil {
+			// certificates are served by the TLS config
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil {
 			if errors.Is(err, http.ErrServerClosed) {
 				return
 			}
@@ -95,6 +150,11 @@ func Start() (err error) {
 		defer logger.Sugar().Info("server has been shutdown")
 		<-ctx.Done()
 
+		// fail readiness first and give the orchestrator a probe period to
+		// notice, so that no new traffic is routed to the instance
+		svc.Drain()
+		time.Sleep(cfg.DrainDelay)
+
 		shutdownTimeoutCtx, cancelShutdownTimeoutCtx := context.WithTimeout(context.Background(), cfg.TimeoutServerShutdown)
 		defer cancelShutdownTimeoutCtx()
 		if err := srv.Shutdown(shutdownTimeoutCtx); err != nil {
@@ -103,6 +163,72 @@ func Start() (err error) {
 		return nil
 	})
 
+	if cfg.MetricsAddress != "" {
+		metricsSrv := server.NewMetricsServer(cfg, cfg.Metrics.Handler())
+
+		logger.Sugar().Infow(
+			"Starting metrics server",
+			"addr", cfg.MetricsAddress,
+		)
+
+		g.Go(func() error {
+			if err := metricsSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return fmt.Errorf("metrics server has failed: %w", err)
+			}
+			return nil
+		})
+
+		g.Go(func() error {
+			defer logger.Sugar().Info("metrics server has been shutdown")
+			<-ctx.Done()
+
+			shutdownTimeoutCtx, cancel := context.WithTimeout(context.Background(), cfg.TimeoutServerShutdown)
+			defer cancel()
+			if err := metricsSrv.Shutdown(shutdownTimeoutCtx); err != nil {
+				return fmt.Errorf("an error occurred during metrics server shutdown: %w", err)
+			}
+			return nil
+		})
+	}
+
+	if cfg.GRPCAddress != "" {
+		grpcSrv := grpcserver.NewServer(cfg, grpcserver.NewGophermartServer(svc, cfg))
+
+		logger.Sugar().Infow(
+			"Starting gRPC server",
+			"addr", cfg.GRPCAddress,
+		)
+
+		g.Go(func() error {
+			lis, err := net.Listen("tcp", cfg.GRPCAddress)
+			if err != nil {
+				return fmt.Errorf("failed to listen on gRPC address: %w", err)
+			}
+			if err := grpcSrv.Serve(lis); err != nil {
+				return fmt.Errorf("gRPC server has failed: %w", err)
+			}
+			return nil
+		})
+
+		g.Go(func() error {
+			defer logger.Sugar().Info("gRPC server has been shutdown")
+			<-ctx.Done()
+
+			stopped := make(chan struct{})
+			go func() {
+				grpcSrv.GracefulStop()
+				close(stopped)
+			}()
+
+			select {
+			case <-stopped:
+			case <-time.After(cfg.TimeoutServerShutdown):
+				grpcSrv.Stop()
+			}
+			return nil
+		})
+	}
+
 	g.Go(func() error {
 		if err := svc.OrderDispatcher(ctx); err != nil {
 			return fmt.Errorf("order fetcher has been terminated with error: %w", err)
@@ -110,7 +236,49 @@ func Start() (err error) {
 		return nil
 	})
 
-	if err := g.Wait(); err != nil {
+	g.Go(func() error {
+		if err := svc.OrderEventsListener(ctx); err != nil {
+			return fmt.Errorf("order events listener has been terminated with error: %w", err)
+		}
+		return nil
+	})
+
+	g.Go(func() error {
+		if err := svc.WebhookDispatcher(ctx); err != nil {
+			return fmt.Errorf("webhook dispatcher has been terminated with error: %w", err)
+		}
+		return nil
+	})
+
+	g.Go(func() error {
+		if err := svc.IdempotencyCleaner(ctx); err != nil {
+			return fmt.Errorf("idempotency cleaner has been terminated with error: %w", err)
+		}
+		return nil
+	})
+
+	g.Go(func() error {
+		if err := svc.PointsExpirer(ctx); err != nil {
+			return fmt.Errorf("points expirer has been terminated with error: %w", err)
+		}
+		return nil
+	})
+
+	g.Go(func() error {
+		if err := svc.TierRecalculator(ctx); err != nil {
+			return fmt.Errorf("tier recalculator has been terminated with error: %w", err)
+		}
+		return nil
+	})
+
+	err = g.Wait()
+
+	// requests drained by the servers and fetched accruals are stored before
+	// the pool is closed
+	s.Close()
+	logger.Sugar().Info("closed DB")
+
+	if err != nil {
 		return fmt.Errorf("go routines stopped with error: %w", err)
 	}
 	return nil
@@ -0,0 +1,143 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/grpcserver/pb"
+	"github.com/vkupriya/go-gophermart/internal/gophermart/helpers"
+	"github.com/vkupriya/go-gophermart/internal/gophermart/logging"
+	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
+	mw "github.com/vkupriya/go-gophermart/internal/gophermart/server/middleware"
+)
+
+const requestIDHeader string = "x-request-id"
+
+// methods that do not require authentication
+var publicMethods = map[string]bool{
+	pb.Gophermart_Register_FullMethodName: true,
+	pb.Gophermart_Login_FullMethodName:    true,
+}
+
+type InterceptorAuth struct {
+	config *models.Config
+	users  mw.UserLocker
+}
+
+func NewInterceptorAuth(c *models.Config, users mw.UserLocker) *InterceptorAuth {
+	return &InterceptorAuth{
+		config: c,
+		users:  users,
+	}
+}
+
+func (i *InterceptorAuth) Auth(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (any, error) {
+	if publicMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 || !strings.HasPrefix(values[0], "Bearer ") {
+		return nil, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+
+	claims, err := helpers.ValidateJWT(i.config, strings.TrimPrefix(values[0], "Bearer "))
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+
+	locked, err := i.users.UserLocked(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			return nil, status.Error(codes.Unauthenticated, "unknown user")
+		}
+		return nil, status.Error(codes.Internal, "failed to get user")
+	}
+	if locked {
+		return nil, status.Error(codes.Unauthenticated, "user is locked")
+	}
+
+	ctx = context.WithValue(ctx, mw.CtxKey{}, claims.UserID)
+	ctx = logging.With(ctx, zap.String("user_id", claims.UserID))
+	return handler(context.WithValue(ctx, mw.CtxRoleKey{}, claims.Role), req)
+}
+
+type InterceptorLogger struct {
+	logger *zap.Logger
+}
+
+func NewInterceptorLogger(zl *zap.Logger) *InterceptorLogger {
+	return &InterceptorLogger{
+		logger: zl,
+	}
+}
+
+// Logging stores a request logger carrying the x-request-id of the client,
+// or a new one, and the method in the context, and logs the call once done.
+// The request ID is returned in the response header.
+func (i *InterceptorLogger) Logging(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	var id string
+	if values := md.Get(requestIDHeader); len(values) > 0 {
+		id = values[0]
+	}
+	id = logging.RequestID(id)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestIDHeader, id))
+
+	logger := i.logger.With(zap.String("request_id", id), zap.String("method", info.FullMethod))
+	resp, err := handler(logging.NewContext(ctx, logger), req)
+
+	logger.Info("call completed",
+		zap.String("code", status.Code(err).String()),
+		zap.Duration("duration", time.Since(start)),
+	)
+	return resp, err
+}
+
+type InterceptorRecovery struct {
+	logger *zap.Logger
+}
+
+func NewInterceptorRecovery(zl *zap.Logger) *InterceptorRecovery {
+	return &InterceptorRecovery{
+		logger: zl,
+	}
+}
+
+func (i *InterceptorRecovery) Recovery(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (resp any, err error) {
+	logger := logging.FromContext(ctx, i.logger)
+	defer func() {
+		errRec := recover()
+		if errRec != nil {
+			switch x := errRec.(type) {
+			case string:
+				err := errors.New(x)
+				logger.Sugar().Error("a panic occured ", zap.Error(err))
+			case error:
+				err := fmt.Errorf("a panic occurred: %w", x)
+				logger.Sugar().Error(zap.Error(err))
+			default:
+				err := errors.New("unknown panic")
+				logger.Sugar().Error(zap.Error(err))
+			}
+			resp, err = nil, status.Error(codes.Internal, "internal error")
+		}
+	}()
+
+	return handler(ctx, req)
+}
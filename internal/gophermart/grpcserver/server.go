@@ -0,0 +1,273 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/grpcserver/pb"
+	"github.com/vkupriya/go-gophermart/internal/gophermart/helpers"
+	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
+	"github.com/vkupriya/go-gophermart/internal/gophermart/server/handlers"
+	mw "github.com/vkupriya/go-gophermart/internal/gophermart/server/middleware"
+)
+
+type GophermartServer struct {
+	pb.UnimplementedGophermartServer
+	service handlers.Service
+	config  *models.Config
+	logger  *zap.Logger
+}
+
+func NewGophermartServer(service handlers.Service, cfg *models.Config) *GophermartServer {
+	return &GophermartServer{
+		service: service,
+		config:  cfg,
+		logger:  cfg.Logger,
+	}
+}
+
+// NewServer returns a gRPC server with the recovery, logging and auth
+// interceptors that mirror the HTTP middleware chain.
+func NewServer(cfg *models.Config, gs *GophermartServer) *grpc.Server {
+	ia := NewInterceptorAuth(cfg, gs.service)
+	il := NewInterceptorLogger(cfg.Logger)
+	ir := NewInterceptorRecovery(cfg.Logger)
+
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		il.Logging,
+		ir.Recovery,
+		ia.Auth,
+	))
+	pb.RegisterGophermartServer(srv, gs)
+	return srv
+}
+
+func userFromContext(ctx context.Context) (string, error) {
+	uid, ok := ctx.Value(mw.CtxKey{}).(string)
+	if !ok {
+		return "", status.Error(codes.Internal, "failed to get user from context value")
+	}
+	return uid, nil
+}
+
+// peerIP returns the host part of the client address, or an empty string.
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return ""
+	}
+	return host
+}
+
+func (gs *GophermartServer) Register(ctx context.Context, req *pb.Credentials) (*pb.AuthResponse, error) {
+	logger := gs.logger
+	if req.GetLogin() == "" || req.GetPassword() == "" {
+		return nil, status.Error(codes.InvalidArgument, "login and password are required")
+	}
+
+	if err := gs.service.UserAdd(ctx, models.User{UserID: req.GetLogin(), Password: req.GetPassword()}); err != nil {
+		logger.Sugar().Error(zap.Error(err))
+		return nil, status.Error(codes.AlreadyExists, "login is already taken")
+	}
+
+	token, err := gs.service.UserLogin(ctx, req.GetLogin(), req.GetPassword(), peerIP(ctx))
+	if err != nil || token == "" {
+		logger.Sugar().Errorf("user %s failed to authenticate", req.GetLogin())
+		return nil, status.Error(codes.Unauthenticated, "failed to authenticate")
+	}
+	return &pb.AuthResponse{Token: token}, nil
+}
+
+func (gs *GophermartServer) Login(ctx context.Context, req *pb.Credentials) (*pb.AuthResponse, error) {
+	token, err := gs.service.UserLogin(ctx, req.GetLogin(), req.GetPassword(), peerIP(ctx))
+	if err != nil || token == "" {
+		gs.logger.Sugar().Errorf("user %s failed to authenticate", req.GetLogin())
+		return nil, status.Error(codes.Unauthenticated, "wrong login or password")
+	}
+	return &pb.AuthResponse{Token: token}, nil
+}
+
+func (gs *GophermartServer) OrderAdd(ctx context.Context, req *pb.OrderAddRequest) (*pb.OrderAddResponse, error) {
+	logger := gs.logger
+	uid, err := userFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	oid, err := helpers.NormalizeOrder(req.GetNumber(), gs.config.OrderMaxLength)
+	if err != nil || !helpers.ValidOrder(oid) {
+		return nil, status.Errorf(codes.InvalidArgument, "incorrect order number %s", req.GetNumber())
+	}
+
+	d, err := gs.service.RiskAssess(ctx, uid, models.RiskOrderUpload, oid, 0)
+	if err != nil {
+		logger.Sugar().Error("failed to assess order upload risk", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to assess order")
+	}
+	if err := riskRefused(d); err != nil {
+		return nil, err
+	}
+
+	order, err := gs.service.OrderGet(ctx, oid)
+	if err != nil {
+		logger.Sugar().Error("failed to get order from DB", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get order")
+	}
+	if order.UserID != "" {
+		if order.UserID == uid {
+			return &pb.OrderAddResponse{Accepted: false}, nil
+		}
+		if err := gs.service.OrderConflicts(ctx, uid, []string{oid}); err != nil {
+			logger.Sugar().Error("failed to record order conflict", zap.Error(err))
+		}
+		return nil, status.Errorf(codes.AlreadyExists, "order %s already registered by another user", oid)
+	}
+	if d.Action == models.RiskReview {
+		return nil, status.Error(codes.FailedPrecondition, "order upload is under review")
+	}
+
+	if err := gs.service.OrderAdd(ctx, uid, oid); err != nil {
+		logger.Sugar().Error(zap.Error(err))
+		return nil, status.Errorf(codes.AlreadyExists, "order %s already registered", oid)
+	}
+	return &pb.OrderAddResponse{Accepted: true}, nil
+}
+
+func (gs *GophermartServer) OrdersGet(ctx context.Context, _ *emptypb.Empty) (*pb.OrdersResponse, error) {
+	uid, err := userFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := gs.service.OrdersGet(ctx, uid)
+	if err != nil {
+		gs.logger.Sugar().Error("failed to get orders", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get orders")
+	}
+
+	resp := &pb.OrdersResponse{Orders: make([]*pb.Order, 0, len(orders))}
+	for _, o := range orders {
+		resp.Orders = append(resp.Orders, &pb.Order{
+			Number:     o.Number,
+			Status:     o.Status,
+			Accrual:    o.Accrual,
+			UploadedAt: timestamppb.New(o.Uploaded),
+		})
+	}
+	return resp, nil
+}
+
+func (gs *GophermartServer) BalanceGet(ctx context.Context, _ *emptypb.Empty) (*pb.Balance, error) {
+	uid, err := userFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bal, err := gs.service.BalanceGet(ctx, uid)
+	if err != nil {
+		gs.logger.Sugar().Error("failed to get user balance", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get balance")
+	}
+	return &pb.Balance{Current: bal.Current, Withdrawn: bal.Withdrawn}, nil
+}
+
+func (gs *GophermartServer) Withdraw(ctx context.Context, req *pb.WithdrawRequest) (*emptypb.Empty, error) {
+	logger := gs.logger
+	uid, err := userFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	oid, err := helpers.NormalizeOrder(req.GetOrder(), gs.config.OrderMaxLength)
+	if err != nil || !helpers.ValidOrder(oid) {
+		return nil, status.Errorf(codes.InvalidArgument, "incorrect order number %s", req.GetOrder())
+	}
+	if req.GetSum() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "sum must be positive")
+	}
+
+	user, err := gs.service.UserGet(ctx, uid)
+	if err != nil {
+		logger.Sugar().Error("failed to get user from DB", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get user")
+	}
+	if user.Accrual == 0 || req.GetSum() > user.Accrual {
+		return nil, status.Error(codes.FailedPrecondition, "not enough accrual points to withdraw")
+	}
+
+	d, err := gs.service.RiskAssess(ctx, uid, models.RiskWithdrawal, oid, req.GetSum())
+	if err != nil {
+		logger.Sugar().Error("failed to assess withdrawal risk", zap.Error(err))
+		if errors.Is(err, models.ErrInsufficientFunds) {
+			return nil, status.Error(codes.FailedPrecondition, "not enough accrual points to withdraw")
+		}
+		return nil, status.Error(codes.Internal, "failed to assess withdrawal")
+	}
+	if err := riskRefused(d); err != nil {
+		return nil, err
+	}
+	if d.Action == models.RiskReview {
+		return nil, status.Error(codes.FailedPrecondition, "withdrawal is under review")
+	}
+
+	w := models.Withdrawal{UserID: uid, Number: oid, Sum: req.GetSum()}
+	if err := gs.service.AccrualWithdraw(ctx, w); err != nil {
+		logger.Sugar().Error(zap.Error(err))
+		var le *models.LimitError
+		if errors.As(err, &le) {
+			return nil, status.Error(codes.FailedPrecondition, le.Code)
+		}
+		if errors.Is(err, models.ErrInsufficientFunds) {
+			return nil, status.Error(codes.FailedPrecondition, "not enough accrual points to withdraw")
+		}
+		return nil, status.Errorf(codes.AlreadyExists, "withdrawal for order %s already registered", oid)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (gs *GophermartServer) WithdrawalsGet(ctx context.Context, _ *emptypb.Empty) (*pb.WithdrawalsResponse, error) {
+	uid, err := userFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	withdrawals, err := gs.service.WithdrawalsGet(ctx, uid)
+	if err != nil {
+		gs.logger.Sugar().Error("failed to get withdrawals", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get withdrawals")
+	}
+
+	resp := &pb.WithdrawalsResponse{Withdrawals: make([]*pb.Withdrawal, 0, len(withdrawals))}
+	for _, w := range withdrawals {
+		resp.Withdrawals = append(resp.Withdrawals, &pb.Withdrawal{
+			Order:       w.Number,
+			Sum:         w.Sum,
+			ProcessedAt: timestamppb.New(w.Processed),
+		})
+	}
+	return resp, nil
+}
+
+// riskRefused maps delayed and blocked risk decisions to gRPC errors.
+func riskRefused(d models.RiskDecision) error {
+	switch d.Action {
+	case models.RiskDelay:
+		return status.Error(codes.ResourceExhausted, "too many requests, retry later")
+	case models.RiskBlock:
+		return status.Error(codes.PermissionDenied, "operation is blocked")
+	}
+	return nil
+}
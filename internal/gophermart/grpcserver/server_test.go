@@ -0,0 +1,117 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/grpcserver/pb"
+	"github.com/vkupriya/go-gophermart/internal/gophermart/helpers"
+	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
+	mock_handlers "github.com/vkupriya/go-gophermart/internal/gophermart/server/handlers/mocks"
+)
+
+func newTestClient(t *testing.T, cfg *models.Config, svc *mock_handlers.MockService) pb.GophermartClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := NewServer(cfg, NewGophermartServer(svc, cfg))
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return pb.NewGophermartClient(conn)
+}
+
+func TestGophermartServer(t *testing.T) {
+	cfg := &models.Config{Logger: zap.NewNop(), JWTKey: "test", JWTTokenTTL: time.Minute}
+	token, err := helpers.CreateJWTString(cfg, "user01", models.RoleUser)
+	require.NoError(t, err)
+	authCtx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+
+	ctrl := gomock.NewController(t)
+	svc := mock_handlers.NewMockService(ctrl)
+	svc.EXPECT().UserLocked(gomock.Any(), "user01").Return(false, nil).AnyTimes()
+	client := newTestClient(t, cfg, svc)
+
+	t.Run("#login_OK", func(t *testing.T) {
+		svc.EXPECT().UserLogin(gomock.Any(), "user01", "secret", gomock.Any()).Return(token, nil)
+		resp, err := client.Login(context.Background(), &pb.Credentials{Login: "user01", Password: "secret"})
+		require.NoError(t, err)
+		assert.Equal(t, token, resp.GetToken())
+	})
+
+	t.Run("#balance_unauthenticated_FAIL", func(t *testing.T) {
+		_, err := client.BalanceGet(context.Background(), &emptypb.Empty{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("#balance_OK", func(t *testing.T) {
+		svc.EXPECT().BalanceGet(gomock.Any(), "user01").Return(models.Balance{Current: 600.5, Withdrawn: 386.5}, nil)
+		resp, err := client.BalanceGet(authCtx, &emptypb.Empty{})
+		require.NoError(t, err)
+		assert.Equal(t, float32(600.5), resp.GetCurrent())
+		assert.Equal(t, float32(386.5), resp.GetWithdrawn())
+	})
+
+	t.Run("#order_add_conflict_FAIL", func(t *testing.T) {
+		svc.EXPECT().RiskAssess(gomock.Any(), "user01", models.RiskOrderUpload, "2377225624", float32(0)).Return(
+			models.RiskDecision{Action: models.RiskAllow}, nil)
+		svc.EXPECT().OrderGet(gomock.Any(), "2377225624").Return(models.Order{UserID: "user02", Number: "2377225624"}, nil)
+		svc.EXPECT().OrderConflicts(gomock.Any(), "user01", []string{"2377225624"}).Return(nil)
+		_, err := client.OrderAdd(authCtx, &pb.OrderAddRequest{Number: "2377225624"})
+		assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	})
+
+	t.Run("#order_add_delayed_FAIL", func(t *testing.T) {
+		svc.EXPECT().RiskAssess(gomock.Any(), "user01", models.RiskOrderUpload, "2377225624", float32(0)).Return(
+			models.RiskDecision{Action: models.RiskDelay}, nil)
+		_, err := client.OrderAdd(authCtx, &pb.OrderAddRequest{Number: "2377225624"})
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	})
+
+	t.Run("#order_add_review_FAIL", func(t *testing.T) {
+		svc.EXPECT().RiskAssess(gomock.Any(), "user01", models.RiskOrderUpload, "2377225624", float32(0)).Return(
+			models.RiskDecision{Action: models.RiskReview}, nil)
+		svc.EXPECT().OrderGet(gomock.Any(), "2377225624").Return(models.Order{}, nil)
+		_, err := client.OrderAdd(authCtx, &pb.OrderAddRequest{Number: "2377225624"})
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	t.Run("#order_add_incorrect_number_FAIL", func(t *testing.T) {
+		_, err := client.OrderAdd(authCtx, &pb.OrderAddRequest{Number: "2377225625"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("#panic_recovered", func(t *testing.T) {
+		svc.EXPECT().WithdrawalsGet(gomock.Any(), "user01").DoAndReturn(func(context.Context, string) (
+			models.Withdrawals, error) {
+			panic("boom")
+		})
+		_, err := client.WithdrawalsGet(authCtx, &emptypb.Empty{})
+		assert.Equal(t, codes.Internal, status.Code(err))
+	})
+}
@@ -10,12 +10,13 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-func CreateJWTString(c *models.Config, userid string) (string, error) {
+func CreateJWTString(c *models.Config, userid string, role string) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, models.Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(c.JWTTokenTTL)),
 		},
 		UserID: userid,
+		Role:   role,
 	})
 
 	// создаём строку токена
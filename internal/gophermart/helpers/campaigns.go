@@ -0,0 +1,61 @@
+package helpers
+
+import (
+	"time"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
+)
+
+// CampaignValid reports whether the campaign has a name, a known rule with
+// its parameters and a non-empty date range.
+func CampaignValid(cmp models.Campaign) bool {
+	if cmp.Name == "" || !cmp.Ends.After(cmp.Starts) || cmp.Budget < 0 || cmp.UserBudget < 0 {
+		return false
+	}
+	switch cmp.Rule {
+	case models.CampaignFirstOrder:
+		return cmp.Amount > 0
+	case models.CampaignWeekend:
+		return cmp.Multiplier > 1
+	case models.CampaignThreshold:
+		return cmp.Amount > 0 && cmp.Threshold > 0
+	default:
+		return false
+	}
+}
+
+// CampaignBonus returns the bonus granted by the campaign for an order with
+// the given accrual credited at t, first is true for the first credited
+// order of the user. Budgets are not taken into account.
+func CampaignBonus(cmp models.Campaign, accrual float32, first bool, t time.Time) float32 {
+	if t.Before(cmp.Starts) || !t.Before(cmp.Ends) {
+		return 0
+	}
+	switch cmp.Rule {
+	case models.CampaignFirstOrder:
+		if first {
+			return cmp.Amount
+		}
+	case models.CampaignWeekend:
+		if wd := t.Weekday(); wd == time.Saturday || wd == time.Sunday {
+			return accrual * (cmp.Multiplier - 1)
+		}
+	case models.CampaignThreshold:
+		if accrual >= cmp.Threshold {
+			return cmp.Amount
+		}
+	}
+	return 0
+}
+
+// CampaignCap limits the bonus to what is left of the global and per-user
+// budgets, userSpent is the bonus already granted to the user.
+func CampaignCap(cmp models.Campaign, bonus float32, userSpent float32) float32 {
+	if cmp.Budget > 0 {
+		bonus = min(bonus, cmp.Budget-cmp.Spent)
+	}
+	if cmp.UserBudget > 0 {
+		bonus = min(bonus, cmp.UserBudget-userSpent)
+	}
+	return max(bonus, 0)
+}
@@ -0,0 +1,92 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
+)
+
+func TestCampaignBonus(t *testing.T) {
+	starts := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	ends := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	saturday := time.Date(2024, 6, 8, 12, 0, 0, 0, time.UTC)
+	monday := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		t        time.Time
+		name     string
+		cmp      models.Campaign
+		accrual  float32
+		first    bool
+		expected float32
+	}{
+		{
+			name:     "#first_order",
+			cmp:      models.Campaign{Rule: models.CampaignFirstOrder, Amount: 50, Starts: starts, Ends: ends},
+			accrual:  10,
+			first:    true,
+			t:        monday,
+			expected: 50,
+		},
+		{
+			name:     "#first_order_repeat",
+			cmp:      models.Campaign{Rule: models.CampaignFirstOrder, Amount: 50, Starts: starts, Ends: ends},
+			accrual:  10,
+			t:        monday,
+			expected: 0,
+		},
+		{
+			name:     "#weekend",
+			cmp:      models.Campaign{Rule: models.CampaignWeekend, Multiplier: 2, Starts: starts, Ends: ends},
+			accrual:  30,
+			t:        saturday,
+			expected: 30,
+		},
+		{
+			name:     "#weekday",
+			cmp:      models.Campaign{Rule: models.CampaignWeekend, Multiplier: 2, Starts: starts, Ends: ends},
+			accrual:  30,
+			t:        monday,
+			expected: 0,
+		},
+		{
+			name:     "#threshold",
+			cmp:      models.Campaign{Rule: models.CampaignThreshold, Amount: 20, Threshold: 100, Starts: starts, Ends: ends},
+			accrual:  100,
+			t:        monday,
+			expected: 20,
+		},
+		{
+			name:     "#below_threshold",
+			cmp:      models.Campaign{Rule: models.CampaignThreshold, Amount: 20, Threshold: 100, Starts: starts, Ends: ends},
+			accrual:  99,
+			t:        monday,
+			expected: 0,
+		},
+		{
+			name:     "#ended",
+			cmp:      models.Campaign{Rule: models.CampaignFirstOrder, Amount: 50, Starts: starts, Ends: ends},
+			first:    true,
+			t:        ends,
+			expected: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, CampaignBonus(tc.cmp, tc.accrual, tc.first, tc.t))
+		})
+	}
+}
+
+func TestCampaignCap(t *testing.T) {
+	cmp := models.Campaign{Budget: 100, Spent: 90, UserBudget: 30}
+
+	assert.Equal(t, float32(10), CampaignCap(cmp, 25, 0), "global budget")
+	assert.Equal(t, float32(5), CampaignCap(cmp, 25, 25), "user budget")
+	assert.Equal(t, float32(0), CampaignCap(cmp, 25, 40), "user budget exceeded")
+	assert.Equal(t, float32(25), CampaignCap(models.Campaign{}, 25, 1000), "unlimited")
+}
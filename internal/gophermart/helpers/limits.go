@@ -0,0 +1,23 @@
+package helpers
+
+import "github.com/vkupriya/go-gophermart/internal/gophermart/models"
+
+// CheckWithdrawal returns a *models.LimitError if a withdrawal of sum
+// violates the configured limits, zero limits are disabled. Daily and
+// monthly stats do not include sum.
+func CheckWithdrawal(c *models.Config, sum float32, st models.WithdrawalStats) error {
+	switch {
+	case c.WithdrawalMin > 0 && sum < c.WithdrawalMin:
+		return &models.LimitError{Code: models.LimitBelowMinimum}
+	case c.WithdrawalMax > 0 && sum > c.WithdrawalMax:
+		return &models.LimitError{Code: models.LimitAboveMaximum}
+	case c.WithdrawalCooldown > 0 && !st.SecurityChanged.IsZero() &&
+		st.Now.Before(st.SecurityChanged.Add(c.WithdrawalCooldown)):
+		return &models.LimitError{Code: models.LimitCooldown}
+	case c.WithdrawalDaily > 0 && st.Daily+sum > c.WithdrawalDaily:
+		return &models.LimitError{Code: models.LimitDaily}
+	case c.WithdrawalMonthly > 0 && st.Monthly+sum > c.WithdrawalMonthly:
+		return &models.LimitError{Code: models.LimitMonthly}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package helpers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
+)
+
+func TestCheckWithdrawal(t *testing.T) {
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+	cfg := &models.Config{
+		WithdrawalMin:      10,
+		WithdrawalMax:      500,
+		WithdrawalDaily:    1000,
+		WithdrawalMonthly:  3000,
+		WithdrawalCooldown: 24 * time.Hour,
+	}
+
+	testCases := []struct {
+		name string
+		code string
+		st   models.WithdrawalStats
+		sum  float32
+	}{
+		{name: "#allowed", sum: 500, st: models.WithdrawalStats{Now: now, Daily: 500, Monthly: 2500}},
+		{name: "#below_minimum", sum: 5, st: models.WithdrawalStats{Now: now}, code: models.LimitBelowMinimum},
+		{name: "#above_maximum", sum: 501, st: models.WithdrawalStats{Now: now}, code: models.LimitAboveMaximum},
+		{name: "#daily", sum: 100, st: models.WithdrawalStats{Now: now, Daily: 950}, code: models.LimitDaily},
+		{name: "#monthly", sum: 100, st: models.WithdrawalStats{Now: now, Monthly: 2950}, code: models.LimitMonthly},
+		{
+			name: "#cooldown",
+			sum:  100,
+			st:   models.WithdrawalStats{Now: now, SecurityChanged: now.Add(-time.Hour)},
+			code: models.LimitCooldown,
+		},
+		{
+			name: "#cooldown_over",
+			sum:  100,
+			st:   models.WithdrawalStats{Now: now, SecurityChanged: now.Add(-25 * time.Hour)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := CheckWithdrawal(cfg, tc.sum, tc.st)
+			if tc.code == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var le *models.LimitError
+			assert.True(t, errors.As(err, &le))
+			assert.Equal(t, tc.code, le.Code)
+			assert.ErrorIs(t, err, models.ErrLimitExceeded)
+		})
+	}
+
+	assert.NoError(t, CheckWithdrawal(&models.Config{}, 1e6, models.WithdrawalStats{Now: now}), "limits disabled")
+}
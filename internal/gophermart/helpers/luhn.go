@@ -1,30 +1,64 @@
 package helpers
 
-func ValidOrder(number int64) bool {
-	const ten = 10
-	return (number%ten+checksumLuhn(number/ten))%ten == 0
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrOrderEmpty     = errors.New("order number is empty")
+	ErrOrderNotDigits = errors.New("order number must contain digits only")
+	ErrOrderTooLong   = errors.New("order number is too long")
+)
+
+// NormalizeOrder returns the canonical form of an order number: surrounding
+// whitespace is trimmed and leading zeros are kept. maxLen <= 0 disables the
+// length check.
+func NormalizeOrder(number string, maxLen int64) (string, error) {
+	number = strings.TrimSpace(number)
+	if number == "" {
+		return "", ErrOrderEmpty
+	}
+	if maxLen > 0 && int64(len(number)) > maxLen {
+		return "", fmt.Errorf("%w: %d digits, max %d", ErrOrderTooLong, len(number), maxLen)
+	}
+	for i := 0; i < len(number); i++ {
+		if number[i] < '0' || number[i] > '9' {
+			return "", ErrOrderNotDigits
+		}
+	}
+	return number, nil
 }
 
-func checksumLuhn(number int64) int64 {
+// ValidOrder reports whether the number passes the Luhn check. Numbers of
+// any length are supported.
+func ValidOrder(number string) bool {
 	const (
 		ten  = 10
-		two  = 2
 		nine = 9
 	)
-	var luhn int64
+	if number == "" {
+		return false
+	}
 
-	for i := 0; number > 0; i++ {
-		cur := number % ten
+	var luhn int
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		if number[i] < '0' || number[i] > '9' {
+			return false
+		}
+		cur := int(number[i] - '0')
 
-		if i%2 == 0 { // even
-			cur *= two
+		if double {
+			cur *= 2
 			if cur > nine {
-				cur = cur%ten + cur/ten
+				cur -= nine
 			}
 		}
 
 		luhn += cur
-		number /= 10
+		double = !double
 	}
-	return luhn % ten
+	return luhn%ten == 0
 }
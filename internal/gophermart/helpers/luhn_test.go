@@ -0,0 +1,131 @@
+package helpers
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// referenceLuhn is the textbook left-to-right Luhn check.
+func referenceLuhn(number string) bool {
+	if number == "" {
+		return false
+	}
+	sum := 0
+	parity := len(number) % 2
+	for i, r := range number {
+		if r < '0' || r > '9' {
+			return false
+		}
+		d := int(r - '0')
+		if i%2 == parity {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// legacyValidOrder is the former int64-based check kept to make sure numbers
+// that fit into int64 are validated the same way as before.
+func legacyValidOrder(number int64) bool {
+	var luhn int64
+	rest := number / 10
+	for i := 0; rest > 0; i++ {
+		cur := rest % 10
+		if i%2 == 0 {
+			cur *= 2
+			if cur > 9 {
+				cur = cur%10 + cur/10
+			}
+		}
+		luhn += cur
+		rest /= 10
+	}
+	return (number%10+luhn%10)%10 == 0
+}
+
+func TestValidOrder(t *testing.T) {
+	cases := []struct {
+		number string
+		valid  bool
+	}{
+		{"2377225624", true},
+		{"2377225625", false},
+		{"12345678903", true},
+		{"0012345678903", true},
+		{"79927398713", true},
+		{"4561261212345467", true},
+		{"123456789012345678901234567891", true},
+		{"123456789012345678901234567897", false},
+		{"", false},
+		{"12a4", false},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.valid, ValidOrder(tc.number), tc.number)
+	}
+}
+
+func TestNormalizeOrder(t *testing.T) {
+	cases := []struct {
+		number   string
+		maxLen   int64
+		expected string
+		err      error
+	}{
+		{" 2377225624\n", 200, "2377225624", nil},
+		{"0012345678903", 200, "0012345678903", nil},
+		{"  ", 200, "", ErrOrderEmpty},
+		{"12 34", 200, "", ErrOrderNotDigits},
+		{"-12345678903", 200, "", ErrOrderNotDigits},
+		{"12345678903", 5, "", ErrOrderTooLong},
+		{strings.Repeat("1", 500), 0, strings.Repeat("1", 500), nil},
+	}
+	for _, tc := range cases {
+		actual, err := NormalizeOrder(tc.number, tc.maxLen)
+		assert.ErrorIs(t, err, tc.err, tc.number)
+		assert.Equal(t, tc.expected, actual, tc.number)
+	}
+}
+
+func FuzzValidOrder(f *testing.F) {
+	for _, seed := range []string{"2377225624", "12345678903", "0079927398713", "1", "", "12a"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, number string) {
+		valid := ValidOrder(number)
+		if valid != referenceLuhn(number) {
+			t.Fatalf("ValidOrder(%q) = %v, reference disagrees", number, valid)
+		}
+		digitsOnly := strings.Trim(number, "0123456789") == ""
+		if n, err := strconv.ParseInt(number, 10, 64); err == nil && digitsOnly {
+			if valid != legacyValidOrder(n) {
+				t.Fatalf("ValidOrder(%q) = %v, legacy int64 check disagrees", number, valid)
+			}
+		}
+	})
+}
+
+func FuzzNormalizeOrder(f *testing.F) {
+	for _, seed := range []string{" 2377225624 ", "0012", "12 34", ""} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, number string) {
+		normalized, err := NormalizeOrder(number, 0)
+		if err != nil {
+			return
+		}
+		again, err := NormalizeOrder(normalized, 0)
+		if err != nil || again != normalized {
+			t.Fatalf("NormalizeOrder is not idempotent for %q", number)
+		}
+		if ValidOrder(normalized) != referenceLuhn(normalized) {
+			t.Fatalf("ValidOrder(%q) disagrees with reference", normalized)
+		}
+	})
+}
@@ -0,0 +1,20 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+const inviteCodeSize int = 5
+
+// GenerateInviteCode returns a random personal invite code of upper case
+// hex digits.
+func GenerateInviteCode() (string, error) {
+	b := make([]byte, inviteCodeSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.New("failed to generate invite code")
+	}
+	return strings.ToUpper(hex.EncodeToString(b)), nil
+}
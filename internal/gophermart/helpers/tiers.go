@@ -0,0 +1,29 @@
+package helpers
+
+import "github.com/vkupriya/go-gophermart/internal/gophermart/models"
+
+// TierMultiplier returns the accrual multiplier of the named tier, users
+// without a tier or with a tier that is no longer configured get 1.
+func TierMultiplier(tiers []models.Tier, name string) float32 {
+	for _, t := range tiers {
+		if t.Name == name {
+			return t.Multiplier
+		}
+	}
+	return 1
+}
+
+// TierFor returns the highest tier whose threshold is reached by total,
+// or an empty string.
+func TierFor(tiers []models.Tier, total float32) string {
+	var (
+		tier      string
+		threshold float32 = -1
+	)
+	for _, t := range tiers {
+		if total >= t.Threshold && t.Threshold > threshold {
+			tier, threshold = t.Name, t.Threshold
+		}
+	}
+	return tier
+}
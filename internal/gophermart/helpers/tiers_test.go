@@ -0,0 +1,39 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
+)
+
+func TestTiers(t *testing.T) {
+	tiers := []models.Tier{
+		{Name: "gold", Threshold: 5000, Multiplier: 1.25},
+		{Name: "silver", Threshold: 1000, Multiplier: 1.1},
+		{Name: "platinum", Threshold: 20000, Multiplier: 1.5},
+	}
+
+	testCases := []struct {
+		name       string
+		tier       string
+		total      float32
+		multiplier float32
+	}{
+		{name: "#no_tier", total: 999.9, tier: "", multiplier: 1},
+		{name: "#silver_threshold", total: 1000, tier: "silver", multiplier: 1.1},
+		{name: "#gold", total: 19999, tier: "gold", multiplier: 1.25},
+		{name: "#platinum", total: 50000, tier: "platinum", multiplier: 1.5},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tier := TierFor(tiers, tc.total)
+			assert.Equal(t, tc.tier, tier)
+			assert.Equal(t, tc.multiplier, TierMultiplier(tiers, tier))
+		})
+	}
+
+	assert.Equal(t, float32(1), TierMultiplier(tiers, "diamond"), "removed tier must not change accruals")
+}
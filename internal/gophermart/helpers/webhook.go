@@ -0,0 +1,79 @@
+package helpers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const webhookSecretSize int = 32
+
+// SignWebhook returns the signature header value for a webhook payload:
+// "t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<body>">".
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	ts := strconv.FormatInt(timestamp, 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func GenerateSecret() (string, error) {
+	b := make([]byte, webhookSecretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.New("failed to generate secret")
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ValidWebhookURL accepts https URLs without credentials whose host is not an
+// internal address. Host names are resolved and checked again on every
+// delivery by WebhookDialControl.
+func ValidWebhookURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	if u.Scheme != "https" || u.Hostname() == "" || u.User != nil {
+		return false
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil && !PublicIP(ip) {
+		return false
+	}
+	return true
+}
+
+// PublicIP reports whether ip may be reached by webhook deliveries. Loopback,
+// private, link-local (including cloud metadata), multicast and unspecified
+// addresses are internal.
+func PublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// WebhookDialControl is a net.Dialer Control refusing connections to internal
+// addresses. It runs after name resolution, so host names resolving to
+// internal addresses are refused as well.
+func WebhookDialControl(_ string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid webhook address %s: %w", address, err)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !PublicIP(ip) {
+		return fmt.Errorf("webhook address %s is not public", host)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Log encodings supported by the LogFormat setting.
+const (
+	FormatConsole string = "console"
+	FormatJSON    string = "json"
+)
+
+const requestIDMaxLength int = 128
+
+type ctxKey struct{}
+
+// New builds the service logger writing in the given format. The level can
+// be changed while the logger is in use.
+func New(format string, level zap.AtomicLevel) (*zap.Logger, error) {
+	var logConfig zap.Config
+	switch format {
+	case FormatConsole, "":
+		logConfig = zap.NewDevelopmentConfig()
+	case FormatJSON:
+		logConfig = zap.NewProductionConfig()
+		logConfig.Sampling = nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+	logConfig.Level = level
+
+	logger, err := logConfig.Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build %s logger: %w", format, err)
+	}
+	return logger, nil
+}
+
+// NewContext returns a copy of ctx carrying the request logger l.
+func NewContext(ctx context.Context, l *zap.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext returns the request logger stored in ctx, or fallback when
+// ctx does not belong to a request.
+func FromContext(ctx context.Context, fallback *zap.Logger) *zap.Logger {
+	if l, ok := ctx.Value(ctxKey{}).(*zap.Logger); ok {
+		return l
+	}
+	return fallback
+}
+
+// With adds fields to the request logger stored in ctx. Contexts without a
+// request logger are returned unchanged.
+func With(ctx context.Context, fields ...zap.Field) context.Context {
+	l, ok := ctx.Value(ctxKey{}).(*zap.Logger)
+	if !ok {
+		return ctx
+	}
+	return NewContext(ctx, l.With(fields...))
+}
+
+// RequestID returns id if it is a usable request ID supplied by a client, or
+// a new random one. Only printable ASCII IDs of a sane length are accepted,
+// so that clients cannot forge log lines.
+func RequestID(id string) string {
+	if id == "" || len(id) > requestIDMaxLength {
+		return uuid.NewString()
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return uuid.NewString()
+		}
+	}
+	return id
+}
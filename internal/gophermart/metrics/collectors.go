@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Scrapes within ordersCacheTTL reuse the last counts, so that frequent
+// scrapes do not scan the orders table each time.
+const (
+	ordersCacheTTL     time.Duration = 15 * time.Second
+	ordersCountTimeout time.Duration = 5 * time.Second
+)
+
+var (
+	poolAcquiredDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", "acquired_conns"),
+		"Connections currently acquired from the pool.", nil, nil)
+	poolIdleDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", "idle_conns"),
+		"Idle connections in the pool.", nil, nil)
+	poolTotalDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", "total_conns"),
+		"Connections in the pool.", nil, nil)
+	poolMaxDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", "max_conns"),
+		"Maximum size of the pool.", nil, nil)
+	poolAcquiresDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", "acquires_total"),
+		"Successful acquires from the pool.", nil, nil)
+	poolEmptyAcquiresDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", "empty_acquires_total"),
+		"Acquires that had to wait for a connection.", nil, nil)
+	poolWaitDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", "acquire_wait_seconds_total"),
+		"Time spent waiting for a connection.", nil, nil)
+	ordersDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "orders"),
+		"Orders by status.", []string{"status"}, nil)
+	ordersErrorDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "orders_scrape_error"),
+		"1 if counting orders by status failed on the last scrape.", nil, nil)
+)
+
+// poolCollector reads pgxpool statistics on every scrape.
+type poolCollector struct {
+	stat func() *pgxpool.Stat
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolAcquiredDesc
+	ch <- poolIdleDesc
+	ch <- poolTotalDesc
+	ch <- poolMaxDesc
+	ch <- poolAcquiresDesc
+	ch <- poolEmptyAcquiresDesc
+	ch <- poolWaitDesc
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stat()
+	ch <- prometheus.MustNewConstMetric(poolAcquiredDesc, prometheus.GaugeValue, float64(s.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(poolIdleDesc, prometheus.GaugeValue, float64(s.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(poolTotalDesc, prometheus.GaugeValue, float64(s.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(poolMaxDesc, prometheus.GaugeValue, float64(s.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquiresDesc, prometheus.CounterValue, float64(s.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquiresDesc, prometheus.CounterValue, float64(s.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolWaitDesc, prometheus.CounterValue, s.AcquireDuration().Seconds())
+}
+
+// ordersCollector counts orders by status, at most once per ordersCacheTTL.
+// Failed counts are not cached.
+type ordersCollector struct {
+	expires time.Time
+	count   func(context.Context) (map[string]int64, error)
+	counts  map[string]int64
+	mu      sync.Mutex
+}
+
+func (c *ordersCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- ordersDesc
+	ch <- ordersErrorDesc
+}
+
+func (c *ordersCollector) Collect(ch chan<- prometheus.Metric) {
+	counts, err := c.cached()
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(ordersErrorDesc, prometheus.GaugeValue, 1)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(ordersErrorDesc, prometheus.GaugeValue, 0)
+	for status, n := range counts {
+		ch <- prometheus.MustNewConstMetric(ordersDesc, prometheus.GaugeValue, float64(n), status)
+	}
+}
+
+func (c *ordersCollector) cached() (map[string]int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Now().Before(c.expires) {
+		return c.counts, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ordersCountTimeout)
+	defer cancel()
+	counts, err := c.count(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.counts, c.expires = counts, time.Now().Add(ordersCacheTTL)
+	return counts, nil
+}
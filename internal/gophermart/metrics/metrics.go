@@ -0,0 +1,175 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace string = "gophermart"
+
+// Metrics holds the Prometheus collectors of the service. All methods are
+// safe to call on a nil *Metrics, which disables instrumentation.
+type Metrics struct {
+	registry         *prometheus.Registry
+	httpRequests     *prometheus.CounterVec
+	httpDuration     *prometheus.HistogramVec
+	queueDepth       prometheus.Gauge
+	ordersClaimed    prometheus.Counter
+	accrualResponses *prometheus.CounterVec
+	accrualBackoffs  prometheus.Counter
+	timeToProcessed  *prometheus.HistogramVec
+	credited         prometheus.Counter
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "HTTP requests by route pattern, method and status.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "HTTP request latency by route pattern, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		queueDepth: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "dispatcher",
+			Name:      "queue_depth",
+			Help:      "Orders claimed by the dispatcher and waiting for an accrual worker.",
+		}),
+		ordersClaimed: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "dispatcher",
+			Name:      "orders_claimed_total",
+			Help:      "Orders claimed for processing by the dispatcher.",
+		}),
+		accrualResponses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "dispatcher",
+			Name:      "accrual_responses_total",
+			Help:      "Responses of the accrual service by status code.",
+		}, []string{"code"}),
+		accrualBackoffs: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "dispatcher",
+			Name:      "accrual_backoffs_total",
+			Help:      "Backoffs after 429 Too Many Requests from the accrual service.",
+		}),
+		timeToProcessed: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "dispatcher",
+			Name:      "time_to_processed_seconds",
+			Help:      "Time from order upload to a terminal status.",
+			Buckets:   prometheus.ExponentialBuckets(1, 4, 10),
+		}, []string{"status"}),
+		credited: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "dispatcher",
+			Name:      "credited_points_total",
+			Help:      "Accrual points credited to users before tier multipliers and bonuses.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.queueDepth,
+		m.ordersClaimed,
+		m.accrualResponses,
+		m.accrualBackoffs,
+		m.timeToProcessed,
+		m.credited,
+	)
+	return m
+}
+
+// Handler serves the registered metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	if m == nil {
+		return http.NotFoundHandler()
+	}
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// RegisterPool exports the statistics of a pgx connection pool.
+func (m *Metrics) RegisterPool(stat func() *pgxpool.Stat) {
+	if m == nil {
+		return
+	}
+	m.registry.MustRegister(&poolCollector{stat: stat})
+}
+
+// RegisterOrders exports the number of orders by status counted by fn. The
+// counts are cached between scrapes and fn gets a context with a timeout.
+func (m *Metrics) RegisterOrders(fn func(context.Context) (map[string]int64, error)) {
+	if m == nil {
+		return
+	}
+	m.registry.MustRegister(&ordersCollector{count: fn})
+}
+
+func (m *Metrics) ObserveHTTP(route string, method string, status int, d time.Duration) {
+	if m == nil {
+		return
+	}
+	code := strconv.Itoa(status)
+	m.httpRequests.WithLabelValues(route, method, code).Inc()
+	m.httpDuration.WithLabelValues(route, method, code).Observe(d.Seconds())
+}
+
+func (m *Metrics) SetQueueDepth(n int) {
+	if m == nil {
+		return
+	}
+	m.queueDepth.Set(float64(n))
+}
+
+func (m *Metrics) OrdersClaimed(n int) {
+	if m == nil {
+		return
+	}
+	m.ordersClaimed.Add(float64(n))
+}
+
+func (m *Metrics) AccrualResponse(code int) {
+	if m == nil {
+		return
+	}
+	m.accrualResponses.WithLabelValues(strconv.Itoa(code)).Inc()
+}
+
+func (m *Metrics) AccrualBackoff() {
+	if m == nil {
+		return
+	}
+	m.accrualBackoffs.Inc()
+}
+
+func (m *Metrics) OrderProcessed(status string, uploaded time.Time) {
+	if m == nil {
+		return
+	}
+	m.timeToProcessed.WithLabelValues(status).Observe(time.Since(uploaded).Seconds())
+}
+
+func (m *Metrics) Credited(points float32) {
+	if m == nil {
+		return
+	}
+	m.credited.Add(float64(points))
+}
@@ -1,15 +1,39 @@
 package models
 
 import (
+	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"go.uber.org/zap"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/metrics"
 )
 
 type Config struct {
 	Logger                *zap.Logger
+	ConfigFile            string
+	PrintConfig           bool
+	LogFormat             string
+	LogLevel              string
+	AtomicLevel           zap.AtomicLevel
+	Metrics               *metrics.Metrics
 	Address               string
+	GRPCAddress           string
+	MetricsAddress        string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSClientCAFile       string
+	ReadHeaderTimeout     time.Duration
+	ReadTimeout           time.Duration
+	WriteTimeout          time.Duration
+	IdleTimeout           time.Duration
+	MaxHeaderBytes        int64
+	TracingExporter       string
+	TracingEndpoint       string
+	TracingSampleRatio    float64
+	TracingInsecure       bool
+	MetricsPublic         bool
 	PostgresDSN           string
 	JWTKey                string
 	AccrualAddress        string
@@ -22,8 +46,62 @@ type Config struct {
 	AccrualWorkers        int64
 	TimeoutServerShutdown time.Duration
 	TimeoutShutdown       time.Duration
+	DrainDelay            time.Duration
+	OrdersBatchSize       int64
+	OrdersBatchMaxBytes   int64
+	OrderMaxLength        int64
+	IdempotencyTTL        time.Duration
+	IdempotencyLease      time.Duration
+	IdempotencyCleanup    time.Duration
+	WebhookInterval       time.Duration
+	WebhookTimeout        time.Duration
+	WebhookBackoff        time.Duration
+	WebhookBackoffMax     time.Duration
+	WebhookMaxAttempts    int64
+	WebhookBatchSize      int64
+	AdminUsers            []string
+	AdjustmentApproval    float32
+	PointsExpiryMonths    int64
+	ExpiryInterval        time.Duration
+	Tiers                 []Tier
+	TierInterval          time.Duration
+	ReferrerBonus         float32
+	RefereeBonus          float32
+	TransferDailyLimit    float32
+	WithdrawalMin         float32
+	WithdrawalMax         float32
+	WithdrawalDaily       float32
+	WithdrawalMonthly     float32
+	WithdrawalCooldown    time.Duration
+	RiskDelay             int64
+	RiskReview            int64
+	RiskBlock             int64
+	RiskRetryAfter        time.Duration
+	HealthTimeout         time.Duration
+}
+
+// Tier is a loyalty tier reached by users whose accruals over the last
+// 12 months are at least Threshold. Credited points are multiplied by
+// Multiplier.
+type Tier struct {
+	Name       string
+	Threshold  float32
+	Multiplier float32
 }
 
+// Errors returned by the service layer that handlers map to status codes.
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrAlreadyDecided    = errors.New("already decided")
+	ErrAlreadyHeld       = errors.New("already held for review")
+	ErrSelfApproval      = errors.New("approval by the same admin")
+	ErrReversalExceeded  = errors.New("reversal exceeds the withdrawn sum")
+	ErrInvalidReferral   = errors.New("invalid referral code")
+	ErrLimitExceeded     = errors.New("limit exceeded")
+	ErrWrongPassword     = errors.New("wrong password")
+)
+
 type Orders []Order
 
 type Order struct {
@@ -34,25 +112,203 @@ type Order struct {
 	Accrual  float32   `json:"accrual,omitempty" db:"accrual"`
 }
 
+// Per-item results of a bulk order upload.
+const (
+	BatchAccepted     string = "accepted"
+	BatchDuplicateOwn string = "duplicate-own"
+	BatchOwnedByOther string = "owned-by-other"
+	BatchInvalidLuhn  string = "invalid-luhn"
+	BatchHeld         string = "held"
+)
+
+type OrderBatchResults []OrderBatchResult
+
+type OrderBatchResult struct {
+	Number string `json:"number"`
+	Status string `json:"status"`
+}
+
+// Types of events published to the order status stream.
+const (
+	OrderEventStatus  string = "order.status"
+	OrderEventAccrual string = "order.accrual"
+)
+
+type OrderEvents []OrderEvent
+
+type OrderEvent struct {
+	Created time.Time `json:"created_at" db:"created_at"`
+	UserID  string    `json:"-" db:"userid"`
+	Number  string    `json:"number" db:"number"`
+	Type    string    `json:"-" db:"type"`
+	Status  string    `json:"status" db:"status"`
+	Accrual float32   `json:"accrual,omitempty" db:"accrual"`
+	ID      int64     `json:"-" db:"id"`
+	Seq     int64     `json:"-" db:"seq"`
+}
+
+// User roles carried in the JWT role claim.
+const (
+	RoleUser  string = "user"
+	RoleAdmin string = "admin"
+)
+
 type Users []User
 
 type User struct {
-	UserID   string  `json:"login"`
-	Password string  `json:"password"`
-	Accrual  float32 `json:"-"`
+	UserID       string  `json:"login"`
+	Password     string  `json:"password"`
+	ReferralCode string  `json:"referral_code,omitempty"`
+	InviteCode   string  `json:"-"`
+	Role         string  `json:"-"`
+	Accrual      float32 `json:"-"`
+	Locked       bool    `json:"-"`
+}
+
+// UserSummaries is the admin view of user accounts.
+type UserSummaries []UserSummary
+
+type UserSummary struct {
+	UserID  string  `json:"login" db:"userid"`
+	Role    string  `json:"role" db:"role"`
+	Accrual float32 `json:"current" db:"accrual"`
+	Locked  bool    `json:"locked" db:"locked"`
 }
 
 type Claims struct {
 	UserID string
+	Role   string
 	jwt.RegisteredClaims
 }
 
+// Actions recorded in the admin audit log.
+const (
+	AuditUsersSearch     string = "users.search"
+	AuditUserOrders      string = "user.orders"
+	AuditUserWithdrawals string = "user.withdrawals"
+	AuditUserBalance     string = "user.balance"
+	AuditUserLock        string = "user.lock"
+	AuditUserUnlock      string = "user.unlock"
+	AuditOrderRecheck    string = "order.recheck"
+	AuditLogView         string = "audit.view"
+	AuditAdjustmentAdd   string = "adjustment.create"
+	AuditAdjustmentOK    string = "adjustment.approve"
+	AuditAdjustmentNOK   string = "adjustment.reject"
+	AuditAdjustmentsView string = "adjustments.view"
+	AuditReversal        string = "withdrawal.reverse"
+	AuditReversalOK      string = "reversal.approve"
+	AuditReversalNOK     string = "reversal.reject"
+	AuditReversalsView   string = "reversals.view"
+	AuditCampaignAdd     string = "campaign.create"
+	AuditCampaignStop    string = "campaign.stop"
+	AuditCampaignsView   string = "campaigns.view"
+	AuditRiskView        string = "risk.view"
+	AuditRiskApprove     string = "risk.approve"
+	AuditRiskReject      string = "risk.reject"
+)
+
+type AuditEntries []AuditEntry
+
+type AuditEntry struct {
+	Created time.Time `json:"created_at" db:"created_at"`
+	Admin   string    `json:"admin" db:"admin"`
+	Action  string    `json:"action" db:"action"`
+	Target  string    `json:"target" db:"target"`
+	ID      int64     `json:"id" db:"id"`
+}
+
 type AccrualResponse struct {
 	Status  string  `json:"status"`
 	Number  string  `json:"order"`
 	Accrual float32 `json:"accrual"`
 }
 
+// Operations scored by the risk engine.
+const (
+	RiskOrderUpload string = "order_upload"
+	RiskWithdrawal  string = "withdrawal"
+)
+
+// Risk engine actions, from the least to the most severe.
+const (
+	RiskAllow  string = "allow"
+	RiskDelay  string = "delay"
+	RiskReview string = "review"
+	RiskBlock  string = "block"
+)
+
+// Statuses of decisions that require review.
+const (
+	RiskOpen     string = "open"
+	RiskApproved string = "approved"
+	RiskRejected string = "rejected"
+)
+
+// RiskSignals describe the recent activity of a user when an operation is
+// scored.
+type RiskSignals struct {
+	Kind            string
+	AccountAge      time.Duration
+	UploadsHour     int64
+	UploadsDay      int64
+	ConflictsDay    int64
+	Invalid         int64
+	WithdrawalsHour int64
+	Amount          float32
+}
+
+type RiskDecisions []RiskDecision
+
+// RiskDecision is the recorded outcome of scoring an operation. Decisions
+// with the review action stay open until an admin approves or rejects
+// them, an approved withdrawal is executed at that point.
+type RiskDecision struct {
+	Created    time.Time  `json:"created_at" db:"created_at"`
+	Reviewed   *time.Time `json:"reviewed_at,omitempty" db:"reviewed_at"`
+	UserID     string     `json:"login" db:"userid"`
+	Kind       string     `json:"kind" db:"kind"`
+	Subject    string     `json:"order" db:"subject"`
+	Action     string     `json:"action" db:"action"`
+	Status     string     `json:"status,omitempty" db:"status"`
+	ReviewedBy string     `json:"reviewed_by,omitempty" db:"reviewed_by"`
+	Reasons    []string   `json:"reasons" db:"reasons"`
+	Amount     float32    `json:"amount,omitempty" db:"amount"`
+	Score      int64      `json:"score" db:"score"`
+	ID         int64      `json:"id" db:"id"`
+}
+
+// Withdrawal limit violations, returned to clients as the problem code.
+const (
+	LimitBelowMinimum string = "withdrawal_below_minimum"
+	LimitAboveMaximum string = "withdrawal_above_maximum"
+	LimitDaily        string = "withdrawal_daily_limit"
+	LimitMonthly      string = "withdrawal_monthly_limit"
+	LimitCooldown     string = "withdrawal_cooldown"
+)
+
+// LimitError is a withdrawal limit violation, it matches ErrLimitExceeded.
+type LimitError struct {
+	Code string
+}
+
+func (e *LimitError) Error() string {
+	return "withdrawal limit violated: " + e.Code
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// WithdrawalStats are the figures withdrawal limits are checked against.
+// SecurityChanged is the time of the latest password change or login from a
+// new IP address, zero if there was none.
+type WithdrawalStats struct {
+	Now             time.Time
+	SecurityChanged time.Time
+	Daily           float32
+	Monthly         float32
+}
+
 type Withdrawals []Withdrawal
 
 type Withdrawal struct {
@@ -60,9 +316,260 @@ type Withdrawal struct {
 	UserID    string    `json:"-" db:"userid"`
 	Number    string    `json:"order" db:"number"`
 	Sum       float32   `json:"sum" db:"sum"`
+	Reversed  float32   `json:"reversed,omitempty" db:"reversed"`
 }
 
+// Reversal statuses. Reversals requested by users are pending until an admin
+// approves or rejects them.
+const (
+	ReversalPending  string = "pending"
+	ReversalApplied  string = "applied"
+	ReversalRejected string = "rejected"
+)
+
+type WithdrawalReversals []WithdrawalReversal
+
+// WithdrawalReversal returns all or part of a withdrawal to the balance.
+// A zero Sum in a request reverses the whole remaining sum.
+type WithdrawalReversal struct {
+	Created   time.Time  `json:"created_at" db:"created_at"`
+	Decided   *time.Time `json:"decided_at,omitempty" db:"decided_at"`
+	UserID    string     `json:"login,omitempty" db:"userid"`
+	Number    string     `json:"order" db:"number"`
+	Reason    string     `json:"reason,omitempty" db:"reason"`
+	Status    string     `json:"status" db:"status"`
+	CreatedBy string     `json:"created_by,omitempty" db:"created_by"`
+	DecidedBy string     `json:"decided_by,omitempty" db:"decided_by"`
+	Sum       float32    `json:"sum" db:"sum"`
+	ID        int64      `json:"id" db:"id"`
+}
+
+// Types of balance history entries.
+const (
+	LedgerAccrual    string = "accrual"
+	LedgerWithdrawal string = "withdrawal"
+	LedgerAdjustment string = "adjustment"
+	LedgerReversal   string = "reversal"
+	LedgerExpiry     string = "expiry"
+	LedgerBonus      string = "bonus"
+	LedgerReferral   string = "referral"
+	LedgerTransfer   string = "transfer"
+)
+
+// Directions of a transfer, used as the reason of transfer ledger entries.
+const (
+	TransferSent     string = "sent"
+	TransferReceived string = "received"
+)
+
+type Transfer struct {
+	Created time.Time `json:"created_at"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Amount  float32   `json:"amount"`
+	ID      int64     `json:"id"`
+}
+
+// Parties of a referral, used as the reason of referral ledger entries.
+const (
+	ReferralReferrer string = "referrer"
+	ReferralReferee  string = "referee"
+)
+
+// ReferralStats is the referral programme summary of a referrer. Invited
+// users are rewarded when their first order is credited.
+type ReferralStats struct {
+	InviteCode string  `json:"invite_code"`
+	Invited    int64   `json:"invited"`
+	Rewarded   int64   `json:"rewarded"`
+	Earned     float32 `json:"earned"`
+}
+
+// LedgerEntries is the balance history of a user. Credits are positive,
+// debits negative.
+type LedgerEntries []LedgerEntry
+
+type LedgerEntry struct {
+	Created time.Time `json:"created_at" db:"created_at"`
+	UserID  string    `json:"-" db:"userid"`
+	Type    string    `json:"type" db:"type"`
+	Number  string    `json:"order,omitempty" db:"number"`
+	Reason  string    `json:"reason,omitempty" db:"reason"`
+	Amount  float32   `json:"amount" db:"amount"`
+	RefID   int64     `json:"-" db:"ref_id"`
+	ID      int64     `json:"id" db:"id"`
+}
+
+// Manual balance adjustment types, statuses and reason codes.
+const (
+	AdjustmentCredit   string = "credit"
+	AdjustmentDebit    string = "debit"
+	AdjustmentPending  string = "pending"
+	AdjustmentApplied  string = "applied"
+	AdjustmentRejected string = "rejected"
+	ReasonGoodwill     string = "goodwill"
+	ReasonFraud        string = "fraud"
+	ReasonCorrection   string = "correction"
+	ReasonOther        string = "other"
+)
+
+type Adjustments []Adjustment
+
+type Adjustment struct {
+	Created   time.Time  `json:"created_at" db:"created_at"`
+	Decided   *time.Time `json:"decided_at,omitempty" db:"decided_at"`
+	UserID    string     `json:"login" db:"userid"`
+	Type      string     `json:"type" db:"type"`
+	Reason    string     `json:"reason" db:"reason"`
+	Note      string     `json:"note" db:"note"`
+	Status    string     `json:"status" db:"status"`
+	CreatedBy string     `json:"created_by" db:"created_by"`
+	DecidedBy string     `json:"decided_by,omitempty" db:"decided_by"`
+	Amount    float32    `json:"amount" db:"amount"`
+	ID        int64      `json:"id" db:"id"`
+}
+
+// Campaign bonus rules.
+const (
+	// CampaignFirstOrder grants Amount for the first credited order of a user.
+	CampaignFirstOrder string = "first_order"
+	// CampaignWeekend multiplies the accrual of orders credited on Saturday
+	// or Sunday by Multiplier, the bonus is the extra part.
+	CampaignWeekend string = "weekend"
+	// CampaignThreshold grants Amount for orders with an accrual of at least
+	// Threshold.
+	CampaignThreshold string = "threshold"
+)
+
+type Campaigns []Campaign
+
+// Campaign grants bonus points for orders credited between Starts and Ends.
+// Zero Budget and UserBudget are unlimited.
+type Campaign struct {
+	Created    time.Time `json:"created_at" db:"created_at"`
+	Starts     time.Time `json:"starts_at" db:"starts_at"`
+	Ends       time.Time `json:"ends_at" db:"ends_at"`
+	Name       string    `json:"name" db:"name"`
+	Rule       string    `json:"rule" db:"rule"`
+	CreatedBy  string    `json:"created_by" db:"created_by"`
+	Amount     float32   `json:"amount,omitempty" db:"amount"`
+	Multiplier float32   `json:"multiplier,omitempty" db:"multiplier"`
+	Threshold  float32   `json:"threshold,omitempty" db:"threshold"`
+	Budget     float32   `json:"budget,omitempty" db:"budget"`
+	UserBudget float32   `json:"user_budget,omitempty" db:"user_budget"`
+	Spent      float32   `json:"spent" db:"spent"`
+	Active     bool      `json:"active" db:"active"`
+	ID         int64     `json:"id" db:"id"`
+}
+
+// Balance of a user. Pending is the provisional accrual of orders still
+// being processed and is not spendable yet, Held is the sum of withdrawals
+// waiting for a risk review.
 type Balance struct {
-	Current   float32 `json:"current"`
-	Withdrawn float32 `json:"withdrawn"`
+	Current       float32           `json:"current"`
+	Withdrawn     float32           `json:"withdrawn"`
+	Pending       float32           `json:"pending,omitempty"`
+	Held          float32           `json:"held,omitempty"`
+	Tier          string            `json:"tier,omitempty"`
+	Expiring      PointsExpirations `json:"expiring,omitempty"`
+	PendingOrders int64             `json:"pending_orders,omitempty"`
+}
+
+// PointsExpirations are the upcoming expirations of accrued points,
+// grouped by day.
+type PointsExpirations []PointsExpiration
+
+type PointsExpiration struct {
+	Expires time.Time `json:"expires_at" db:"expires_at"`
+	Amount  float32   `json:"amount" db:"amount"`
+}
+
+// IdempotencyRecord is a stored response for an Idempotency-Key.
+// Status is zero while the original request is still being processed.
+type IdempotencyRecord struct {
+	Created     time.Time
+	UserID      string
+	Key         string
+	RequestHash string
+	ContentType string
+	Body        []byte
+	Status      int
+}
+
+// Events delivered to user webhooks.
+const (
+	WebhookOrderProcessed   string = "order.processed"
+	WebhookOrderInvalid     string = "order.invalid"
+	WebhookWithdrawalCreate string = "withdrawal.created"
+	WebhookWithdrawalRevert string = "withdrawal.reversed"
+)
+
+type Webhooks []Webhook
+
+type Webhook struct {
+	Created time.Time `json:"created_at" db:"created_at"`
+	UserID  string    `json:"-" db:"userid"`
+	URL     string    `json:"url" db:"url"`
+	Secret  string    `json:"secret,omitempty" db:"secret"`
+	ID      int64     `json:"id" db:"id"`
+}
+
+// WebhookEvent is the JSON payload sent to a webhook.
+type WebhookEvent struct {
+	Occurred time.Time `json:"occurred_at"`
+	Event    string    `json:"event"`
+	Order    string    `json:"order"`
+	Status   string    `json:"status,omitempty"`
+	Accrual  float32   `json:"accrual,omitempty"`
+	Sum      float32   `json:"sum,omitempty"`
+}
+
+// WebhookMessage is an outbox entry claimed for delivery.
+type WebhookMessage struct {
+	Event    string
+	URL      string
+	Secret   string
+	Payload  []byte
+	ID       int64
+	Attempts int64
+}
+
+type WebhookDeliveries []WebhookDelivery
+
+type WebhookDelivery struct {
+	Created    time.Time `json:"created_at" db:"created_at"`
+	Event      string    `json:"event" db:"event"`
+	Error      string    `json:"error,omitempty" db:"error"`
+	OutboxID   int64     `json:"outbox_id" db:"outbox_id"`
+	Attempt    int64     `json:"attempt" db:"attempt"`
+	StatusCode int64     `json:"status_code" db:"status_code"`
+	Duration   int64     `json:"duration_ms" db:"duration_ms"`
+}
+
+// States of a readiness check. Degraded checks are reported but do not make
+// the service unready.
+const (
+	HealthOK       string = "ok"
+	HealthDegraded string = "degraded"
+	HealthFail     string = "fail"
+)
+
+type HealthCheck struct {
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// HealthReport is the readiness of the service with the result of every
+// dependency check by name.
+type HealthReport struct {
+	Checks map[string]HealthCheck `json:"checks"`
+	Ready  bool                   `json:"ready"`
+}
+
+// MigrationStatus is the schema version applied to the DB and the latest
+// version embedded in the binary.
+type MigrationStatus struct {
+	Version uint
+	Latest  uint
+	Dirty   bool
 }
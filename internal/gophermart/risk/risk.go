@@ -0,0 +1,130 @@
+package risk
+
+import (
+	"time"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
+)
+
+// Rule scores one aspect of the signals. It returns the score added and a
+// short reason, or zero and an empty reason if it does not fire.
+type Rule interface {
+	Score(s models.RiskSignals) (int64, string)
+}
+
+// RuleFunc adapts a function to the Rule interface.
+type RuleFunc func(s models.RiskSignals) (int64, string)
+
+func (f RuleFunc) Score(s models.RiskSignals) (int64, string) {
+	return f(s)
+}
+
+// Engine sums the scores of its rules and maps the total to an action.
+// A zero threshold disables the action.
+type Engine struct {
+	rules  []Rule
+	delay  int64
+	review int64
+	block  int64
+}
+
+func NewEngine(c *models.Config, rules ...Rule) *Engine {
+	return &Engine{
+		rules:  rules,
+		delay:  c.RiskDelay,
+		review: c.RiskReview,
+		block:  c.RiskBlock,
+	}
+}
+
+// Enabled reports whether any action other than allow is configured.
+func (e *Engine) Enabled() bool {
+	return e.delay > 0 || e.review > 0 || e.block > 0
+}
+
+func (e *Engine) Evaluate(s models.RiskSignals) models.RiskDecision {
+	d := models.RiskDecision{Kind: s.Kind, Action: models.RiskAllow, Reasons: []string{}}
+	for _, r := range e.rules {
+		score, reason := r.Score(s)
+		if score == 0 {
+			continue
+		}
+		d.Score += score
+		d.Reasons = append(d.Reasons, reason)
+	}
+
+	switch {
+	case e.block > 0 && d.Score >= e.block:
+		d.Action = models.RiskBlock
+	case e.review > 0 && d.Score >= e.review:
+		d.Action = models.RiskReview
+	case e.delay > 0 && d.Score >= e.delay:
+		d.Action = models.RiskDelay
+	}
+	return d
+}
+
+// DefaultRules score upload bursts, orders claimed by other users, invalid
+// orders, new accounts and withdrawal bursts.
+func DefaultRules() []Rule {
+	return []Rule{
+		RuleFunc(uploadRate),
+		RuleFunc(conflictRatio),
+		RuleFunc(invalidOrders),
+		RuleFunc(accountAge),
+		RuleFunc(withdrawalRate),
+	}
+}
+
+func uploadRate(s models.RiskSignals) (int64, string) {
+	if s.Kind != models.RiskOrderUpload {
+		return 0, ""
+	}
+	switch {
+	case s.UploadsHour >= 30:
+		return 50, "upload_burst"
+	case s.UploadsHour >= 10:
+		return 20, "upload_rate"
+	}
+	return 0, ""
+}
+
+// conflictRatio fires when a large share of recent uploads were orders of
+// other users, the pattern of probing sequential numbers.
+func conflictRatio(s models.RiskSignals) (int64, string) {
+	attempts := s.UploadsDay + s.ConflictsDay
+	if s.ConflictsDay < 3 || attempts == 0 {
+		return 0, ""
+	}
+	switch ratio := float64(s.ConflictsDay) / float64(attempts); {
+	case ratio >= 0.5:
+		return 60, "conflict_ratio"
+	case ratio >= 0.2:
+		return 30, "conflicts"
+	}
+	return 0, ""
+}
+
+func invalidOrders(s models.RiskSignals) (int64, string) {
+	if s.Invalid >= 5 {
+		return 20, "invalid_orders"
+	}
+	return 0, ""
+}
+
+func accountAge(s models.RiskSignals) (int64, string) {
+	switch {
+	case s.Kind == models.RiskWithdrawal && s.AccountAge < time.Hour:
+		return 40, "new_account"
+	case s.AccountAge < 24*time.Hour:
+		return 10, "new_account"
+	}
+	return 0, ""
+}
+
+func withdrawalRate(s models.RiskSignals) (int64, string) {
+	if s.Kind == models.RiskWithdrawal && s.WithdrawalsHour >= 3 {
+		return 40, "withdrawal_burst"
+	}
+	return 0, ""
+}
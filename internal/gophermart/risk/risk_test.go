@@ -0,0 +1,61 @@
+package risk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
+)
+
+func TestEngine(t *testing.T) {
+	cfg := &models.Config{RiskDelay: 40, RiskReview: 60, RiskBlock: 90}
+	e := NewEngine(cfg, DefaultRules()...)
+	old := 30 * 24 * time.Hour
+
+	testCases := []struct {
+		name    string
+		action  string
+		reasons []string
+		s       models.RiskSignals
+	}{
+		{
+			name:    "#regular_upload",
+			s:       models.RiskSignals{Kind: models.RiskOrderUpload, AccountAge: old, UploadsHour: 2, UploadsDay: 5},
+			action:  models.RiskAllow,
+			reasons: []string{},
+		},
+		{
+			name: "#sequential_probe",
+			s: models.RiskSignals{Kind: models.RiskOrderUpload, AccountAge: old, UploadsHour: 12, UploadsDay: 12,
+				ConflictsDay: 20},
+			action:  models.RiskReview,
+			reasons: []string{"upload_rate", "conflict_ratio"},
+		},
+		{
+			name: "#bot_burst",
+			s: models.RiskSignals{Kind: models.RiskOrderUpload, AccountAge: time.Hour, UploadsHour: 40, UploadsDay: 40,
+				ConflictsDay: 40},
+			action:  models.RiskBlock,
+			reasons: []string{"upload_burst", "conflict_ratio", "new_account"},
+		},
+		{
+			name:    "#withdrawal_after_signup",
+			s:       models.RiskSignals{Kind: models.RiskWithdrawal, AccountAge: time.Minute, Amount: 100},
+			action:  models.RiskDelay,
+			reasons: []string{"new_account"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := e.Evaluate(tc.s)
+			assert.Equal(t, tc.action, d.Action)
+			assert.Equal(t, tc.reasons, d.Reasons)
+			assert.Equal(t, tc.s.Kind, d.Kind)
+		})
+	}
+
+	assert.False(t, NewEngine(&models.Config{}, DefaultRules()...).Enabled())
+}
@@ -0,0 +1,589 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/helpers"
+	"github.com/vkupriya/go-gophermart/internal/gophermart/logging"
+	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
+	mw "github.com/vkupriya/go-gophermart/internal/gophermart/server/middleware"
+)
+
+const (
+	adminDefaultLimit int64 = 100
+	adminMaxLimit     int64 = 1000
+)
+
+// pagination reads the limit and offset query parameters.
+func pagination(r *http.Request) (int64, int64, bool) {
+	limit, offset := adminDefaultLimit, int64(0)
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 || n > adminMaxLimit {
+			return 0, 0, false
+		}
+		limit = n
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, false
+		}
+		offset = n
+	}
+	return limit, offset, true
+}
+
+func (gr *GophermartHandler) writeJSON(rw http.ResponseWriter, status int, v any) {
+	logger := gr.logger
+
+	body, err := json.Marshal(v)
+	if err != nil {
+		logger.Sugar().Error("failed to marshal response", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+
+	if _, err := rw.Write(body); err != nil {
+		logger.Sugar().Error("failed to write response", zap.Error(err))
+		return
+	}
+}
+
+// problem is an RFC 9457 problem details body. Code is a stable machine
+// readable reason that clients can switch on.
+type problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Code   string `json:"code"`
+	Status int    `json:"status"`
+}
+
+func (gr *GophermartHandler) writeProblem(rw http.ResponseWriter, status int, code string, title string) {
+	logger := gr.logger
+
+	body, err := json.Marshal(problem{Type: "about:blank", Title: title, Code: code, Status: status})
+	if err != nil {
+		logger.Sugar().Error("failed to marshal problem", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/problem+json")
+	rw.WriteHeader(status)
+
+	if _, err := rw.Write(body); err != nil {
+		logger.Sugar().Error("failed to write problem", zap.Error(err))
+		return
+	}
+}
+
+// clientIP returns the host part of the request remote address.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (gr *GophermartHandler) AdminUsersGet(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	admin, ok := r.Context().Value(mw.CtxKey{}).(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	limit, offset, ok := pagination(r)
+	if !ok {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	users, err := gr.service.AdminUsersSearch(r.Context(), admin, r.URL.Query().Get("q"), limit, offset)
+	if err != nil {
+		logger.Sugar().Error("failed to search users", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	gr.writeJSON(rw, http.StatusOK, users)
+}
+
+func (gr *GophermartHandler) AdminOrdersGet(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	admin, ok := r.Context().Value(mw.CtxKey{}).(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	orders, err := gr.service.AdminOrdersGet(r.Context(), admin, chi.URLParam(r, "login"))
+	if err != nil {
+		logger.Sugar().Error("failed to get user orders", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	gr.writeJSON(rw, http.StatusOK, orders)
+}
+
+func (gr *GophermartHandler) AdminWithdrawalsGet(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	admin, ok := r.Context().Value(mw.CtxKey{}).(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w, err := gr.service.AdminWithdrawalsGet(r.Context(), admin, chi.URLParam(r, "login"))
+	if err != nil {
+		logger.Sugar().Error("failed to get user withdrawals", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	gr.writeJSON(rw, http.StatusOK, w)
+}
+
+func (gr *GophermartHandler) AdminBalanceGet(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	admin, ok := r.Context().Value(mw.CtxKey{}).(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	bal, err := gr.service.AdminBalanceGet(r.Context(), admin, chi.URLParam(r, "login"))
+	if err != nil {
+		logger.Sugar().Error("failed to get user balance", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	gr.writeJSON(rw, http.StatusOK, bal)
+}
+
+func (gr *GophermartHandler) AdminUserLock(rw http.ResponseWriter, r *http.Request) {
+	gr.adminUserLockSet(rw, r, true)
+}
+
+func (gr *GophermartHandler) AdminUserUnlock(rw http.ResponseWriter, r *http.Request) {
+	gr.adminUserLockSet(rw, r, false)
+}
+
+func (gr *GophermartHandler) adminUserLockSet(rw http.ResponseWriter, r *http.Request, locked bool) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	admin, ok := r.Context().Value(mw.CtxKey{}).(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	found, err := gr.service.AdminUserLock(r.Context(), admin, chi.URLParam(r, "login"), locked)
+	if err != nil {
+		logger.Sugar().Error("failed to update user lock", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (gr *GophermartHandler) AdminOrderRecheck(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	admin, ok := r.Context().Value(mw.CtxKey{}).(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	order, err := gr.service.AdminOrderRecheck(r.Context(), admin, chi.URLParam(r, "number"))
+	if err != nil {
+		logger.Sugar().Error("failed to recheck order", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	switch {
+	case order.UserID == "":
+		rw.WriteHeader(http.StatusNotFound)
+	case order.Status == "PROCESSED":
+		rw.WriteHeader(http.StatusConflict)
+	default:
+		gr.writeJSON(rw, http.StatusAccepted, order)
+	}
+}
+
+func (gr *GophermartHandler) AdminAuditGet(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	admin, ok := r.Context().Value(mw.CtxKey{}).(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	limit, offset, ok := pagination(r)
+	if !ok {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	entries, err := gr.service.AdminAuditGet(r.Context(), admin, limit, offset)
+	if err != nil {
+		logger.Sugar().Error("failed to get admin audit", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	gr.writeJSON(rw, http.StatusOK, entries)
+}
+
+// errorStatus maps service errors to HTTP status codes.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrInsufficientFunds):
+		return http.StatusPaymentRequired
+	case errors.Is(err, models.ErrAlreadyDecided):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrSelfApproval):
+		return http.StatusForbidden
+	case errors.Is(err, models.ErrWrongPassword):
+		return http.StatusUnauthorized
+	case errors.Is(err, models.ErrReversalExceeded), errors.Is(err, models.ErrLimitExceeded):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (gr *GophermartHandler) AdminAdjustmentAdd(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	var a models.Adjustment
+	admin, ok := r.Context().Value(mw.CtxKey{}).(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&a); err != nil {
+		logger.Sugar().Error("cannot decode request JSON body")
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if a.Amount <= 0 {
+		logger.Sugar().Errorf("incorrect adjustment amount %v", a.Amount)
+		rw.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+	a.UserID = chi.URLParam(r, "login")
+
+	a, err := gr.service.AdminAdjustmentAdd(r.Context(), admin, a)
+	if err != nil {
+		logger.Sugar().Error("failed to create adjustment", zap.Error(err))
+		rw.WriteHeader(errorStatus(err))
+		return
+	}
+	gr.writeJSON(rw, http.StatusCreated, a)
+}
+
+func (gr *GophermartHandler) AdminAdjustmentsGet(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	admin, ok := r.Context().Value(mw.CtxKey{}).(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	limit, offset, ok := pagination(r)
+	if !ok {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	adjustments, err := gr.service.AdminAdjustmentsGet(r.Context(), admin, r.URL.Query().Get("status"), limit, offset)
+	if err != nil {
+		logger.Sugar().Error("failed to get adjustments", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	gr.writeJSON(rw, http.StatusOK, adjustments)
+}
+
+func (gr *GophermartHandler) AdminAdjustmentApprove(rw http.ResponseWriter, r *http.Request) {
+	gr.adminAdjustmentDecide(rw, r, true)
+}
+
+func (gr *GophermartHandler) AdminAdjustmentReject(rw http.ResponseWriter, r *http.Request) {
+	gr.adminAdjustmentDecide(rw, r, false)
+}
+
+func (gr *GophermartHandler) adminAdjustmentDecide(rw http.ResponseWriter, r *http.Request, approve bool) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	admin, ok := r.Context().Value(mw.CtxKey{}).(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		logger.Sugar().Errorf("incorrect adjustment id %s", chi.URLParam(r, "id"))
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	a, err := gr.service.AdminAdjustmentDecide(r.Context(), admin, id, approve)
+	if err != nil {
+		logger.Sugar().Error("failed to decide adjustment", zap.Error(err))
+		rw.WriteHeader(errorStatus(err))
+		return
+	}
+	gr.writeJSON(rw, http.StatusOK, a)
+}
+
+func (gr *GophermartHandler) AdminCampaignAdd(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	var cmp models.Campaign
+	admin, ok := r.Context().Value(mw.CtxKey{}).(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&cmp); err != nil {
+		logger.Sugar().Error("cannot decode request JSON body")
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !helpers.CampaignValid(cmp) {
+		logger.Sugar().Errorf("incorrect %s campaign %s", cmp.Rule, cmp.Name)
+		rw.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+
+	cmp, err := gr.service.AdminCampaignAdd(r.Context(), admin, cmp)
+	if err != nil {
+		logger.Sugar().Error("failed to create campaign", zap.Error(err))
+		rw.WriteHeader(errorStatus(err))
+		return
+	}
+	gr.writeJSON(rw, http.StatusCreated, cmp)
+}
+
+func (gr *GophermartHandler) AdminCampaignsGet(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	admin, ok := r.Context().Value(mw.CtxKey{}).(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	limit, offset, ok := pagination(r)
+	if !ok {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	campaigns, err := gr.service.AdminCampaignsGet(r.Context(), admin, limit, offset)
+	if err != nil {
+		logger.Sugar().Error("failed to get campaigns", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	gr.writeJSON(rw, http.StatusOK, campaigns)
+}
+
+func (gr *GophermartHandler) AdminCampaignStop(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	admin, ok := r.Context().Value(mw.CtxKey{}).(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		logger.Sugar().Errorf("incorrect campaign id %s", chi.URLParam(r, "id"))
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	cmp, err := gr.service.AdminCampaignStop(r.Context(), admin, id)
+	if err != nil {
+		logger.Sugar().Error("failed to stop campaign", zap.Error(err))
+		rw.WriteHeader(errorStatus(err))
+		return
+	}
+	gr.writeJSON(rw, http.StatusOK, cmp)
+}
+
+func (gr *GophermartHandler) AdminWithdrawalReverse(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	admin, ok := r.Context().Value(mw.CtxKey{}).(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rev, err := decodeReversal(r)
+	if err != nil {
+		logger.Sugar().Error(zap.Error(err))
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	rev, err = gr.service.AdminWithdrawalReverse(r.Context(), admin, rev)
+	if err != nil {
+		logger.Sugar().Error("failed to reverse withdrawal", zap.Error(err))
+		rw.WriteHeader(errorStatus(err))
+		return
+	}
+	gr.writeJSON(rw, http.StatusOK, rev)
+}
+
+func (gr *GophermartHandler) AdminReversalsGet(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	admin, ok := r.Context().Value(mw.CtxKey{}).(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	limit, offset, ok := pagination(r)
+	if !ok {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	reversals, err := gr.service.AdminReversalsGet(r.Context(), admin, r.URL.Query().Get("status"), limit, offset)
+	if err != nil {
+		logger.Sugar().Error("failed to get reversals", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	gr.writeJSON(rw, http.StatusOK, reversals)
+}
+
+func (gr *GophermartHandler) AdminReversalApprove(rw http.ResponseWriter, r *http.Request) {
+	gr.adminReversalDecide(rw, r, true)
+}
+
+func (gr *GophermartHandler) AdminReversalReject(rw http.ResponseWriter, r *http.Request) {
+	gr.adminReversalDecide(rw, r, false)
+}
+
+func (gr *GophermartHandler) adminReversalDecide(rw http.ResponseWriter, r *http.Request, approve bool) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	admin, ok := r.Context().Value(mw.CtxKey{}).(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		logger.Sugar().Errorf("incorrect reversal id %s", chi.URLParam(r, "id"))
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	rev, err := gr.service.AdminReversalDecide(r.Context(), admin, id, approve)
+	if err != nil {
+		logger.Sugar().Error("failed to decide reversal", zap.Error(err))
+		rw.WriteHeader(errorStatus(err))
+		return
+	}
+	gr.writeJSON(rw, http.StatusOK, rev)
+}
+
+func (gr *GophermartHandler) AdminRiskDecisionsGet(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	admin, ok := r.Context().Value(mw.CtxKey{}).(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	limit, offset, ok := pagination(r)
+	if !ok {
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	q := r.URL.Query()
+	decisions, err := gr.service.AdminRiskDecisionsGet(r.Context(), admin, q.Get("action"), q.Get("status"), limit, offset)
+	if err != nil {
+		logger.Sugar().Error("failed to get risk decisions", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	gr.writeJSON(rw, http.StatusOK, decisions)
+}
+
+func (gr *GophermartHandler) AdminRiskApprove(rw http.ResponseWriter, r *http.Request) {
+	gr.adminRiskDecide(rw, r, true)
+}
+
+func (gr *GophermartHandler) AdminRiskReject(rw http.ResponseWriter, r *http.Request) {
+	gr.adminRiskDecide(rw, r, false)
+}
+
+func (gr *GophermartHandler) adminRiskDecide(rw http.ResponseWriter, r *http.Request, approve bool) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	admin, ok := r.Context().Value(mw.CtxKey{}).(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		logger.Sugar().Errorf("incorrect risk decision id %s", chi.URLParam(r, "id"))
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	d, err := gr.service.AdminRiskDecide(r.Context(), admin, id, approve)
+	if err != nil {
+		logger.Sugar().Error("failed to decide risk decision", zap.Error(err))
+		var le *models.LimitError
+		if errors.As(err, &le) {
+			gr.writeProblem(rw, http.StatusUnprocessableEntity, le.Code, "withdrawal limit violated")
+			return
+		}
+		rw.WriteHeader(errorStatus(err))
+		return
+	}
+	gr.writeJSON(rw, http.StatusOK, d)
+}
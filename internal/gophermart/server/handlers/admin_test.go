@@ -0,0 +1,584 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/helpers"
+	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
+	mock_handlers "github.com/vkupriya/go-gophermart/internal/gophermart/server/handlers/mocks"
+)
+
+// expectIdempotency lets a request with an Idempotency-Key through the
+// idempotency middleware as a first attempt.
+func expectIdempotency(s *mock_handlers.MockService) {
+	s.EXPECT().IdempotencyReserve(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, rec models.IdempotencyRecord) (models.IdempotencyRecord, bool, error) {
+			return rec, true, nil
+		})
+	s.EXPECT().IdempotencySave(gomock.Any(), gomock.Any()).Return(nil)
+}
+
+func TestAdminRoutes(t *testing.T) {
+	cfg := &models.Config{Logger: zap.NewNop(), JWTKey: "test", JWTTokenTTL: time.Minute}
+	adminToken, err := helpers.CreateJWTString(cfg, "admin01", models.RoleAdmin)
+	require.NoError(t, err)
+	userToken, err := helpers.CreateJWTString(cfg, "user01", models.RoleUser)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		mockSvc        func(*gomock.Controller) *mock_handlers.MockService
+		name           string
+		token          string
+		method         string
+		path           string
+		body           string
+		idempotencyKey string
+		expectedCode   int
+		expectedBody   string
+	}{
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "user01").Return(false, nil)
+				return s
+			},
+			name:         "#users_not_admin_FAIL",
+			token:        userToken,
+			method:       http.MethodGet,
+			path:         "/api/admin/users",
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "user01").Return(true, nil)
+				return s
+			},
+			name:         "#locked_user_FAIL",
+			token:        userToken,
+			method:       http.MethodGet,
+			path:         "/api/user/balance",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "user01").Return(false, fmt.Errorf("user user01: %w", models.ErrNotFound))
+				return s
+			},
+			name:         "#deleted_user_FAIL",
+			token:        userToken,
+			method:       http.MethodGet,
+			path:         "/api/user/balance",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "admin01").Return(false, nil)
+				s.EXPECT().UserRole(gomock.Any(), "admin01").Return(models.RoleUser, nil)
+				return s
+			},
+			name:         "#revoked_admin_FAIL",
+			token:        adminToken,
+			method:       http.MethodGet,
+			path:         "/api/admin/users",
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "admin01").Return(false, nil)
+				s.EXPECT().UserRole(gomock.Any(), "admin01").Return(models.RoleAdmin, nil)
+				s.EXPECT().AdminUsersSearch(gomock.Any(), "admin01", "user", int64(10), int64(0)).Return(models.UserSummaries{
+					{UserID: "user01", Role: models.RoleUser, Accrual: 500},
+				}, nil)
+				return s
+			},
+			name:         "#users_search_OK",
+			token:        adminToken,
+			method:       http.MethodGet,
+			path:         "/api/admin/users?q=user&limit=10",
+			expectedCode: http.StatusOK,
+			expectedBody: `[{"login":"user01","role":"user","current":500,"locked":false}]`,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "admin01").Return(false, nil)
+				s.EXPECT().UserRole(gomock.Any(), "admin01").Return(models.RoleAdmin, nil)
+				return s
+			},
+			name:         "#users_limit_FAIL",
+			token:        adminToken,
+			method:       http.MethodGet,
+			path:         "/api/admin/users?limit=5000",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "admin01").Return(false, nil)
+				s.EXPECT().UserRole(gomock.Any(), "admin01").Return(models.RoleAdmin, nil)
+				s.EXPECT().AdminUserLock(gomock.Any(), "admin01", "user01", true).Return(true, nil)
+				return s
+			},
+			name:         "#user_lock_OK",
+			token:        adminToken,
+			method:       http.MethodPost,
+			path:         "/api/admin/users/user01/lock",
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "admin01").Return(false, nil)
+				s.EXPECT().UserRole(gomock.Any(), "admin01").Return(models.RoleAdmin, nil)
+				s.EXPECT().AdminUserLock(gomock.Any(), "admin01", "nobody", false).Return(false, nil)
+				return s
+			},
+			name:         "#user_unlock_not_found_FAIL",
+			token:        adminToken,
+			method:       http.MethodPost,
+			path:         "/api/admin/users/nobody/unlock",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "admin01").Return(false, nil)
+				s.EXPECT().UserRole(gomock.Any(), "admin01").Return(models.RoleAdmin, nil)
+				s.EXPECT().AdminOrderRecheck(gomock.Any(), "admin01", "2377225624").Return(models.Order{
+					UserID: "user01", Number: "2377225624", Status: "PROCESSED", Accrual: 100,
+				}, nil)
+				return s
+			},
+			name:         "#order_recheck_processed_FAIL",
+			token:        adminToken,
+			method:       http.MethodPost,
+			path:         "/api/admin/orders/2377225624/recheck",
+			expectedCode: http.StatusConflict,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "admin01").Return(false, nil)
+				s.EXPECT().UserRole(gomock.Any(), "admin01").Return(models.RoleAdmin, nil)
+				s.EXPECT().AdminOrderRecheck(gomock.Any(), "admin01", "2377225624").Return(models.Order{
+					UserID: "user01", Number: "2377225624", Status: "NEW",
+				}, nil)
+				return s
+			},
+			name:         "#order_recheck_OK",
+			token:        adminToken,
+			method:       http.MethodPost,
+			path:         "/api/admin/orders/2377225624/recheck",
+			expectedCode: http.StatusAccepted,
+			expectedBody: `{"uploaded_at":"0001-01-01T00:00:00Z","number":"2377225624","status":"NEW"}`,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "admin01").Return(false, nil)
+				s.EXPECT().UserRole(gomock.Any(), "admin01").Return(models.RoleAdmin, nil)
+				s.EXPECT().AdminAdjustmentAdd(gomock.Any(), "admin01", models.Adjustment{
+					UserID: "user01", Type: models.AdjustmentCredit, Amount: 50,
+					Reason: models.ReasonGoodwill, Note: "delayed delivery",
+				}).Return(models.Adjustment{
+					UserID: "user01", Type: models.AdjustmentCredit, Amount: 50, Reason: models.ReasonGoodwill,
+					Note: "delayed delivery", Status: models.AdjustmentPending, CreatedBy: "admin01", ID: 7,
+				}, nil)
+				return s
+			},
+			name:         "#adjustment_add_OK",
+			token:        adminToken,
+			method:       http.MethodPost,
+			path:         "/api/admin/users/user01/adjustments",
+			body:         `{"type":"credit","amount":50,"reason":"goodwill","note":"delayed delivery"}`,
+			expectedCode: http.StatusCreated,
+			expectedBody: `{"created_at":"0001-01-01T00:00:00Z","login":"user01","type":"credit","reason":"goodwill",
+				"note":"delayed delivery","status":"pending","created_by":"admin01","amount":50,"id":7}`,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "admin01").Return(false, nil)
+				s.EXPECT().UserRole(gomock.Any(), "admin01").Return(models.RoleAdmin, nil)
+				return s
+			},
+			name:         "#adjustment_add_no_note_FAIL",
+			token:        adminToken,
+			method:       http.MethodPost,
+			path:         "/api/admin/users/user01/adjustments",
+			body:         `{"type":"debit","amount":50,"reason":"fraud"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "admin01").Return(false, nil)
+				s.EXPECT().UserRole(gomock.Any(), "admin01").Return(models.RoleAdmin, nil)
+				s.EXPECT().AdminAdjustmentAdd(gomock.Any(), "admin01", gomock.Any()).Return(models.Adjustment{},
+					fmt.Errorf("failed to create adjustment: %w", models.ErrInsufficientFunds))
+				return s
+			},
+			name:         "#adjustment_debit_insufficient_FAIL",
+			token:        adminToken,
+			method:       http.MethodPost,
+			path:         "/api/admin/users/user01/adjustments",
+			body:         `{"type":"debit","amount":5000,"reason":"fraud","note":"chargeback"}`,
+			expectedCode: http.StatusPaymentRequired,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "admin01").Return(false, nil)
+				s.EXPECT().UserRole(gomock.Any(), "admin01").Return(models.RoleAdmin, nil)
+				s.EXPECT().AdminAdjustmentDecide(gomock.Any(), "admin01", int64(7), true).Return(models.Adjustment{},
+					fmt.Errorf("failed to decide adjustment 7: %w", models.ErrSelfApproval))
+				return s
+			},
+			name:         "#adjustment_self_approve_FAIL",
+			token:        adminToken,
+			method:       http.MethodPost,
+			path:         "/api/admin/adjustments/7/approve",
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "user01").Return(false, nil)
+				s.EXPECT().BalanceHistoryGet(gomock.Any(), "user01").Return(models.LedgerEntries{
+					{Type: models.LedgerAccrual, Number: "2377225624", Amount: 100, ID: 1},
+					{Type: models.LedgerAdjustment, Reason: models.ReasonFraud, Amount: -40, ID: 2},
+				}, nil)
+				return s
+			},
+			name:         "#balance_history_OK",
+			token:        userToken,
+			method:       http.MethodGet,
+			path:         "/api/user/balance/history",
+			expectedCode: http.StatusOK,
+			expectedBody: `[{"created_at":"0001-01-01T00:00:00Z","type":"accrual","order":"2377225624","amount":100,"id":1},
+				{"created_at":"0001-01-01T00:00:00Z","type":"adjustment","reason":"fraud","amount":-40,"id":2}]`,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "user01").Return(false, nil)
+				expectIdempotency(s)
+				rev := models.WithdrawalReversal{UserID: "user01", Number: "2377225624", Sum: 20}
+				s.EXPECT().WithdrawalReverse(gomock.Any(), rev).Return(models.WithdrawalReversal{
+					UserID: "user01", Number: "2377225624", Status: models.ReversalPending, CreatedBy: "user01",
+					Sum: 20, ID: 3,
+				}, nil)
+				return s
+			},
+			name:           "#reversal_request_OK",
+			token:          userToken,
+			method:         http.MethodPost,
+			path:           "/api/user/withdrawals/2377225624/reversal",
+			body:           `{"sum":20}`,
+			idempotencyKey: "refund-1",
+			expectedCode:   http.StatusAccepted,
+			expectedBody: `{"created_at":"0001-01-01T00:00:00Z","login":"user01","order":"2377225624",
+				"status":"pending","created_by":"user01","sum":20,"id":3}`,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "user01").Return(false, nil)
+				return s
+			},
+			name:         "#reversal_no_idempotency_key_FAIL",
+			token:        userToken,
+			method:       http.MethodPost,
+			path:         "/api/user/withdrawals/2377225624/reversal",
+			body:         `{"sum":20}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "user01").Return(false, nil)
+				expectIdempotency(s)
+				s.EXPECT().WithdrawalReverse(gomock.Any(), models.WithdrawalReversal{UserID: "user01", Number: "2377225624"}).
+					Return(models.WithdrawalReversal{}, fmt.Errorf("failed to reverse: %w", models.ErrReversalExceeded))
+				return s
+			},
+			name:           "#reversal_full_already_reversed_FAIL",
+			token:          userToken,
+			method:         http.MethodPost,
+			path:           "/api/user/withdrawals/2377225624/reversal",
+			idempotencyKey: "refund-2",
+			expectedCode:   http.StatusUnprocessableEntity,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "admin01").Return(false, nil)
+				s.EXPECT().UserRole(gomock.Any(), "admin01").Return(models.RoleAdmin, nil)
+				expectIdempotency(s)
+				s.EXPECT().AdminWithdrawalReverse(gomock.Any(), "admin01", models.WithdrawalReversal{
+					Number: "2377225624", Reason: "order cancelled",
+				}).Return(models.WithdrawalReversal{}, fmt.Errorf("failed to reverse: %w", models.ErrNotFound))
+				return s
+			},
+			name:           "#admin_reversal_not_found_FAIL",
+			token:          adminToken,
+			method:         http.MethodPost,
+			path:           "/api/admin/withdrawals/2377225624/reversal",
+			body:           `{"reason":"order cancelled"}`,
+			idempotencyKey: "refund-3",
+			expectedCode:   http.StatusNotFound,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "admin01").Return(false, nil)
+				s.EXPECT().UserRole(gomock.Any(), "admin01").Return(models.RoleAdmin, nil)
+				return s
+			},
+			name:         "#admin_reversal_no_idempotency_key_FAIL",
+			token:        adminToken,
+			method:       http.MethodPost,
+			path:         "/api/admin/withdrawals/2377225624/reversal",
+			body:         `{"reason":"order cancelled"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "admin01").Return(false, nil)
+				s.EXPECT().UserRole(gomock.Any(), "admin01").Return(models.RoleAdmin, nil)
+				s.EXPECT().AdminReversalsGet(gomock.Any(), "admin01", models.ReversalPending, int64(100), int64(0)).
+					Return(models.WithdrawalReversals{{
+						UserID: "user01", Number: "2377225624", Status: models.ReversalPending, CreatedBy: "user01",
+						Sum: 20, ID: 3,
+					}}, nil)
+				return s
+			},
+			name:         "#reversals_pending_OK",
+			token:        adminToken,
+			method:       http.MethodGet,
+			path:         "/api/admin/reversals?status=pending",
+			expectedCode: http.StatusOK,
+			expectedBody: `[{"created_at":"0001-01-01T00:00:00Z","login":"user01","order":"2377225624",
+				"status":"pending","created_by":"user01","sum":20,"id":3}]`,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "admin01").Return(false, nil)
+				s.EXPECT().UserRole(gomock.Any(), "admin01").Return(models.RoleAdmin, nil)
+				s.EXPECT().AdminReversalDecide(gomock.Any(), "admin01", int64(3), true).Return(models.WithdrawalReversal{
+					UserID: "user01", Number: "2377225624", Status: models.ReversalApplied, CreatedBy: "user01",
+					DecidedBy: "admin01", Sum: 20, ID: 3,
+				}, nil)
+				return s
+			},
+			name:         "#reversal_approve_OK",
+			token:        adminToken,
+			method:       http.MethodPost,
+			path:         "/api/admin/reversals/3/approve",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"created_at":"0001-01-01T00:00:00Z","login":"user01","order":"2377225624",
+				"status":"applied","created_by":"user01","decided_by":"admin01","sum":20,"id":3}`,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "admin01").Return(false, nil)
+				s.EXPECT().UserRole(gomock.Any(), "admin01").Return(models.RoleAdmin, nil)
+				s.EXPECT().AdminReversalDecide(gomock.Any(), "admin01", int64(3), false).Return(models.WithdrawalReversal{},
+					fmt.Errorf("failed to decide reversal 3: %w", models.ErrAlreadyDecided))
+				return s
+			},
+			name:         "#reversal_reject_decided_FAIL",
+			token:        adminToken,
+			method:       http.MethodPost,
+			path:         "/api/admin/reversals/3/reject",
+			expectedCode: http.StatusConflict,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "user01").Return(false, nil)
+				return s
+			},
+			name:         "#reversal_approve_user_FAIL",
+			token:        userToken,
+			method:       http.MethodPost,
+			path:         "/api/admin/reversals/3/approve",
+			expectedCode: http.StatusForbidden,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "admin01").Return(false, nil)
+				s.EXPECT().UserRole(gomock.Any(), "admin01").Return(models.RoleAdmin, nil)
+				s.EXPECT().AdminCampaignAdd(gomock.Any(), "admin01", models.Campaign{
+					Name: "summer", Rule: models.CampaignWeekend, Multiplier: 2, Budget: 10000,
+					Starts: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), Ends: time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC),
+				}).Return(models.Campaign{
+					Name: "summer", Rule: models.CampaignWeekend, Multiplier: 2, Budget: 10000, CreatedBy: "admin01",
+					Starts: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), Ends: time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC),
+					Active: true, ID: 4,
+				}, nil)
+				return s
+			},
+			name:   "#campaign_add_OK",
+			token:  adminToken,
+			method: http.MethodPost,
+			path:   "/api/admin/campaigns",
+			body: `{"name":"summer","rule":"weekend","multiplier":2,"budget":10000,
+				"starts_at":"2024-06-01T00:00:00Z","ends_at":"2024-09-01T00:00:00Z"}`,
+			expectedCode: http.StatusCreated,
+			expectedBody: `{"created_at":"0001-01-01T00:00:00Z","starts_at":"2024-06-01T00:00:00Z",
+				"ends_at":"2024-09-01T00:00:00Z","name":"summer","rule":"weekend","created_by":"admin01",
+				"multiplier":2,"budget":10000,"spent":0,"active":true,"id":4}`,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "admin01").Return(false, nil)
+				s.EXPECT().UserRole(gomock.Any(), "admin01").Return(models.RoleAdmin, nil)
+				return s
+			},
+			name:   "#campaign_add_no_amount_FAIL",
+			token:  adminToken,
+			method: http.MethodPost,
+			path:   "/api/admin/campaigns",
+			body: `{"name":"welcome","rule":"first_order",
+				"starts_at":"2024-06-01T00:00:00Z","ends_at":"2024-09-01T00:00:00Z"}`,
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "admin01").Return(false, nil)
+				s.EXPECT().UserRole(gomock.Any(), "admin01").Return(models.RoleAdmin, nil)
+				s.EXPECT().AdminCampaignStop(gomock.Any(), "admin01", int64(9)).Return(models.Campaign{},
+					fmt.Errorf("failed to stop campaign 9: %w", models.ErrNotFound))
+				return s
+			},
+			name:         "#campaign_stop_not_found_FAIL",
+			token:        adminToken,
+			method:       http.MethodPost,
+			path:         "/api/admin/campaigns/9/stop",
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "admin01").Return(false, nil)
+				s.EXPECT().UserRole(gomock.Any(), "admin01").Return(models.RoleAdmin, nil)
+				s.EXPECT().AdminRiskDecisionsGet(gomock.Any(), "admin01", models.RiskReview, models.RiskOpen, int64(10), int64(0)).
+					Return(models.RiskDecisions{{
+						UserID: "user01", Kind: models.RiskWithdrawal, Subject: "12345678903",
+						Action: models.RiskReview, Status: models.RiskOpen,
+						Reasons: []string{"withdrawal_burst"}, Amount: 250, Score: 60, ID: 3,
+					}}, nil)
+				return s
+			},
+			name:         "#risk_decisions_get_OK",
+			token:        adminToken,
+			method:       http.MethodGet,
+			path:         "/api/admin/risk/decisions?action=review&status=open&limit=10",
+			expectedCode: http.StatusOK,
+			expectedBody: `[{"created_at":"0001-01-01T00:00:00Z","login":"user01","kind":"withdrawal",` +
+				`"order":"12345678903","action":"review","status":"open","reasons":["withdrawal_burst"],` +
+				`"amount":250,"score":60,"id":3}]`,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "admin01").Return(false, nil)
+				s.EXPECT().UserRole(gomock.Any(), "admin01").Return(models.RoleAdmin, nil)
+				s.EXPECT().AdminRiskDecide(gomock.Any(), "admin01", int64(3), true).Return(models.RiskDecision{},
+					fmt.Errorf("failed to decide risk decision: %w", models.ErrAlreadyDecided))
+				return s
+			},
+			name:         "#risk_decision_approve_decided_FAIL",
+			token:        adminToken,
+			method:       http.MethodPost,
+			path:         "/api/admin/risk/decisions/3/approve",
+			expectedCode: http.StatusConflict,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "admin01").Return(false, nil)
+				s.EXPECT().UserRole(gomock.Any(), "admin01").Return(models.RoleAdmin, nil)
+				s.EXPECT().AdminRiskDecide(gomock.Any(), "admin01", int64(3), true).Return(models.RiskDecision{},
+					fmt.Errorf("failed to decide risk decision: %w", &models.LimitError{Code: models.LimitDaily}))
+				return s
+			},
+			name:         "#risk_decision_approve_limit_FAIL",
+			token:        adminToken,
+			method:       http.MethodPost,
+			path:         "/api/admin/risk/decisions/3/approve",
+			expectedCode: http.StatusUnprocessableEntity,
+			expectedBody: `{"type":"about:blank","title":"withdrawal limit violated","code":"withdrawal_daily_limit",` +
+				`"status":422}`,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserLocked(gomock.Any(), "user01").Return(false, nil)
+				return s
+			},
+			name:         "#risk_decisions_user_FAIL",
+			token:        userToken,
+			method:       http.MethodGet,
+			path:         "/api/admin/risk/decisions",
+			expectedCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			svc := tc.mockSvc(ctrl)
+			r, err := NewGophermartRouter(cfg, NewGophermartHandler(svc, cfg))
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+			if tc.idempotencyKey != "" {
+				req.Header.Set("Idempotency-Key", tc.idempotencyKey)
+			}
+			if tc.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer func() {
+				if err := res.Body.Close(); err != nil {
+					assert.Error(t, err)
+				}
+			}()
+			body, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedCode, res.StatusCode, "Response code didn't match expected")
+			if tc.expectedBody != "" {
+				assert.JSONEq(t, tc.expectedBody, string(body))
+			}
+		})
+	}
+}
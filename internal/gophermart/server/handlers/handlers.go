@@ -1,16 +1,23 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/vkupriya/go-gophermart/internal/gophermart/helpers"
+	"github.com/vkupriya/go-gophermart/internal/gophermart/logging"
 	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
 	mw "github.com/vkupriya/go-gophermart/internal/gophermart/server/middleware"
+	"go.opentelemetry.io/otel"
 	"go.uber.org/zap"
 )
 
@@ -19,56 +26,187 @@ const (
 	errorIncorrectOrderNumber string = "incorrect order number "
 )
 
+const streamKeepAlive time.Duration = 15 * time.Second
+
 type Service interface {
-	UserAdd(user models.User) error
-	UserGet(uid string) (models.User, error)
-	UserLogin(uid string, passwd string) (string, error)
-	OrderAdd(uid string, oid string) error
-	OrdersGet(uid string) (models.Orders, error)
-	OrderGet(oid string) (models.Order, error)
-	AccrualWithdraw(w models.Withdrawal) error
-	WithdrawalsGet(uid string) (models.Withdrawals, error)
-	BalanceGet(uid string) (models.Balance, error)
+	Readiness(ctx context.Context) models.HealthReport
+	UserAdd(ctx context.Context, user models.User) error
+	UserGet(ctx context.Context, uid string) (models.User, error)
+	UserLogin(ctx context.Context, uid string, passwd string, ip string) (string, error)
+	UserPasswordChange(ctx context.Context, uid string, oldPasswd string, newPasswd string) error
+	OrderAdd(ctx context.Context, uid string, oid string) error
+	OrdersAddBatch(ctx context.Context, uid string, oids []string) (models.OrderBatchResults, error)
+	OrdersGet(ctx context.Context, uid string) (models.Orders, error)
+	OrderGet(ctx context.Context, oid string) (models.Order, error)
+	AccrualWithdraw(ctx context.Context, w models.Withdrawal) error
+	WithdrawalsGet(ctx context.Context, uid string) (models.Withdrawals, error)
+	BalanceGet(ctx context.Context, uid string) (models.Balance, error)
+	IdempotencyReserve(ctx context.Context, rec models.IdempotencyRecord) (models.IdempotencyRecord, bool, error)
+	IdempotencySave(ctx context.Context, rec models.IdempotencyRecord) error
+	IdempotencyRelease(ctx context.Context, uid string, key string) error
+	OrderEventsSubscribe(ctx context.Context, uid string, lastID int64) (
+		models.OrderEvents, <-chan models.OrderEvent, func(), error)
+	WebhookAdd(ctx context.Context, wh models.Webhook) (models.Webhook, error)
+	WebhooksGet(ctx context.Context, uid string) (models.Webhooks, error)
+	WebhookDelete(ctx context.Context, uid string, id int64) (bool, error)
+	WebhookDeliveriesGet(ctx context.Context, uid string, id int64) (models.WebhookDeliveries, error)
+	UserLocked(ctx context.Context, uid string) (bool, error)
+	UserRole(ctx context.Context, uid string) (string, error)
+	AdminUsersSearch(ctx context.Context, admin string, query string, limit int64, offset int64) (
+		models.UserSummaries, error)
+	AdminOrdersGet(ctx context.Context, admin string, uid string) (models.Orders, error)
+	AdminWithdrawalsGet(ctx context.Context, admin string, uid string) (models.Withdrawals, error)
+	AdminBalanceGet(ctx context.Context, admin string, uid string) (models.Balance, error)
+	AdminUserLock(ctx context.Context, admin string, uid string, locked bool) (bool, error)
+	AdminOrderRecheck(ctx context.Context, admin string, oid string) (models.Order, error)
+	AdminAuditGet(ctx context.Context, admin string, limit int64, offset int64) (models.AuditEntries, error)
+	BalanceHistoryGet(ctx context.Context, uid string) (models.LedgerEntries, error)
+	ReferralStatsGet(ctx context.Context, uid string) (models.ReferralStats, error)
+	Transfer(ctx context.Context, t models.Transfer) (models.Transfer, error)
+	RiskAssess(ctx context.Context, uid string, kind string, subject string, amount float32) (models.RiskDecision, error)
+	OrderConflicts(ctx context.Context, uid string, oids []string) error
+	AdminRiskDecisionsGet(ctx context.Context, admin string, action string, status string, limit int64, offset int64) (
+		models.RiskDecisions, error)
+	AdminRiskDecide(ctx context.Context, admin string, id int64, approve bool) (models.RiskDecision, error)
+	AdminAdjustmentAdd(ctx context.Context, admin string, a models.Adjustment) (models.Adjustment, error)
+	AdminAdjustmentDecide(ctx context.Context, admin string, id int64, approve bool) (models.Adjustment, error)
+	AdminAdjustmentsGet(ctx context.Context, admin string, status string, limit int64, offset int64) (
+		models.Adjustments, error)
+	AdminCampaignAdd(ctx context.Context, admin string, cmp models.Campaign) (models.Campaign, error)
+	AdminCampaignStop(ctx context.Context, admin string, id int64) (models.Campaign, error)
+	AdminCampaignsGet(ctx context.Context, admin string, limit int64, offset int64) (models.Campaigns, error)
+	WithdrawalReverse(ctx context.Context, r models.WithdrawalReversal) (models.WithdrawalReversal, error)
+	AdminWithdrawalReverse(ctx context.Context, admin string, r models.WithdrawalReversal) (
+		models.WithdrawalReversal, error)
+	AdminReversalDecide(ctx context.Context, admin string, id int64, approve bool) (models.WithdrawalReversal, error)
+	AdminReversalsGet(ctx context.Context, admin string, status string, limit int64, offset int64) (
+		models.WithdrawalReversals, error)
 }
 
 type GophermartHandler struct {
 	service Service
+	config  atomic.Pointer[models.Config]
 	logger  *zap.Logger
 }
 
-func NewGophermartHandler(service Service, logger *zap.Logger) *GophermartHandler {
-	return &GophermartHandler{
+func NewGophermartHandler(service Service, cfg *models.Config) *GophermartHandler {
+	gr := &GophermartHandler{
 		service: service,
-		logger:  logger,
+		logger:  cfg.Logger,
 	}
+	gr.config.Store(cfg)
+	return gr
+}
+
+func (gr *GophermartHandler) cfg() *models.Config {
+	return gr.config.Load()
 }
 
-func NewGophermartRouter(cfg *models.Config, gr *GophermartHandler) chi.Router {
+// Reconfigure applies a reloaded configuration to subsequent requests.
+func (gr *GophermartHandler) Reconfigure(c *models.Config) {
+	gr.config.Store(c)
+}
+
+func NewGophermartRouter(cfg *models.Config, gr *GophermartHandler) (chi.Router, error) {
 	r := chi.NewRouter()
 
-	ma := mw.NewMiddlewareAuth(cfg)
+	spec, err := LoadOpenAPI()
+	if err != nil {
+		return nil, err
+	}
+
+	ma := mw.NewMiddlewareAuth(cfg, gr.service)
 	ml := mw.NewMiddlewareLogger(gr.logger)
 	mg := mw.NewMiddlewareGzip(gr.logger)
 	mr := mw.NewMiddlewareRecovery(gr.logger)
+	mi := mw.NewMiddlewareIdempotency(gr.logger, gr.service)
+	mv := mw.NewMiddlewareOpenAPI(gr.logger, spec)
+	mm := mw.NewMiddlewareMetrics(cfg.Metrics)
+	mt := mw.NewMiddlewareTracing(otel.GetTracerProvider())
+	mq := mw.NewMiddlewareRequestID(gr.logger)
+	mc := mw.NewMiddlewareClientCert(cfg)
+	r.Use(mt.Tracing)
+	r.Use(mm.Metrics)
+	r.Use(mq.RequestID)
 	r.Use(ml.Logging)
 	r.Use(mr.Recovery)
-	r.Post("/api/user/register", gr.UserAdd)
-	r.Post("/api/user/login", gr.UserLogin)
+	r.Get("/api/openapi.json", gr.OpenAPIGet)
+	r.Get("/healthz", gr.Healthz)
+	r.Get("/readyz", gr.Readyz)
+	if cfg.MetricsAddress == "" && cfg.MetricsPublic {
+		r.Method(http.MethodGet, "/metrics", cfg.Metrics.Handler())
+	}
+
+	r.Group(func(r chi.Router) {
+		r.Use(mv.Validate)
+		r.Post("/api/user/register", gr.UserAdd)
+		r.Post("/api/user/login", gr.UserLogin)
+	})
 
 	r.Group(func(r chi.Router) {
 		r.Use(ma.Auth)
 		r.Use(mg.GzipHandler)
+		r.Use(mv.Validate)
+		r.Use(mi.Idempotency)
 		r.Post("/api/user/orders", gr.OrderAdd)
+		r.Post("/api/user/orders/batch", gr.OrdersAddBatch)
 		r.Get("/api/user/orders", gr.OrdersGet)
 		r.Post("/api/user/balance/withdraw", gr.AccrualWithdraw)
+		r.With(mi.Required).Post("/api/user/balance/transfer", gr.Transfer)
 		r.Get("/api/user/withdrawals", gr.WithdrawalsGet)
+		r.With(mi.Required).Post("/api/user/withdrawals/{number}/reversal", gr.WithdrawalReverse)
 		r.Get("/api/user/balance", gr.BalanceGet)
+		r.Get("/api/user/balance/history", gr.BalanceHistoryGet)
+		r.Get("/api/user/referrals", gr.ReferralStatsGet)
+		r.Post("/api/user/password", gr.UserPasswordChange)
+		r.Post("/api/user/webhooks", gr.WebhookAdd)
+		r.Get("/api/user/webhooks", gr.WebhooksGet)
+		r.Delete("/api/user/webhooks/{id}", gr.WebhookDelete)
+		r.Get("/api/user/webhooks/{id}/deliveries", gr.WebhookDeliveriesGet)
 	})
-	return r
+
+	r.Group(func(r chi.Router) {
+		r.Use(mc.ClientCert)
+		r.Use(ma.Auth)
+		r.Use(ma.Admin)
+		r.Use(mg.GzipHandler)
+		r.Use(mv.Validate)
+		r.Use(mi.Idempotency)
+		r.Get("/api/admin/users", gr.AdminUsersGet)
+		r.Get("/api/admin/users/{login}/orders", gr.AdminOrdersGet)
+		r.Get("/api/admin/users/{login}/withdrawals", gr.AdminWithdrawalsGet)
+		r.Get("/api/admin/users/{login}/balance", gr.AdminBalanceGet)
+		r.Post("/api/admin/users/{login}/lock", gr.AdminUserLock)
+		r.Post("/api/admin/users/{login}/unlock", gr.AdminUserUnlock)
+		r.Post("/api/admin/orders/{number}/recheck", gr.AdminOrderRecheck)
+		r.Get("/api/admin/audit", gr.AdminAuditGet)
+		r.Post("/api/admin/users/{login}/adjustments", gr.AdminAdjustmentAdd)
+		r.Get("/api/admin/adjustments", gr.AdminAdjustmentsGet)
+		r.Post("/api/admin/adjustments/{id}/approve", gr.AdminAdjustmentApprove)
+		r.Post("/api/admin/adjustments/{id}/reject", gr.AdminAdjustmentReject)
+		r.Post("/api/admin/campaigns", gr.AdminCampaignAdd)
+		r.Get("/api/admin/campaigns", gr.AdminCampaignsGet)
+		r.Post("/api/admin/campaigns/{id}/stop", gr.AdminCampaignStop)
+		r.Get("/api/admin/risk/decisions", gr.AdminRiskDecisionsGet)
+		r.Post("/api/admin/risk/decisions/{id}/approve", gr.AdminRiskApprove)
+		r.Post("/api/admin/risk/decisions/{id}/reject", gr.AdminRiskReject)
+		r.With(mi.Required).Post("/api/admin/withdrawals/{number}/reversal", gr.AdminWithdrawalReverse)
+		r.Get("/api/admin/reversals", gr.AdminReversalsGet)
+		r.Post("/api/admin/reversals/{id}/approve", gr.AdminReversalApprove)
+		r.Post("/api/admin/reversals/{id}/reject", gr.AdminReversalReject)
+	})
+
+	// event stream is not compressed so that every event is flushed immediately
+	r.Group(func(r chi.Router) {
+		r.Use(ma.Auth)
+		r.Use(mv.Validate)
+		r.Get("/api/user/orders/stream", gr.OrdersStream)
+	})
+	return r, nil
 }
 
 func (gr *GophermartHandler) OrdersGet(rw http.ResponseWriter, r *http.Request) {
-	logger := gr.logger
+	logger := logging.FromContext(r.Context(), gr.logger)
 	v := r.Context().Value(mw.CtxKey{})
 	ctxUname, ok := v.(string)
 	if !ok {
@@ -77,7 +215,7 @@ func (gr *GophermartHandler) OrdersGet(rw http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	resp, err := gr.service.OrdersGet(ctxUname)
+	resp, err := gr.service.OrdersGet(r.Context(), ctxUname)
 	if err != nil {
 		fmt.Println(err)
 	}
@@ -99,7 +237,7 @@ func (gr *GophermartHandler) OrdersGet(rw http.ResponseWriter, r *http.Request)
 }
 
 func (gr *GophermartHandler) UserAdd(rw http.ResponseWriter, r *http.Request) {
-	logger := gr.logger
+	logger := logging.FromContext(r.Context(), gr.logger)
 
 	var user models.User
 
@@ -110,13 +248,17 @@ func (gr *GophermartHandler) UserAdd(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := gr.service.UserAdd(user); err != nil {
+	if err := gr.service.UserAdd(r.Context(), user); err != nil {
 		logger.Sugar().Error(zap.Error(err))
+		if errors.Is(err, models.ErrInvalidReferral) {
+			rw.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
 		rw.WriteHeader(http.StatusConflict)
 		return
 	}
 
-	token, err := gr.service.UserLogin(user.UserID, user.Password)
+	token, err := gr.service.UserLogin(r.Context(), user.UserID, user.Password, clientIP(r))
 	if err != nil || token == "" {
 		fmt.Println(err)
 		logger.Sugar().Errorf("user %s failed to authenticate", user.UserID)
@@ -127,7 +269,7 @@ func (gr *GophermartHandler) UserAdd(rw http.ResponseWriter, r *http.Request) {
 }
 
 func (gr *GophermartHandler) UserLogin(rw http.ResponseWriter, r *http.Request) {
-	logger := gr.logger
+	logger := logging.FromContext(r.Context(), gr.logger)
 
 	var user models.User
 
@@ -138,7 +280,7 @@ func (gr *GophermartHandler) UserLogin(rw http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	token, err := gr.service.UserLogin(user.UserID, user.Password)
+	token, err := gr.service.UserLogin(r.Context(), user.UserID, user.Password, clientIP(r))
 	if err != nil || token == "" {
 		logger.Sugar().Errorf("user %s failed to authenticate", user.UserID)
 		rw.WriteHeader(http.StatusUnauthorized)
@@ -147,8 +289,35 @@ func (gr *GophermartHandler) UserLogin(rw http.ResponseWriter, r *http.Request)
 	rw.Header().Set("Authorization", "Bearer "+token)
 }
 
+func (gr *GophermartHandler) UserPasswordChange(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	var req struct {
+		OldPassword string `json:"old_password"`
+		NewPassword string `json:"new_password"`
+	}
+	ctxUname, ok := r.Context().Value(mw.CtxKey{}).(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&req); err != nil {
+		logger.Sugar().Error("cannot decode request JSON body")
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := gr.service.UserPasswordChange(r.Context(), ctxUname, req.OldPassword, req.NewPassword); err != nil {
+		logger.Sugar().Error("failed to change password", zap.Error(err))
+		rw.WriteHeader(errorStatus(err))
+		return
+	}
+}
+
 func (gr *GophermartHandler) OrderAdd(rw http.ResponseWriter, r *http.Request) {
-	logger := gr.logger
+	logger := logging.FromContext(r.Context(), gr.logger)
 	v := r.Context().Value(mw.CtxKey{})
 	ctxUname, ok := v.(string)
 	if !ok {
@@ -162,15 +331,24 @@ func (gr *GophermartHandler) OrderAdd(rw http.ResponseWriter, r *http.Request) {
 		logger.Sugar().Error("failed to read request body.", zap.Error(err))
 		rw.WriteHeader(http.StatusInternalServerError)
 	}
-	oid := string(b)
-	orderNum, err := strconv.ParseInt(oid, 10, 64)
-	if err != nil || !helpers.ValidOrder(orderNum) {
-		logger.Sugar().Errorf(errorIncorrectOrderNumber, oid)
+	oid, err := helpers.NormalizeOrder(string(b), gr.cfg().OrderMaxLength)
+	if err != nil || !helpers.ValidOrder(oid) {
+		logger.Sugar().Errorf(errorIncorrectOrderNumber, string(b))
 		rw.WriteHeader(http.StatusUnprocessableEntity)
 		return
 	}
 
-	order, err := gr.service.OrderGet(oid)
+	d, err := gr.service.RiskAssess(r.Context(), ctxUname, models.RiskOrderUpload, oid, 0)
+	if err != nil {
+		logger.Sugar().Error("failed to assess order upload risk", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if gr.riskRefused(rw, d) {
+		return
+	}
+
+	order, err := gr.service.OrderGet(r.Context(), oid)
 	if err != nil {
 		logger.Sugar().Error("failed to get order from DB", zap.Error(err))
 		rw.WriteHeader(http.StatusInternalServerError)
@@ -182,11 +360,19 @@ func (gr *GophermartHandler) OrderAdd(rw http.ResponseWriter, r *http.Request) {
 			return
 		} else {
 			logger.Sugar().Errorf("order %s already registered by another user", oid)
+			if err := gr.service.OrderConflicts(r.Context(), ctxUname, []string{oid}); err != nil {
+				logger.Sugar().Error("failed to record order conflict", zap.Error(err))
+			}
 			rw.WriteHeader(http.StatusConflict)
 			return
 		}
 	}
-	if err := gr.service.OrderAdd(ctxUname, oid); err != nil {
+	if d.Action == models.RiskReview {
+		logger.Sugar().Infof("upload of order %s is held for review", oid)
+		rw.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if err := gr.service.OrderAdd(r.Context(), ctxUname, oid); err != nil {
 		logger.Sugar().Error(zap.Error(err))
 		rw.WriteHeader(http.StatusConflict)
 		return
@@ -195,8 +381,139 @@ func (gr *GophermartHandler) OrderAdd(rw http.ResponseWriter, r *http.Request) {
 	rw.WriteHeader(http.StatusAccepted)
 }
 
+// riskRefused writes the response for delayed and blocked operations and
+// reports whether the operation must not proceed.
+func (gr *GophermartHandler) riskRefused(rw http.ResponseWriter, d models.RiskDecision) bool {
+	switch d.Action {
+	case models.RiskDelay:
+		rw.Header().Set("Retry-After", strconv.FormatInt(int64(gr.cfg().RiskRetryAfter.Seconds()), 10))
+		rw.WriteHeader(http.StatusTooManyRequests)
+		return true
+	case models.RiskBlock:
+		rw.WriteHeader(http.StatusForbidden)
+		return true
+	}
+	return false
+}
+
+func (gr *GophermartHandler) OrdersAddBatch(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	v := r.Context().Value(mw.CtxKey{})
+	ctxUname, ok := v.(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	b, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, gr.cfg().OrdersBatchMaxBytes))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			logger.Sugar().Errorf("orders batch exceeds %d bytes", maxErr.Limit)
+			rw.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		logger.Sugar().Error("failed to read request body.", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var oids []string
+	if strings.Contains(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.Unmarshal(b, &oids); err != nil {
+			logger.Sugar().Error("cannot decode request JSON body")
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	} else {
+		for _, line := range strings.Split(string(b), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				oids = append(oids, line)
+			}
+		}
+	}
+
+	if len(oids) == 0 {
+		logger.Sugar().Error("orders batch is empty")
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if int64(len(oids)) > gr.cfg().OrdersBatchSize {
+		logger.Sugar().Errorf("orders batch exceeds %d orders", gr.cfg().OrdersBatchSize)
+		rw.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	valid := make([]string, 0, len(oids))
+	for i, oid := range oids {
+		oid, err := helpers.NormalizeOrder(oid, gr.cfg().OrderMaxLength)
+		if err == nil && helpers.ValidOrder(oid) {
+			oids[i] = oid
+			valid = append(valid, oid)
+		}
+	}
+
+	d, err := gr.service.RiskAssess(r.Context(), ctxUname, models.RiskOrderUpload, "", 0)
+	if err != nil {
+		logger.Sugar().Error("failed to assess order upload risk", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if gr.riskRefused(rw, d) {
+		return
+	}
+
+	var stored models.OrderBatchResults
+	status := http.StatusOK
+	switch {
+	case d.Action == models.RiskReview:
+		// nothing is registered, the batch is uploaded again once the review is approved
+		logger.Sugar().Info("orders batch upload is held for review")
+		status = http.StatusAccepted
+		for _, oid := range valid {
+			stored = append(stored, models.OrderBatchResult{Number: oid, Status: models.BatchHeld})
+		}
+	case len(valid) != 0:
+		stored, err = gr.service.OrdersAddBatch(r.Context(), ctxUname, valid)
+		if err != nil {
+			logger.Sugar().Error("failed to register orders batch", zap.Error(err))
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	results := make(models.OrderBatchResults, 0, len(oids))
+	for _, oid := range oids {
+		if len(stored) != 0 && stored[0].Number == oid {
+			if stored[0].Status == models.BatchAccepted {
+				status = http.StatusAccepted
+			}
+			results = append(results, stored[0])
+			stored = stored[1:]
+			continue
+		}
+		results = append(results, models.OrderBatchResult{Number: oid, Status: models.BatchInvalidLuhn})
+	}
+
+	body, err := json.Marshal(results)
+	if err != nil {
+		logger.Sugar().Error("failed to marshal orders batch results", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+
+	if _, err := rw.Write(body); err != nil {
+		logger.Sugar().Error("failed to write orders batch results", zap.Error(err))
+		return
+	}
+}
+
 func (gr *GophermartHandler) AccrualWithdraw(rw http.ResponseWriter, r *http.Request) {
-	logger := gr.logger
+	logger := logging.FromContext(r.Context(), gr.logger)
 	var w models.Withdrawal
 	v := r.Context().Value(mw.CtxKey{})
 	ctxUname, ok := v.(string)
@@ -213,14 +530,15 @@ func (gr *GophermartHandler) AccrualWithdraw(rw http.ResponseWriter, r *http.Req
 		return
 	}
 	w.UserID = ctxUname
-	orderNum, err := strconv.ParseInt(w.Number, 10, 64)
-	if err != nil || !helpers.ValidOrder(orderNum) {
+	oid, err := helpers.NormalizeOrder(w.Number, gr.cfg().OrderMaxLength)
+	if err != nil || !helpers.ValidOrder(oid) {
 		logger.Sugar().Errorf(errorIncorrectOrderNumber, w.Number)
 		rw.WriteHeader(http.StatusUnprocessableEntity)
 		return
 	}
+	w.Number = oid
 
-	user, err := gr.service.UserGet(ctxUname)
+	user, err := gr.service.UserGet(r.Context(), ctxUname)
 	if err != nil {
 		logger.Sugar().Error("failed to get user from DB", zap.Error(err))
 		rw.WriteHeader(http.StatusInternalServerError)
@@ -231,15 +549,84 @@ func (gr *GophermartHandler) AccrualWithdraw(rw http.ResponseWriter, r *http.Req
 		rw.WriteHeader(http.StatusPaymentRequired)
 		return
 	}
-	if err := gr.service.AccrualWithdraw(w); err != nil {
+
+	d, err := gr.service.RiskAssess(r.Context(), ctxUname, models.RiskWithdrawal, oid, w.Sum)
+	if err != nil {
+		logger.Sugar().Error("failed to assess withdrawal risk", zap.Error(err))
+		switch {
+		case errors.Is(err, models.ErrInsufficientFunds):
+			rw.WriteHeader(http.StatusPaymentRequired)
+			return
+		case errors.Is(err, models.ErrAlreadyHeld):
+			rw.WriteHeader(http.StatusConflict)
+			return
+		}
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if gr.riskRefused(rw, d) {
+		return
+	}
+	if d.Action == models.RiskReview {
+		logger.Sugar().Infof("withdrawal for order %s is held for review", oid)
+		rw.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if err := gr.service.AccrualWithdraw(r.Context(), w); err != nil {
 		logger.Sugar().Error(zap.Error(err))
-		rw.WriteHeader(http.StatusConflict)
+		var le *models.LimitError
+		switch {
+		case errors.As(err, &le):
+			gr.writeProblem(rw, http.StatusUnprocessableEntity, le.Code, "withdrawal limit violated")
+		case errors.Is(err, models.ErrInsufficientFunds):
+			rw.WriteHeader(http.StatusPaymentRequired)
+		default:
+			rw.WriteHeader(http.StatusConflict)
+		}
 		return
 	}
 }
 
+func (gr *GophermartHandler) Transfer(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	var t models.Transfer
+	ctxUname, ok := r.Context().Value(mw.CtxKey{}).(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&t); err != nil {
+		logger.Sugar().Error("cannot decode request JSON body")
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	t.From = ctxUname
+	if t.Amount <= 0 || t.To == t.From {
+		logger.Sugar().Errorf("incorrect transfer of %v to %s", t.Amount, t.To)
+		rw.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+
+	t, err := gr.service.Transfer(r.Context(), t)
+	if err != nil {
+		logger.Sugar().Error("failed to transfer points", zap.Error(err))
+		var le *models.LimitError
+		if errors.As(err, &le) {
+			gr.writeProblem(rw, http.StatusUnprocessableEntity, le.Code, "withdrawal limit violated")
+			return
+		}
+		rw.WriteHeader(errorStatus(err))
+		return
+	}
+	gr.writeJSON(rw, http.StatusOK, t)
+}
+
 func (gr *GophermartHandler) WithdrawalsGet(rw http.ResponseWriter, r *http.Request) {
-	logger := gr.logger
+	logger := logging.FromContext(r.Context(), gr.logger)
 	v := r.Context().Value(mw.CtxKey{})
 	ctxUname, ok := v.(string)
 	if !ok {
@@ -248,7 +635,7 @@ func (gr *GophermartHandler) WithdrawalsGet(rw http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	w, err := gr.service.WithdrawalsGet(ctxUname)
+	w, err := gr.service.WithdrawalsGet(r.Context(), ctxUname)
 	if err != nil {
 		logger.Sugar().Error("failed to get withdrawals", zap.Error(err))
 		rw.WriteHeader(http.StatusInternalServerError)
@@ -271,8 +658,46 @@ func (gr *GophermartHandler) WithdrawalsGet(rw http.ResponseWriter, r *http.Requ
 	}
 }
 
+// decodeReversal reads an optional reversal request body, an empty body
+// reverses the whole remaining sum.
+func decodeReversal(r *http.Request) (models.WithdrawalReversal, error) {
+	var rev models.WithdrawalReversal
+	if err := json.NewDecoder(r.Body).Decode(&rev); err != nil && !errors.Is(err, io.EOF) {
+		return rev, fmt.Errorf("cannot decode request JSON body: %w", err)
+	}
+	rev.Number = chi.URLParam(r, "number")
+	return rev, nil
+}
+
+func (gr *GophermartHandler) WithdrawalReverse(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	v := r.Context().Value(mw.CtxKey{})
+	ctxUname, ok := v.(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rev, err := decodeReversal(r)
+	if err != nil {
+		logger.Sugar().Error(zap.Error(err))
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	rev.UserID = ctxUname
+
+	rev, err = gr.service.WithdrawalReverse(r.Context(), rev)
+	if err != nil {
+		logger.Sugar().Error("failed to request withdrawal reversal", zap.Error(err))
+		rw.WriteHeader(errorStatus(err))
+		return
+	}
+	gr.writeJSON(rw, http.StatusAccepted, rev)
+}
+
 func (gr *GophermartHandler) BalanceGet(rw http.ResponseWriter, r *http.Request) {
-	logger := gr.logger
+	logger := logging.FromContext(r.Context(), gr.logger)
 	v := r.Context().Value(mw.CtxKey{})
 	ctxUname, ok := v.(string)
 	if !ok {
@@ -281,7 +706,7 @@ func (gr *GophermartHandler) BalanceGet(rw http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	bal, err := gr.service.BalanceGet(ctxUname)
+	bal, err := gr.service.BalanceGet(r.Context(), ctxUname)
 	if err != nil {
 		logger.Sugar().Error("failed to get user balance", zap.Error(err))
 		rw.WriteHeader(http.StatusInternalServerError)
@@ -303,3 +728,299 @@ func (gr *GophermartHandler) BalanceGet(rw http.ResponseWriter, r *http.Request)
 		return
 	}
 }
+
+func (gr *GophermartHandler) BalanceHistoryGet(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	v := r.Context().Value(mw.CtxKey{})
+	ctxUname, ok := v.(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	entries, err := gr.service.BalanceHistoryGet(r.Context(), ctxUname)
+	if err != nil {
+		logger.Sugar().Error("failed to get balance history", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	body, err := json.Marshal(entries)
+	if err != nil {
+		logger.Sugar().Error("failed to marshal balance history", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+
+	if _, err := rw.Write(body); err != nil {
+		logger.Sugar().Error("failed to write balance history", zap.Error(err))
+		return
+	}
+}
+
+func (gr *GophermartHandler) OrdersStream(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	v := r.Context().Value(mw.CtxKey{})
+	ctxUname, ok := v.(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var lastID int64
+	if h := r.Header.Get("Last-Event-ID"); h != "" {
+		id, err := strconv.ParseInt(h, 10, 64)
+		if err != nil || id < 0 {
+			logger.Sugar().Errorf("incorrect Last-Event-ID %s", h)
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		lastID = id
+	}
+
+	backlog, events, unsubscribe, err := gr.service.OrderEventsSubscribe(r.Context(), ctxUname, lastID)
+	if err != nil {
+		logger.Sugar().Error("failed to subscribe to order events", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	defer unsubscribe()
+
+	// the stream outlives the server read and write timeouts
+	rc := http.NewResponseController(rw)
+	for _, setDeadline := range []func(time.Time) error{rc.SetReadDeadline, rc.SetWriteDeadline} {
+		if err := setDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			logger.Sugar().Error("failed to clear order events stream deadline", zap.Error(err))
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.Header().Set("Connection", "keep-alive")
+	rw.Header().Set("X-Accel-Buffering", "no")
+	rw.WriteHeader(http.StatusOK)
+
+	send := func(e models.OrderEvent) error {
+		if e.Seq <= lastID {
+			return nil
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("failed to marshal order event: %w", err)
+		}
+		if _, err := fmt.Fprintf(rw, "id: %d\nevent: %s\ndata: %s\n\n", e.Seq, e.Type, data); err != nil {
+			return fmt.Errorf("failed to write order event: %w", err)
+		}
+		lastID = e.Seq
+		return nil
+	}
+
+	for _, e := range backlog {
+		if err := send(e); err != nil {
+			logger.Sugar().Error(zap.Error(err))
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		logger.Sugar().Error("failed to flush order events", zap.Error(err))
+		return
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(rw, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case e, ok := <-events:
+			if !ok {
+				// dropped by the broker, the client resumes with Last-Event-ID
+				return
+			}
+			if err := send(e); err != nil {
+				logger.Sugar().Error(zap.Error(err))
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			logger.Sugar().Error("failed to flush order events", zap.Error(err))
+			return
+		}
+	}
+}
+
+func (gr *GophermartHandler) WebhookAdd(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	var wh models.Webhook
+	v := r.Context().Value(mw.CtxKey{})
+	ctxUname, ok := v.(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&wh); err != nil {
+		logger.Sugar().Error("cannot decode request JSON body")
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !helpers.ValidWebhookURL(wh.URL) {
+		logger.Sugar().Errorf("incorrect webhook url %s", wh.URL)
+		rw.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+	wh.UserID = ctxUname
+
+	wh, err := gr.service.WebhookAdd(r.Context(), wh)
+	if err != nil {
+		logger.Sugar().Error("failed to register webhook", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	body, err := json.Marshal(wh)
+	if err != nil {
+		logger.Sugar().Error("failed to marshal webhook", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusCreated)
+
+	if _, err := rw.Write(body); err != nil {
+		logger.Sugar().Error("failed to write webhook", zap.Error(err))
+		return
+	}
+}
+
+func (gr *GophermartHandler) WebhooksGet(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	v := r.Context().Value(mw.CtxKey{})
+	ctxUname, ok := v.(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	webhooks, err := gr.service.WebhooksGet(r.Context(), ctxUname)
+	if err != nil {
+		logger.Sugar().Error("failed to get webhooks", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	body, err := json.Marshal(webhooks)
+	if err != nil {
+		logger.Sugar().Error("failed to marshal webhooks list", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+
+	if _, err := rw.Write(body); err != nil {
+		logger.Sugar().Error("failed to write webhooks list", zap.Error(err))
+		return
+	}
+}
+
+func (gr *GophermartHandler) WebhookDelete(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	v := r.Context().Value(mw.CtxKey{})
+	ctxUname, ok := v.(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		logger.Sugar().Errorf("incorrect webhook id %s", chi.URLParam(r, "id"))
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := gr.service.WebhookDelete(r.Context(), ctxUname, id)
+	if err != nil {
+		logger.Sugar().Error("failed to delete webhook", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (gr *GophermartHandler) WebhookDeliveriesGet(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	v := r.Context().Value(mw.CtxKey{})
+	ctxUname, ok := v.(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		logger.Sugar().Errorf("incorrect webhook id %s", chi.URLParam(r, "id"))
+		rw.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	deliveries, err := gr.service.WebhookDeliveriesGet(r.Context(), ctxUname, id)
+	if err != nil {
+		logger.Sugar().Error("failed to get webhook deliveries", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	body, err := json.Marshal(deliveries)
+	if err != nil {
+		logger.Sugar().Error("failed to marshal webhook deliveries", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+
+	if _, err := rw.Write(body); err != nil {
+		logger.Sugar().Error("failed to write webhook deliveries", zap.Error(err))
+		return
+	}
+}
+
+func (gr *GophermartHandler) ReferralStatsGet(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context(), gr.logger)
+	ctxUname, ok := r.Context().Value(mw.CtxKey{}).(string)
+	if !ok {
+		logger.Sugar().Error(errorNoContextUser)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	stats, err := gr.service.ReferralStatsGet(r.Context(), ctxUname)
+	if err != nil {
+		logger.Sugar().Error("failed to get referral stats", zap.Error(err))
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	gr.writeJSON(rw, http.StatusOK, stats)
+}
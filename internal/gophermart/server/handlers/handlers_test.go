@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -49,7 +50,7 @@ func TestOrdersGet(t *testing.T) {
 		{
 			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
 				s := mock_handlers.NewMockService(c)
-				s.EXPECT().OrdersGet(gomock.Any()).Return(orders, nil).AnyTimes()
+				s.EXPECT().OrdersGet(gomock.Any(), gomock.Any()).Return(orders, nil).AnyTimes()
 				return s
 			},
 			name:         "#get_orders_OK",
@@ -66,7 +67,7 @@ func TestOrdersGet(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			svc := tc.mockSvc(ctrl)
 
-			h := NewGophermartHandler(svc, logger)
+			h := NewGophermartHandler(svc, &models.Config{Logger: logger})
 
 			r := httptest.NewRequest(tc.method, tc.path, http.NoBody)
 			w := httptest.NewRecorder()
@@ -125,8 +126,10 @@ func TestOrderAdd(t *testing.T) {
 		{
 			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
 				s := mock_handlers.NewMockService(c)
-				s.EXPECT().OrderAdd(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
-				s.EXPECT().OrderGet(gomock.Any()).Return(models.Order{}, nil).AnyTimes()
+				s.EXPECT().RiskAssess(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(
+					models.RiskDecision{Action: models.RiskAllow}, nil).AnyTimes()
+				s.EXPECT().OrderAdd(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+				s.EXPECT().OrderGet(gomock.Any(), gomock.Any()).Return(models.Order{}, nil).AnyTimes()
 				return s
 			},
 			name:         "#add_order_OK",
@@ -140,8 +143,10 @@ func TestOrderAdd(t *testing.T) {
 		{
 			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
 				s := mock_handlers.NewMockService(c)
-				s.EXPECT().OrderAdd(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
-				s.EXPECT().OrderGet(gomock.Any()).Return(order, nil).AnyTimes()
+				s.EXPECT().RiskAssess(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(
+					models.RiskDecision{Action: models.RiskAllow}, nil).AnyTimes()
+				s.EXPECT().OrderAdd(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+				s.EXPECT().OrderGet(gomock.Any(), gomock.Any()).Return(order, nil).AnyTimes()
 				return s
 			},
 			name:         "#add_order_same_user_OK",
@@ -155,8 +160,11 @@ func TestOrderAdd(t *testing.T) {
 		{
 			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
 				s := mock_handlers.NewMockService(c)
-				s.EXPECT().OrderAdd(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
-				s.EXPECT().OrderGet(gomock.Any()).Return(order, nil).AnyTimes()
+				s.EXPECT().RiskAssess(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(
+					models.RiskDecision{Action: models.RiskAllow}, nil).AnyTimes()
+				s.EXPECT().OrderAdd(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+				s.EXPECT().OrderGet(gomock.Any(), gomock.Any()).Return(order, nil).AnyTimes()
+				s.EXPECT().OrderConflicts(gomock.Any(), "testuser", []string{"2377225624"}).Return(nil)
 				return s
 			},
 			name:         "#add_order_exists_differentuser_FAIL",
@@ -170,8 +178,41 @@ func TestOrderAdd(t *testing.T) {
 		{
 			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
 				s := mock_handlers.NewMockService(c)
-				s.EXPECT().OrderAdd(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
-				s.EXPECT().OrderGet(gomock.Any()).Return(order, nil).AnyTimes()
+				s.EXPECT().RiskAssess(gomock.Any(), "user01", models.RiskOrderUpload, "2377225624", float32(0)).Return(
+					models.RiskDecision{Action: models.RiskBlock}, nil)
+				return s
+			},
+			name:         "#add_order_blocked_FAIL",
+			user:         "user01",
+			method:       http.MethodPost,
+			path:         "/api/user/orders",
+			body:         "2377225624",
+			expectedCode: http.StatusForbidden,
+			expectedBody: "",
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().RiskAssess(gomock.Any(), "user01", models.RiskOrderUpload, "2377225624", float32(0)).Return(
+					models.RiskDecision{Action: models.RiskReview}, nil)
+				s.EXPECT().OrderGet(gomock.Any(), "2377225624").Return(models.Order{}, nil)
+				return s
+			},
+			name:         "#add_order_review_OK",
+			user:         "user01",
+			method:       http.MethodPost,
+			path:         "/api/user/orders",
+			body:         "2377225624",
+			expectedCode: http.StatusAccepted,
+			expectedBody: "",
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().RiskAssess(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(
+					models.RiskDecision{Action: models.RiskAllow}, nil).AnyTimes()
+				s.EXPECT().OrderAdd(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+				s.EXPECT().OrderGet(gomock.Any(), gomock.Any()).Return(order, nil).AnyTimes()
 				return s
 			},
 			name:         "#add_order_incorrect_number_FAIL",
@@ -182,6 +223,23 @@ func TestOrderAdd(t *testing.T) {
 			expectedCode: http.StatusUnprocessableEntity,
 			expectedBody: "",
 		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().RiskAssess(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(
+					models.RiskDecision{Action: models.RiskAllow}, nil).AnyTimes()
+				s.EXPECT().OrderGet(gomock.Any(), "00123456789012345678901234567891").Return(models.Order{}, nil)
+				s.EXPECT().OrderAdd(gomock.Any(), "user01", "00123456789012345678901234567891").Return(nil)
+				return s
+			},
+			name:         "#add_order_long_number_OK",
+			user:         "user01",
+			method:       http.MethodPost,
+			path:         "/api/user/orders",
+			body:         " 00123456789012345678901234567891\n",
+			expectedCode: http.StatusAccepted,
+			expectedBody: "",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -189,7 +247,7 @@ func TestOrderAdd(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			svc := tc.mockSvc(ctrl)
 
-			h := NewGophermartHandler(svc, logger)
+			h := NewGophermartHandler(svc, &models.Config{Logger: logger})
 
 			r := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
 			w := httptest.NewRecorder()
@@ -242,7 +300,7 @@ func TestBalanceGet(t *testing.T) {
 		{
 			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
 				s := mock_handlers.NewMockService(c)
-				s.EXPECT().BalanceGet(gomock.Any()).Return(balance, nil).AnyTimes()
+				s.EXPECT().BalanceGet(gomock.Any(), gomock.Any()).Return(balance, nil).AnyTimes()
 				return s
 			},
 			name:         "#balance_get_OK",
@@ -252,6 +310,61 @@ func TestBalanceGet(t *testing.T) {
 			expectedCode: http.StatusOK,
 			expectedBody: `{"current":600.5,"withdrawn":386.5}`,
 		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().BalanceGet(gomock.Any(), gomock.Any()).Return(models.Balance{
+					Current:   600.5,
+					Withdrawn: 386.5,
+					Expiring: models.PointsExpirations{
+						{Expires: time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC), Amount: 100},
+					},
+				}, nil)
+				return s
+			},
+			name:         "#balance_get_expiring_OK",
+			user:         "user01",
+			method:       http.MethodGet,
+			path:         "/api/user/balance",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"current":600.5,"withdrawn":386.5,"expiring":[{"expires_at":"2025-01-10T00:00:00Z","amount":100}]}`,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().BalanceGet(gomock.Any(), gomock.Any()).Return(models.Balance{
+					Current:   600.5,
+					Withdrawn: 386.5,
+					Tier:      "gold",
+				}, nil)
+				return s
+			},
+			name:         "#balance_get_tier_OK",
+			user:         "user01",
+			method:       http.MethodGet,
+			path:         "/api/user/balance",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"current":600.5,"withdrawn":386.5,"tier":"gold"}`,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().BalanceGet(gomock.Any(), gomock.Any()).Return(models.Balance{
+					Current:       600.5,
+					Withdrawn:     386.5,
+					Pending:       120,
+					Held:          250,
+					PendingOrders: 2,
+				}, nil)
+				return s
+			},
+			name:         "#balance_get_pending_OK",
+			user:         "user01",
+			method:       http.MethodGet,
+			path:         "/api/user/balance",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"current":600.5,"withdrawn":386.5,"pending":120,"held":250,"pending_orders":2}`,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -259,7 +372,7 @@ func TestBalanceGet(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			svc := tc.mockSvc(ctrl)
 
-			h := NewGophermartHandler(svc, logger)
+			h := NewGophermartHandler(svc, &models.Config{Logger: logger})
 
 			r := httptest.NewRequest(tc.method, tc.path, http.NoBody)
 			w := httptest.NewRecorder()
@@ -308,6 +421,8 @@ func TestAccrualWithdraw(t *testing.T) {
 		{
 			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
 				s := mock_handlers.NewMockService(c)
+				s.EXPECT().RiskAssess(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(
+					models.RiskDecision{Action: models.RiskAllow}, nil).AnyTimes()
 				return s
 			},
 			name:         "#accrual_withdraw_badrequest_FAIL",
@@ -321,7 +436,9 @@ func TestAccrualWithdraw(t *testing.T) {
 		{
 			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
 				s := mock_handlers.NewMockService(c)
-				s.EXPECT().UserGet(gomock.Any()).Return(models.User{UserID: "user01", Accrual: 0, Password: ""}, nil)
+				s.EXPECT().RiskAssess(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(
+					models.RiskDecision{Action: models.RiskAllow}, nil).AnyTimes()
+				s.EXPECT().UserGet(gomock.Any(), gomock.Any()).Return(models.User{UserID: "user01", Accrual: 0, Password: ""}, nil)
 				return s
 			},
 			name:         "#accrual_withdraw_paymentneeded_FAIL",
@@ -335,8 +452,11 @@ func TestAccrualWithdraw(t *testing.T) {
 		{
 			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
 				s := mock_handlers.NewMockService(c)
-				s.EXPECT().UserGet(gomock.Any()).Return(models.User{UserID: "user01", Accrual: 500, Password: ""}, nil)
-				s.EXPECT().AccrualWithdraw(gomock.Any()).Return(nil)
+				s.EXPECT().RiskAssess(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(
+					models.RiskDecision{Action: models.RiskAllow}, nil).AnyTimes()
+				s.EXPECT().UserGet(gomock.Any(), gomock.Any()).
+					Return(models.User{UserID: "user01", Accrual: 500, Password: ""}, nil)
+				s.EXPECT().AccrualWithdraw(gomock.Any(), gomock.Any()).Return(nil)
 				return s
 			},
 			name:         "#accrual_withdraw_OK",
@@ -347,6 +467,76 @@ func TestAccrualWithdraw(t *testing.T) {
 			expectedCode: http.StatusOK,
 			expectedBody: "",
 		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().RiskAssess(gomock.Any(), "user01", models.RiskWithdrawal, "12345678903", float32(250)).Return(
+					models.RiskDecision{Action: models.RiskDelay}, nil)
+				s.EXPECT().UserGet(gomock.Any(), gomock.Any()).
+					Return(models.User{UserID: "user01", Accrual: 500, Password: ""}, nil)
+				return s
+			},
+			name:         "#accrual_withdraw_delayed_FAIL",
+			user:         "user01",
+			method:       http.MethodPost,
+			path:         "/api/user/balance/withdraw",
+			body:         `{"order":"12345678903","sum":250}`,
+			expectedCode: http.StatusTooManyRequests,
+			expectedBody: "",
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().RiskAssess(gomock.Any(), "user01", models.RiskWithdrawal, "12345678903", float32(250)).Return(
+					models.RiskDecision{Action: models.RiskReview}, nil)
+				s.EXPECT().UserGet(gomock.Any(), gomock.Any()).
+					Return(models.User{UserID: "user01", Accrual: 500, Password: ""}, nil)
+				return s
+			},
+			name:         "#accrual_withdraw_review_OK",
+			user:         "user01",
+			method:       http.MethodPost,
+			path:         "/api/user/balance/withdraw",
+			body:         `{"order":"12345678903","sum":250}`,
+			expectedCode: http.StatusAccepted,
+			expectedBody: "",
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().RiskAssess(gomock.Any(), "user01", models.RiskWithdrawal, "12345678903", float32(250)).Return(
+					models.RiskDecision{}, fmt.Errorf("failed to record risk decision: %w", models.ErrAlreadyHeld))
+				s.EXPECT().UserGet(gomock.Any(), gomock.Any()).
+					Return(models.User{UserID: "user01", Accrual: 500, Password: ""}, nil)
+				return s
+			},
+			name:         "#accrual_withdraw_already_held_FAIL",
+			user:         "user01",
+			method:       http.MethodPost,
+			path:         "/api/user/balance/withdraw",
+			body:         `{"order":"12345678903","sum":250}`,
+			expectedCode: http.StatusConflict,
+			expectedBody: "",
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().RiskAssess(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(
+					models.RiskDecision{Action: models.RiskAllow}, nil).AnyTimes()
+				s.EXPECT().UserGet(gomock.Any(), gomock.Any()).
+					Return(models.User{UserID: "user01", Accrual: 500, Password: ""}, nil)
+				s.EXPECT().AccrualWithdraw(gomock.Any(), gomock.Any()).Return(
+					fmt.Errorf("failed to withdraw: %w", &models.LimitError{Code: models.LimitCooldown}))
+				return s
+			},
+			name:         "#accrual_withdraw_cooldown_FAIL",
+			user:         "user01",
+			method:       http.MethodPost,
+			path:         "/api/user/balance/withdraw",
+			body:         `{"order":"12345678903","sum":250}`,
+			expectedCode: http.StatusUnprocessableEntity,
+			expectedBody: `{"type":"about:blank","title":"withdrawal limit violated","code":"withdrawal_cooldown","status":422}`,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -354,7 +544,7 @@ func TestAccrualWithdraw(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			svc := tc.mockSvc(ctrl)
 
-			h := NewGophermartHandler(svc, logger)
+			h := NewGophermartHandler(svc, &models.Config{Logger: logger})
 
 			r := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
 			w := httptest.NewRecorder()
@@ -381,3 +571,521 @@ func TestAccrualWithdraw(t *testing.T) {
 		})
 	}
 }
+
+//nolint:dupl // handlers unit tests following same pattern
+func TestOrdersAddBatch(t *testing.T) {
+	logConfig := zap.NewDevelopmentConfig()
+	logger, err := logConfig.Build()
+	if err != nil {
+		t.Error("failed to initialize Logger: %w", err)
+	}
+
+	cfg := &models.Config{
+		Logger:              logger,
+		OrdersBatchSize:     3,
+		OrdersBatchMaxBytes: 1024,
+	}
+
+	results := models.OrderBatchResults{
+		{Number: "2377225624", Status: models.BatchAccepted},
+		{Number: "12345678903", Status: models.BatchOwnedByOther},
+	}
+
+	testCases := []struct {
+		mockSvc      func(*gomock.Controller) *mock_handlers.MockService
+		name         string
+		user         string
+		contentType  string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().RiskAssess(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(
+					models.RiskDecision{Action: models.RiskAllow}, nil).AnyTimes()
+				s.EXPECT().OrdersAddBatch(gomock.Any(), "user01", []string{"2377225624", "12345678903"}).Return(results, nil)
+				return s
+			},
+			name:         "#add_orders_batch_text_OK",
+			user:         "user01",
+			contentType:  "text/plain",
+			body:         "2377225624\n2377225625\n12345678903\n",
+			expectedCode: http.StatusAccepted,
+			expectedBody: `[{"number":"2377225624","status":"accepted"},` +
+				`{"number":"2377225625","status":"invalid-luhn"},` +
+				`{"number":"12345678903","status":"owned-by-other"}]`,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().RiskAssess(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(
+					models.RiskDecision{Action: models.RiskAllow}, nil).AnyTimes()
+				s.EXPECT().OrdersAddBatch(gomock.Any(), "user01", []string{"2377225624"}).Return(
+					models.OrderBatchResults{{Number: "2377225624", Status: models.BatchDuplicateOwn}}, nil)
+				return s
+			},
+			name:         "#add_orders_batch_json_OK",
+			user:         "user01",
+			contentType:  "application/json",
+			body:         `["2377225624"]`,
+			expectedCode: http.StatusOK,
+			expectedBody: `[{"number":"2377225624","status":"duplicate-own"}]`,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().RiskAssess(gomock.Any(), "user01", models.RiskOrderUpload, "", float32(0)).Return(
+					models.RiskDecision{Action: models.RiskReview}, nil)
+				return s
+			},
+			name:         "#add_orders_batch_review_OK",
+			user:         "user01",
+			contentType:  "text/plain",
+			body:         "2377225624\n2377225625\n12345678903\n",
+			expectedCode: http.StatusAccepted,
+			expectedBody: `[{"number":"2377225624","status":"held"},` +
+				`{"number":"2377225625","status":"invalid-luhn"},` +
+				`{"number":"12345678903","status":"held"}]`,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				return mock_handlers.NewMockService(c)
+			},
+			name:         "#add_orders_batch_too_large_FAIL",
+			user:         "user01",
+			contentType:  "text/plain",
+			body:         "2377225624\n12345678903\n4561261212345467\n79927398713",
+			expectedCode: http.StatusRequestEntityTooLarge,
+			expectedBody: "",
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				return mock_handlers.NewMockService(c)
+			},
+			name:         "#add_orders_batch_bad_json_FAIL",
+			user:         "user01",
+			contentType:  "application/json",
+			body:         `{"order":"2377225624"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			svc := tc.mockSvc(ctrl)
+
+			h := NewGophermartHandler(svc, cfg)
+
+			r := httptest.NewRequest(http.MethodPost, "/api/user/orders/batch", strings.NewReader(tc.body))
+			r.Header.Set("Content-Type", tc.contentType)
+			w := httptest.NewRecorder()
+
+			ctx := r.Context()
+			ctx = context.WithValue(ctx, mw.CtxKey{}, tc.user)
+			r = r.WithContext(ctx)
+
+			h.OrdersAddBatch(w, r)
+			res := w.Result()
+			defer func() {
+				if err := res.Body.Close(); err != nil {
+					assert.Error(t, err)
+				}
+			}()
+
+			b, _ := io.ReadAll(res.Body)
+			assert.NoError(t, err, "error making HTTP request")
+
+			assert.Equal(t, tc.expectedCode, res.StatusCode, "Response code didn't match expected")
+			if tc.expectedBody != "" {
+				assert.Equal(t, tc.expectedBody, string(b))
+			}
+		})
+	}
+}
+
+func TestOrdersStream(t *testing.T) {
+	logConfig := zap.NewDevelopmentConfig()
+	logger, err := logConfig.Build()
+	if err != nil {
+		t.Error("failed to initialize Logger: %w", err)
+	}
+
+	tTime, _ := time.Parse(time.RFC3339, "2024-07-21T16:00:11+01:00")
+
+	backlog := models.OrderEvents{
+		{ID: 3, Seq: 3, UserID: "user01", Number: "2377225624", Type: models.OrderEventStatus, Status: "PROCESSED",
+			Accrual: 500, Created: tTime},
+	}
+
+	testCases := []struct {
+		mockSvc      func(*gomock.Controller) *mock_handlers.MockService
+		name         string
+		lastEventID  string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				live := make(chan models.OrderEvent, 2)
+				// already sent in the backlog, must be skipped
+				live <- backlog[0]
+				// inserted before the backlog event, but committed after it
+				live <- models.OrderEvent{ID: 2, Seq: 4, UserID: "user01", Number: "2377225624",
+					Type: models.OrderEventAccrual, Status: "PROCESSED", Accrual: 500, Created: tTime}
+				close(live)
+				s.EXPECT().OrderEventsSubscribe(gomock.Any(), "user01", int64(2)).Return(backlog, live, func() {}, nil)
+				return s
+			},
+			name:         "#orders_stream_resume_OK",
+			lastEventID:  "2",
+			expectedCode: http.StatusOK,
+			expectedBody: "id: 3\nevent: order.status\n" +
+				`data: {"created_at":"2024-07-21T16:00:11+01:00","number":"2377225624","status":"PROCESSED","accrual":500}` +
+				"\n\nid: 4\nevent: order.accrual\n" +
+				`data: {"created_at":"2024-07-21T16:00:11+01:00","number":"2377225624","status":"PROCESSED","accrual":500}` +
+				"\n\n",
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				return mock_handlers.NewMockService(c)
+			},
+			name:         "#orders_stream_bad_last_event_id_FAIL",
+			lastEventID:  "abc",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			svc := tc.mockSvc(ctrl)
+
+			h := NewGophermartHandler(svc, &models.Config{Logger: logger})
+
+			r := httptest.NewRequest(http.MethodGet, "/api/user/orders/stream", http.NoBody)
+			r.Header.Set("Last-Event-ID", tc.lastEventID)
+			w := httptest.NewRecorder()
+
+			ctx := r.Context()
+			ctx = context.WithValue(ctx, mw.CtxKey{}, "user01")
+			r = r.WithContext(ctx)
+
+			h.OrdersStream(w, r)
+			res := w.Result()
+			defer func() {
+				if err := res.Body.Close(); err != nil {
+					assert.Error(t, err)
+				}
+			}()
+
+			b, _ := io.ReadAll(res.Body)
+
+			assert.Equal(t, tc.expectedCode, res.StatusCode, "Response code didn't match expected")
+			if tc.expectedBody != "" {
+				assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+				assert.Equal(t, tc.expectedBody, string(b))
+			}
+		})
+	}
+}
+
+//nolint:dupl // handlers unit tests following same pattern
+func TestReferralStatsGet(t *testing.T) {
+	logConfig := zap.NewDevelopmentConfig()
+	logger, err := logConfig.Build()
+	if err != nil {
+		t.Error("failed to initialize Logger: %w", err)
+	}
+
+	testCases := []struct {
+		mockSvc      func(*gomock.Controller) *mock_handlers.MockService
+		name         string
+		user         string
+		method       string
+		path         string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().ReferralStatsGet(gomock.Any(), "user01").Return(models.ReferralStats{
+					InviteCode: "3F9A0C21B7", Invited: 3, Rewarded: 1, Earned: 100,
+				}, nil)
+				return s
+			},
+			name:         "#referral_stats_OK",
+			user:         "user01",
+			method:       http.MethodGet,
+			path:         "/api/user/referrals",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"invite_code":"3F9A0C21B7","invited":3,"rewarded":1,"earned":100}`,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().ReferralStatsGet(gomock.Any(), "user01").Return(models.ReferralStats{},
+					fmt.Errorf("failed to get referral stats: %w", context.DeadlineExceeded))
+				return s
+			},
+			name:         "#referral_stats_db_error_FAIL",
+			user:         "user01",
+			method:       http.MethodGet,
+			path:         "/api/user/referrals",
+			expectedCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			svc := tc.mockSvc(ctrl)
+
+			h := NewGophermartHandler(svc, &models.Config{Logger: logger})
+
+			r := httptest.NewRequest(tc.method, tc.path, http.NoBody)
+			w := httptest.NewRecorder()
+
+			ctx := r.Context()
+			ctx = context.WithValue(ctx, mw.CtxKey{}, tc.user)
+			r = r.WithContext(ctx)
+
+			h.ReferralStatsGet(w, r)
+			res := w.Result()
+			defer func() {
+				if err := res.Body.Close(); err != nil {
+					assert.Error(t, err)
+				}
+			}()
+
+			b, _ := io.ReadAll(res.Body)
+			assert.NoError(t, err, "error making HTTP request")
+
+			assert.Equal(t, tc.expectedCode, res.StatusCode, "Response code didn't match expected")
+			if tc.expectedBody != "" {
+				assert.JSONEq(t, tc.expectedBody, string(b))
+			}
+		})
+	}
+}
+
+//nolint:dupl // handlers unit tests following same pattern
+func TestTransfer(t *testing.T) {
+	logConfig := zap.NewDevelopmentConfig()
+	logger, err := logConfig.Build()
+	if err != nil {
+		t.Error("failed to initialize Logger: %w", err)
+	}
+
+	testCases := []struct {
+		mockSvc      func(*gomock.Controller) *mock_handlers.MockService
+		name         string
+		user         string
+		method       string
+		path         string
+		body         string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().Transfer(gomock.Any(), models.Transfer{From: "user01", To: "user02", Amount: 25}).
+					Return(models.Transfer{From: "user01", To: "user02", Amount: 25, ID: 5}, nil)
+				return s
+			},
+			name:         "#transfer_OK",
+			user:         "user01",
+			method:       http.MethodPost,
+			path:         "/api/user/balance/transfer",
+			body:         `{"to":"user02","amount":25}`,
+			expectedCode: http.StatusOK,
+			expectedBody: `{"created_at":"0001-01-01T00:00:00Z","from":"user01","to":"user02","amount":25,"id":5}`,
+		},
+		{
+			mockSvc:      mock_handlers.NewMockService,
+			name:         "#transfer_to_self_FAIL",
+			user:         "user01",
+			method:       http.MethodPost,
+			path:         "/api/user/balance/transfer",
+			body:         `{"to":"user01","amount":25}`,
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().Transfer(gomock.Any(), models.Transfer{From: "user01", To: "user03", Amount: 25}).
+					Return(models.Transfer{}, fmt.Errorf("failed to transfer: user user03: %w", models.ErrNotFound))
+				return s
+			},
+			name:         "#transfer_unknown_or_locked_recipient_FAIL",
+			user:         "user01",
+			method:       http.MethodPost,
+			path:         "/api/user/balance/transfer",
+			body:         `{"to":"user03","amount":25}`,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().Transfer(gomock.Any(), gomock.Any()).Return(models.Transfer{},
+					fmt.Errorf("failed to transfer: %w", models.ErrLimitExceeded))
+				return s
+			},
+			name:         "#transfer_daily_limit_FAIL",
+			user:         "user01",
+			method:       http.MethodPost,
+			path:         "/api/user/balance/transfer",
+			body:         `{"to":"user02","amount":2500}`,
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().Transfer(gomock.Any(), gomock.Any()).Return(models.Transfer{},
+					fmt.Errorf("failed to transfer: %w", &models.LimitError{Code: models.LimitCooldown}))
+				return s
+			},
+			name:         "#transfer_cooldown_FAIL",
+			user:         "user01",
+			method:       http.MethodPost,
+			path:         "/api/user/balance/transfer",
+			body:         `{"to":"user02","amount":25}`,
+			expectedCode: http.StatusUnprocessableEntity,
+			expectedBody: `{"type":"about:blank","title":"withdrawal limit violated","code":"withdrawal_cooldown","status":422}`,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().Transfer(gomock.Any(), gomock.Any()).Return(models.Transfer{},
+					fmt.Errorf("failed to transfer: %w", models.ErrInsufficientFunds))
+				return s
+			},
+			name:         "#transfer_held_points_FAIL",
+			user:         "user01",
+			method:       http.MethodPost,
+			path:         "/api/user/balance/transfer",
+			body:         `{"to":"user02","amount":400}`,
+			expectedCode: http.StatusPaymentRequired,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			svc := tc.mockSvc(ctrl)
+
+			h := NewGophermartHandler(svc, &models.Config{Logger: logger})
+
+			r := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+
+			ctx := r.Context()
+			ctx = context.WithValue(ctx, mw.CtxKey{}, tc.user)
+			r = r.WithContext(ctx)
+
+			h.Transfer(w, r)
+			res := w.Result()
+			defer func() {
+				if err := res.Body.Close(); err != nil {
+					assert.Error(t, err)
+				}
+			}()
+
+			b, _ := io.ReadAll(res.Body)
+			assert.NoError(t, err, "error making HTTP request")
+
+			assert.Equal(t, tc.expectedCode, res.StatusCode, "Response code didn't match expected")
+			if tc.expectedBody != "" {
+				assert.JSONEq(t, tc.expectedBody, string(b))
+			}
+		})
+	}
+}
+
+//nolint:dupl // handlers unit tests following same pattern
+func TestUserPasswordChange(t *testing.T) {
+	logConfig := zap.NewDevelopmentConfig()
+	logger, err := logConfig.Build()
+	if err != nil {
+		t.Error("failed to initialize Logger: %w", err)
+	}
+
+	testCases := []struct {
+		mockSvc      func(*gomock.Controller) *mock_handlers.MockService
+		name         string
+		user         string
+		method       string
+		path         string
+		body         string
+		expectedCode int
+	}{
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserPasswordChange(gomock.Any(), "user01", "old", "new").Return(nil)
+				return s
+			},
+			name:         "#password_change_OK",
+			user:         "user01",
+			method:       http.MethodPost,
+			path:         "/api/user/password",
+			body:         `{"old_password":"old","new_password":"new"}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserPasswordChange(gomock.Any(), "user01", "old", "new").Return(
+					fmt.Errorf("failed to change password: %w", models.ErrWrongPassword))
+				return s
+			},
+			name:         "#password_change_wrong_FAIL",
+			user:         "user01",
+			method:       http.MethodPost,
+			path:         "/api/user/password",
+			body:         `{"old_password":"old","new_password":"new"}`,
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			mockSvc:      mock_handlers.NewMockService,
+			name:         "#password_change_badrequest_FAIL",
+			user:         "user01",
+			method:       http.MethodPost,
+			path:         "/api/user/password",
+			body:         `{"old_password":`,
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			svc := tc.mockSvc(ctrl)
+
+			h := NewGophermartHandler(svc, &models.Config{Logger: logger})
+
+			r := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+
+			ctx := r.Context()
+			ctx = context.WithValue(ctx, mw.CtxKey{}, tc.user)
+			r = r.WithContext(ctx)
+
+			h.UserPasswordChange(w, r)
+			res := w.Result()
+			defer func() {
+				if err := res.Body.Close(); err != nil {
+					assert.Error(t, err)
+				}
+			}()
+
+			assert.Equal(t, tc.expectedCode, res.StatusCode, "Response code didn't match expected")
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
+)
+
+// Healthz reports that the process is up and serving. Dependencies are only
+// checked by Readyz, so that a DB outage does not restart the service.
+func (gr *GophermartHandler) Healthz(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Cache-Control", "no-store")
+	gr.writeJSON(rw, http.StatusOK, models.HealthCheck{Status: models.HealthOK})
+}
+
+// Readyz reports every dependency check and answers 503 Service Unavailable
+// if any of them failed or the service is shutting down.
+func (gr *GophermartHandler) Readyz(rw http.ResponseWriter, r *http.Request) {
+	report := gr.service.Readiness(r.Context())
+
+	status := http.StatusOK
+	if !report.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	rw.Header().Set("Cache-Control", "no-store")
+	gr.writeJSON(rw, status, report)
+}
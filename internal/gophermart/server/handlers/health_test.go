@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
+	mock_handlers "github.com/vkupriya/go-gophermart/internal/gophermart/server/handlers/mocks"
+)
+
+func TestHealth(t *testing.T) {
+	logger := zap.NewNop()
+
+	testCases := []struct {
+		mockSvc      func(*gomock.Controller) *mock_handlers.MockService
+		name         string
+		path         string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			mockSvc:      mock_handlers.NewMockService,
+			name:         "#healthz_OK",
+			path:         "/healthz",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"status":"ok"}`,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().Readiness(gomock.Any()).Return(models.HealthReport{
+					Ready: true,
+					Checks: map[string]models.HealthCheck{
+						"database": {Status: models.HealthOK},
+						"accrual":  {Status: models.HealthDegraded, Detail: "backing off after 429"},
+					},
+				})
+				return s
+			},
+			name:         "#readyz_degraded_OK",
+			path:         "/readyz",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"ready":true,"checks":{"accrual":{"status":"degraded","detail":"backing off after 429"},
+				"database":{"status":"ok"}}}`,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().Readiness(gomock.Any()).Return(models.HealthReport{
+					Checks: map[string]models.HealthCheck{
+						"shutdown": {Status: models.HealthFail, Detail: "shutting down"},
+						"database": {Status: models.HealthOK},
+					},
+				})
+				return s
+			},
+			name:         "#readyz_shutdown_unavailable",
+			path:         "/readyz",
+			expectedCode: http.StatusServiceUnavailable,
+			expectedBody: `{"ready":false,"checks":{"shutdown":{"status":"fail","detail":"shutting down"},
+				"database":{"status":"ok"}}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			h := NewGophermartHandler(tc.mockSvc(ctrl), &models.Config{Logger: logger})
+			r, err := NewGophermartRouter(&models.Config{Logger: logger}, h)
+			require.NoError(t, err)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, http.NoBody))
+			res := w.Result()
+			defer func() {
+				if err := res.Body.Close(); err != nil {
+					assert.Error(t, err)
+				}
+			}()
+
+			b, err := io.ReadAll(res.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedCode, res.StatusCode)
+			assert.Equal(t, "no-store", res.Header.Get("Cache-Control"))
+			assert.JSONEq(t, tc.expectedBody, string(b))
+		})
+	}
+}
@@ -1,10 +1,11 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: handlers.go
+// Source: server/handlers/handlers.go
 
 // Package mock_handlers is a generated GoMock package.
 package mock_handlers
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -35,133 +36,686 @@ func (m *MockService) EXPECT() *MockServiceMockRecorder {
 }
 
 // AccrualWithdraw mocks base method.
-func (m *MockService) AccrualWithdraw(w models.Withdrawal) error {
+func (m *MockService) AccrualWithdraw(ctx context.Context, w models.Withdrawal) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AccrualWithdraw", w)
+	ret := m.ctrl.Call(m, "AccrualWithdraw", ctx, w)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AccrualWithdraw indicates an expected call of AccrualWithdraw.
-func (mr *MockServiceMockRecorder) AccrualWithdraw(w interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) AccrualWithdraw(ctx, w interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AccrualWithdraw", reflect.TypeOf((*MockService)(nil).AccrualWithdraw), w)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AccrualWithdraw", reflect.TypeOf((*MockService)(nil).AccrualWithdraw), ctx, w)
+}
+
+// AdminAdjustmentAdd mocks base method.
+func (m *MockService) AdminAdjustmentAdd(ctx context.Context, admin string, a models.Adjustment) (models.Adjustment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminAdjustmentAdd", ctx, admin, a)
+	ret0, _ := ret[0].(models.Adjustment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdminAdjustmentAdd indicates an expected call of AdminAdjustmentAdd.
+func (mr *MockServiceMockRecorder) AdminAdjustmentAdd(ctx, admin, a interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminAdjustmentAdd", reflect.TypeOf((*MockService)(nil).AdminAdjustmentAdd), ctx, admin, a)
+}
+
+// AdminAdjustmentDecide mocks base method.
+func (m *MockService) AdminAdjustmentDecide(ctx context.Context, admin string, id int64, approve bool) (models.Adjustment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminAdjustmentDecide", ctx, admin, id, approve)
+	ret0, _ := ret[0].(models.Adjustment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdminAdjustmentDecide indicates an expected call of AdminAdjustmentDecide.
+func (mr *MockServiceMockRecorder) AdminAdjustmentDecide(ctx, admin, id, approve interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminAdjustmentDecide", reflect.TypeOf((*MockService)(nil).AdminAdjustmentDecide), ctx, admin, id, approve)
+}
+
+// AdminAdjustmentsGet mocks base method.
+func (m *MockService) AdminAdjustmentsGet(ctx context.Context, admin, status string, limit, offset int64) (models.Adjustments, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminAdjustmentsGet", ctx, admin, status, limit, offset)
+	ret0, _ := ret[0].(models.Adjustments)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdminAdjustmentsGet indicates an expected call of AdminAdjustmentsGet.
+func (mr *MockServiceMockRecorder) AdminAdjustmentsGet(ctx, admin, status, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminAdjustmentsGet", reflect.TypeOf((*MockService)(nil).AdminAdjustmentsGet), ctx, admin, status, limit, offset)
+}
+
+// AdminAuditGet mocks base method.
+func (m *MockService) AdminAuditGet(ctx context.Context, admin string, limit, offset int64) (models.AuditEntries, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminAuditGet", ctx, admin, limit, offset)
+	ret0, _ := ret[0].(models.AuditEntries)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdminAuditGet indicates an expected call of AdminAuditGet.
+func (mr *MockServiceMockRecorder) AdminAuditGet(ctx, admin, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminAuditGet", reflect.TypeOf((*MockService)(nil).AdminAuditGet), ctx, admin, limit, offset)
+}
+
+// AdminBalanceGet mocks base method.
+func (m *MockService) AdminBalanceGet(ctx context.Context, admin, uid string) (models.Balance, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminBalanceGet", ctx, admin, uid)
+	ret0, _ := ret[0].(models.Balance)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdminBalanceGet indicates an expected call of AdminBalanceGet.
+func (mr *MockServiceMockRecorder) AdminBalanceGet(ctx, admin, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminBalanceGet", reflect.TypeOf((*MockService)(nil).AdminBalanceGet), ctx, admin, uid)
+}
+
+// AdminCampaignAdd mocks base method.
+func (m *MockService) AdminCampaignAdd(ctx context.Context, admin string, cmp models.Campaign) (models.Campaign, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminCampaignAdd", ctx, admin, cmp)
+	ret0, _ := ret[0].(models.Campaign)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdminCampaignAdd indicates an expected call of AdminCampaignAdd.
+func (mr *MockServiceMockRecorder) AdminCampaignAdd(ctx, admin, cmp interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminCampaignAdd", reflect.TypeOf((*MockService)(nil).AdminCampaignAdd), ctx, admin, cmp)
+}
+
+// AdminCampaignStop mocks base method.
+func (m *MockService) AdminCampaignStop(ctx context.Context, admin string, id int64) (models.Campaign, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminCampaignStop", ctx, admin, id)
+	ret0, _ := ret[0].(models.Campaign)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdminCampaignStop indicates an expected call of AdminCampaignStop.
+func (mr *MockServiceMockRecorder) AdminCampaignStop(ctx, admin, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminCampaignStop", reflect.TypeOf((*MockService)(nil).AdminCampaignStop), ctx, admin, id)
+}
+
+// AdminCampaignsGet mocks base method.
+func (m *MockService) AdminCampaignsGet(ctx context.Context, admin string, limit, offset int64) (models.Campaigns, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminCampaignsGet", ctx, admin, limit, offset)
+	ret0, _ := ret[0].(models.Campaigns)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdminCampaignsGet indicates an expected call of AdminCampaignsGet.
+func (mr *MockServiceMockRecorder) AdminCampaignsGet(ctx, admin, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminCampaignsGet", reflect.TypeOf((*MockService)(nil).AdminCampaignsGet), ctx, admin, limit, offset)
+}
+
+// AdminOrderRecheck mocks base method.
+func (m *MockService) AdminOrderRecheck(ctx context.Context, admin, oid string) (models.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminOrderRecheck", ctx, admin, oid)
+	ret0, _ := ret[0].(models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdminOrderRecheck indicates an expected call of AdminOrderRecheck.
+func (mr *MockServiceMockRecorder) AdminOrderRecheck(ctx, admin, oid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminOrderRecheck", reflect.TypeOf((*MockService)(nil).AdminOrderRecheck), ctx, admin, oid)
+}
+
+// AdminOrdersGet mocks base method.
+func (m *MockService) AdminOrdersGet(ctx context.Context, admin, uid string) (models.Orders, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminOrdersGet", ctx, admin, uid)
+	ret0, _ := ret[0].(models.Orders)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdminOrdersGet indicates an expected call of AdminOrdersGet.
+func (mr *MockServiceMockRecorder) AdminOrdersGet(ctx, admin, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminOrdersGet", reflect.TypeOf((*MockService)(nil).AdminOrdersGet), ctx, admin, uid)
+}
+
+// AdminReversalDecide mocks base method.
+func (m *MockService) AdminReversalDecide(ctx context.Context, admin string, id int64, approve bool) (models.WithdrawalReversal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminReversalDecide", ctx, admin, id, approve)
+	ret0, _ := ret[0].(models.WithdrawalReversal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdminReversalDecide indicates an expected call of AdminReversalDecide.
+func (mr *MockServiceMockRecorder) AdminReversalDecide(ctx, admin, id, approve interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminReversalDecide", reflect.TypeOf((*MockService)(nil).AdminReversalDecide), ctx, admin, id, approve)
+}
+
+// AdminReversalsGet mocks base method.
+func (m *MockService) AdminReversalsGet(ctx context.Context, admin, status string, limit, offset int64) (models.WithdrawalReversals, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminReversalsGet", ctx, admin, status, limit, offset)
+	ret0, _ := ret[0].(models.WithdrawalReversals)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdminReversalsGet indicates an expected call of AdminReversalsGet.
+func (mr *MockServiceMockRecorder) AdminReversalsGet(ctx, admin, status, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminReversalsGet", reflect.TypeOf((*MockService)(nil).AdminReversalsGet), ctx, admin, status, limit, offset)
+}
+
+// AdminRiskDecide mocks base method.
+func (m *MockService) AdminRiskDecide(ctx context.Context, admin string, id int64, approve bool) (models.RiskDecision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminRiskDecide", ctx, admin, id, approve)
+	ret0, _ := ret[0].(models.RiskDecision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdminRiskDecide indicates an expected call of AdminRiskDecide.
+func (mr *MockServiceMockRecorder) AdminRiskDecide(ctx, admin, id, approve interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminRiskDecide", reflect.TypeOf((*MockService)(nil).AdminRiskDecide), ctx, admin, id, approve)
+}
+
+// AdminRiskDecisionsGet mocks base method.
+func (m *MockService) AdminRiskDecisionsGet(ctx context.Context, admin, action, status string, limit, offset int64) (models.RiskDecisions, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminRiskDecisionsGet", ctx, admin, action, status, limit, offset)
+	ret0, _ := ret[0].(models.RiskDecisions)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdminRiskDecisionsGet indicates an expected call of AdminRiskDecisionsGet.
+func (mr *MockServiceMockRecorder) AdminRiskDecisionsGet(ctx, admin, action, status, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminRiskDecisionsGet", reflect.TypeOf((*MockService)(nil).AdminRiskDecisionsGet), ctx, admin, action, status, limit, offset)
+}
+
+// AdminUserLock mocks base method.
+func (m *MockService) AdminUserLock(ctx context.Context, admin, uid string, locked bool) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminUserLock", ctx, admin, uid, locked)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdminUserLock indicates an expected call of AdminUserLock.
+func (mr *MockServiceMockRecorder) AdminUserLock(ctx, admin, uid, locked interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminUserLock", reflect.TypeOf((*MockService)(nil).AdminUserLock), ctx, admin, uid, locked)
+}
+
+// AdminUsersSearch mocks base method.
+func (m *MockService) AdminUsersSearch(ctx context.Context, admin, query string, limit, offset int64) (models.UserSummaries, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminUsersSearch", ctx, admin, query, limit, offset)
+	ret0, _ := ret[0].(models.UserSummaries)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdminUsersSearch indicates an expected call of AdminUsersSearch.
+func (mr *MockServiceMockRecorder) AdminUsersSearch(ctx, admin, query, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminUsersSearch", reflect.TypeOf((*MockService)(nil).AdminUsersSearch), ctx, admin, query, limit, offset)
+}
+
+// AdminWithdrawalReverse mocks base method.
+func (m *MockService) AdminWithdrawalReverse(ctx context.Context, admin string, r models.WithdrawalReversal) (models.WithdrawalReversal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminWithdrawalReverse", ctx, admin, r)
+	ret0, _ := ret[0].(models.WithdrawalReversal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdminWithdrawalReverse indicates an expected call of AdminWithdrawalReverse.
+func (mr *MockServiceMockRecorder) AdminWithdrawalReverse(ctx, admin, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminWithdrawalReverse", reflect.TypeOf((*MockService)(nil).AdminWithdrawalReverse), ctx, admin, r)
+}
+
+// AdminWithdrawalsGet mocks base method.
+func (m *MockService) AdminWithdrawalsGet(ctx context.Context, admin, uid string) (models.Withdrawals, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminWithdrawalsGet", ctx, admin, uid)
+	ret0, _ := ret[0].(models.Withdrawals)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdminWithdrawalsGet indicates an expected call of AdminWithdrawalsGet.
+func (mr *MockServiceMockRecorder) AdminWithdrawalsGet(ctx, admin, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminWithdrawalsGet", reflect.TypeOf((*MockService)(nil).AdminWithdrawalsGet), ctx, admin, uid)
 }
 
 // BalanceGet mocks base method.
-func (m *MockService) BalanceGet(uid string) (models.Balance, error) {
+func (m *MockService) BalanceGet(ctx context.Context, uid string) (models.Balance, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "BalanceGet", uid)
+	ret := m.ctrl.Call(m, "BalanceGet", ctx, uid)
 	ret0, _ := ret[0].(models.Balance)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // BalanceGet indicates an expected call of BalanceGet.
-func (mr *MockServiceMockRecorder) BalanceGet(uid interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) BalanceGet(ctx, uid interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BalanceGet", reflect.TypeOf((*MockService)(nil).BalanceGet), uid)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BalanceGet", reflect.TypeOf((*MockService)(nil).BalanceGet), ctx, uid)
+}
+
+// BalanceHistoryGet mocks base method.
+func (m *MockService) BalanceHistoryGet(ctx context.Context, uid string) (models.LedgerEntries, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BalanceHistoryGet", ctx, uid)
+	ret0, _ := ret[0].(models.LedgerEntries)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BalanceHistoryGet indicates an expected call of BalanceHistoryGet.
+func (mr *MockServiceMockRecorder) BalanceHistoryGet(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BalanceHistoryGet", reflect.TypeOf((*MockService)(nil).BalanceHistoryGet), ctx, uid)
+}
+
+// IdempotencyRelease mocks base method.
+func (m *MockService) IdempotencyRelease(ctx context.Context, uid, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IdempotencyRelease", ctx, uid, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IdempotencyRelease indicates an expected call of IdempotencyRelease.
+func (mr *MockServiceMockRecorder) IdempotencyRelease(ctx, uid, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IdempotencyRelease", reflect.TypeOf((*MockService)(nil).IdempotencyRelease), ctx, uid, key)
+}
+
+// IdempotencyReserve mocks base method.
+func (m *MockService) IdempotencyReserve(ctx context.Context, rec models.IdempotencyRecord) (models.IdempotencyRecord, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IdempotencyReserve", ctx, rec)
+	ret0, _ := ret[0].(models.IdempotencyRecord)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// IdempotencyReserve indicates an expected call of IdempotencyReserve.
+func (mr *MockServiceMockRecorder) IdempotencyReserve(ctx, rec interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IdempotencyReserve", reflect.TypeOf((*MockService)(nil).IdempotencyReserve), ctx, rec)
+}
+
+// IdempotencySave mocks base method.
+func (m *MockService) IdempotencySave(ctx context.Context, rec models.IdempotencyRecord) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IdempotencySave", ctx, rec)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IdempotencySave indicates an expected call of IdempotencySave.
+func (mr *MockServiceMockRecorder) IdempotencySave(ctx, rec interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IdempotencySave", reflect.TypeOf((*MockService)(nil).IdempotencySave), ctx, rec)
 }
 
 // OrderAdd mocks base method.
-func (m *MockService) OrderAdd(uid, oid string) error {
+func (m *MockService) OrderAdd(ctx context.Context, uid, oid string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "OrderAdd", uid, oid)
+	ret := m.ctrl.Call(m, "OrderAdd", ctx, uid, oid)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // OrderAdd indicates an expected call of OrderAdd.
-func (mr *MockServiceMockRecorder) OrderAdd(uid, oid interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) OrderAdd(ctx, uid, oid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrderAdd", reflect.TypeOf((*MockService)(nil).OrderAdd), ctx, uid, oid)
+}
+
+// OrderConflicts mocks base method.
+func (m *MockService) OrderConflicts(ctx context.Context, uid string, oids []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OrderConflicts", ctx, uid, oids)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// OrderConflicts indicates an expected call of OrderConflicts.
+func (mr *MockServiceMockRecorder) OrderConflicts(ctx, uid, oids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrderConflicts", reflect.TypeOf((*MockService)(nil).OrderConflicts), ctx, uid, oids)
+}
+
+// OrderEventsSubscribe mocks base method.
+func (m *MockService) OrderEventsSubscribe(ctx context.Context, uid string, lastID int64) (models.OrderEvents, <-chan models.OrderEvent, func(), error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OrderEventsSubscribe", ctx, uid, lastID)
+	ret0, _ := ret[0].(models.OrderEvents)
+	ret1, _ := ret[1].(<-chan models.OrderEvent)
+	ret2, _ := ret[2].(func())
+	ret3, _ := ret[3].(error)
+	return ret0, ret1, ret2, ret3
+}
+
+// OrderEventsSubscribe indicates an expected call of OrderEventsSubscribe.
+func (mr *MockServiceMockRecorder) OrderEventsSubscribe(ctx, uid, lastID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrderAdd", reflect.TypeOf((*MockService)(nil).OrderAdd), uid, oid)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrderEventsSubscribe", reflect.TypeOf((*MockService)(nil).OrderEventsSubscribe), ctx, uid, lastID)
 }
 
 // OrderGet mocks base method.
-func (m *MockService) OrderGet(oid string) (models.Order, error) {
+func (m *MockService) OrderGet(ctx context.Context, oid string) (models.Order, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "OrderGet", oid)
+	ret := m.ctrl.Call(m, "OrderGet", ctx, oid)
 	ret0, _ := ret[0].(models.Order)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // OrderGet indicates an expected call of OrderGet.
-func (mr *MockServiceMockRecorder) OrderGet(oid interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) OrderGet(ctx, oid interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrderGet", reflect.TypeOf((*MockService)(nil).OrderGet), oid)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrderGet", reflect.TypeOf((*MockService)(nil).OrderGet), ctx, oid)
+}
+
+// OrdersAddBatch mocks base method.
+func (m *MockService) OrdersAddBatch(ctx context.Context, uid string, oids []string) (models.OrderBatchResults, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OrdersAddBatch", ctx, uid, oids)
+	ret0, _ := ret[0].(models.OrderBatchResults)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// OrdersAddBatch indicates an expected call of OrdersAddBatch.
+func (mr *MockServiceMockRecorder) OrdersAddBatch(ctx, uid, oids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrdersAddBatch", reflect.TypeOf((*MockService)(nil).OrdersAddBatch), ctx, uid, oids)
 }
 
 // OrdersGet mocks base method.
-func (m *MockService) OrdersGet(uid string) (models.Orders, error) {
+func (m *MockService) OrdersGet(ctx context.Context, uid string) (models.Orders, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "OrdersGet", uid)
+	ret := m.ctrl.Call(m, "OrdersGet", ctx, uid)
 	ret0, _ := ret[0].(models.Orders)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // OrdersGet indicates an expected call of OrdersGet.
-func (mr *MockServiceMockRecorder) OrdersGet(uid interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) OrdersGet(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrdersGet", reflect.TypeOf((*MockService)(nil).OrdersGet), ctx, uid)
+}
+
+// Readiness mocks base method.
+func (m *MockService) Readiness(ctx context.Context) models.HealthReport {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Readiness", ctx)
+	ret0, _ := ret[0].(models.HealthReport)
+	return ret0
+}
+
+// Readiness indicates an expected call of Readiness.
+func (mr *MockServiceMockRecorder) Readiness(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Readiness", reflect.TypeOf((*MockService)(nil).Readiness), ctx)
+}
+
+// ReferralStatsGet mocks base method.
+func (m *MockService) ReferralStatsGet(ctx context.Context, uid string) (models.ReferralStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReferralStatsGet", ctx, uid)
+	ret0, _ := ret[0].(models.ReferralStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReferralStatsGet indicates an expected call of ReferralStatsGet.
+func (mr *MockServiceMockRecorder) ReferralStatsGet(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReferralStatsGet", reflect.TypeOf((*MockService)(nil).ReferralStatsGet), ctx, uid)
+}
+
+// RiskAssess mocks base method.
+func (m *MockService) RiskAssess(ctx context.Context, uid, kind, subject string, amount float32) (models.RiskDecision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RiskAssess", ctx, uid, kind, subject, amount)
+	ret0, _ := ret[0].(models.RiskDecision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RiskAssess indicates an expected call of RiskAssess.
+func (mr *MockServiceMockRecorder) RiskAssess(ctx, uid, kind, subject, amount interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RiskAssess", reflect.TypeOf((*MockService)(nil).RiskAssess), ctx, uid, kind, subject, amount)
+}
+
+// Transfer mocks base method.
+func (m *MockService) Transfer(ctx context.Context, t models.Transfer) (models.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Transfer", ctx, t)
+	ret0, _ := ret[0].(models.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Transfer indicates an expected call of Transfer.
+func (mr *MockServiceMockRecorder) Transfer(ctx, t interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrdersGet", reflect.TypeOf((*MockService)(nil).OrdersGet), uid)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Transfer", reflect.TypeOf((*MockService)(nil).Transfer), ctx, t)
 }
 
 // UserAdd mocks base method.
-func (m *MockService) UserAdd(user models.User) error {
+func (m *MockService) UserAdd(ctx context.Context, user models.User) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UserAdd", user)
+	ret := m.ctrl.Call(m, "UserAdd", ctx, user)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // UserAdd indicates an expected call of UserAdd.
-func (mr *MockServiceMockRecorder) UserAdd(user interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) UserAdd(ctx, user interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserAdd", reflect.TypeOf((*MockService)(nil).UserAdd), user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserAdd", reflect.TypeOf((*MockService)(nil).UserAdd), ctx, user)
 }
 
 // UserGet mocks base method.
-func (m *MockService) UserGet(uid string) (models.User, error) {
+func (m *MockService) UserGet(ctx context.Context, uid string) (models.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UserGet", uid)
+	ret := m.ctrl.Call(m, "UserGet", ctx, uid)
 	ret0, _ := ret[0].(models.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // UserGet indicates an expected call of UserGet.
-func (mr *MockServiceMockRecorder) UserGet(uid interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) UserGet(ctx, uid interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserGet", reflect.TypeOf((*MockService)(nil).UserGet), uid)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserGet", reflect.TypeOf((*MockService)(nil).UserGet), ctx, uid)
+}
+
+// UserLocked mocks base method.
+func (m *MockService) UserLocked(ctx context.Context, uid string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserLocked", ctx, uid)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UserLocked indicates an expected call of UserLocked.
+func (mr *MockServiceMockRecorder) UserLocked(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserLocked", reflect.TypeOf((*MockService)(nil).UserLocked), ctx, uid)
 }
 
 // UserLogin mocks base method.
-func (m *MockService) UserLogin(uid, passwd string) (string, error) {
+func (m *MockService) UserLogin(ctx context.Context, uid, passwd, ip string) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UserLogin", uid, passwd)
+	ret := m.ctrl.Call(m, "UserLogin", ctx, uid, passwd, ip)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // UserLogin indicates an expected call of UserLogin.
-func (mr *MockServiceMockRecorder) UserLogin(uid, passwd interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) UserLogin(ctx, uid, passwd, ip interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserLogin", reflect.TypeOf((*MockService)(nil).UserLogin), ctx, uid, passwd, ip)
+}
+
+// UserPasswordChange mocks base method.
+func (m *MockService) UserPasswordChange(ctx context.Context, uid, oldPasswd, newPasswd string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserPasswordChange", ctx, uid, oldPasswd, newPasswd)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UserPasswordChange indicates an expected call of UserPasswordChange.
+func (mr *MockServiceMockRecorder) UserPasswordChange(ctx, uid, oldPasswd, newPasswd interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserPasswordChange", reflect.TypeOf((*MockService)(nil).UserPasswordChange), ctx, uid, oldPasswd, newPasswd)
+}
+
+// UserRole mocks base method.
+func (m *MockService) UserRole(ctx context.Context, uid string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserRole", ctx, uid)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UserRole indicates an expected call of UserRole.
+func (mr *MockServiceMockRecorder) UserRole(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserRole", reflect.TypeOf((*MockService)(nil).UserRole), ctx, uid)
+}
+
+// WebhookAdd mocks base method.
+func (m *MockService) WebhookAdd(ctx context.Context, wh models.Webhook) (models.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WebhookAdd", ctx, wh)
+	ret0, _ := ret[0].(models.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WebhookAdd indicates an expected call of WebhookAdd.
+func (mr *MockServiceMockRecorder) WebhookAdd(ctx, wh interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WebhookAdd", reflect.TypeOf((*MockService)(nil).WebhookAdd), ctx, wh)
+}
+
+// WebhookDelete mocks base method.
+func (m *MockService) WebhookDelete(ctx context.Context, uid string, id int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WebhookDelete", ctx, uid, id)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WebhookDelete indicates an expected call of WebhookDelete.
+func (mr *MockServiceMockRecorder) WebhookDelete(ctx, uid, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WebhookDelete", reflect.TypeOf((*MockService)(nil).WebhookDelete), ctx, uid, id)
+}
+
+// WebhookDeliveriesGet mocks base method.
+func (m *MockService) WebhookDeliveriesGet(ctx context.Context, uid string, id int64) (models.WebhookDeliveries, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WebhookDeliveriesGet", ctx, uid, id)
+	ret0, _ := ret[0].(models.WebhookDeliveries)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WebhookDeliveriesGet indicates an expected call of WebhookDeliveriesGet.
+func (mr *MockServiceMockRecorder) WebhookDeliveriesGet(ctx, uid, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WebhookDeliveriesGet", reflect.TypeOf((*MockService)(nil).WebhookDeliveriesGet), ctx, uid, id)
+}
+
+// WebhooksGet mocks base method.
+func (m *MockService) WebhooksGet(ctx context.Context, uid string) (models.Webhooks, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WebhooksGet", ctx, uid)
+	ret0, _ := ret[0].(models.Webhooks)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WebhooksGet indicates an expected call of WebhooksGet.
+func (mr *MockServiceMockRecorder) WebhooksGet(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WebhooksGet", reflect.TypeOf((*MockService)(nil).WebhooksGet), ctx, uid)
+}
+
+// WithdrawalReverse mocks base method.
+func (m *MockService) WithdrawalReverse(ctx context.Context, r models.WithdrawalReversal) (models.WithdrawalReversal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithdrawalReverse", ctx, r)
+	ret0, _ := ret[0].(models.WithdrawalReversal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WithdrawalReverse indicates an expected call of WithdrawalReverse.
+func (mr *MockServiceMockRecorder) WithdrawalReverse(ctx, r interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserLogin", reflect.TypeOf((*MockService)(nil).UserLogin), uid, passwd)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithdrawalReverse", reflect.TypeOf((*MockService)(nil).WithdrawalReverse), ctx, r)
 }
 
 // WithdrawalsGet mocks base method.
-func (m *MockService) WithdrawalsGet(uid string) (models.Withdrawals, error) {
+func (m *MockService) WithdrawalsGet(ctx context.Context, uid string) (models.Withdrawals, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "WithdrawalsGet", uid)
+	ret := m.ctrl.Call(m, "WithdrawalsGet", ctx, uid)
 	ret0, _ := ret[0].(models.Withdrawals)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // WithdrawalsGet indicates an expected call of WithdrawalsGet.
-func (mr *MockServiceMockRecorder) WithdrawalsGet(uid interface{}) *gomock.Call {
+func (mr *MockServiceMockRecorder) WithdrawalsGet(ctx, uid interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithdrawalsGet", reflect.TypeOf((*MockService)(nil).WithdrawalsGet), uid)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithdrawalsGet", reflect.TypeOf((*MockService)(nil).WithdrawalsGet), ctx, uid)
 }
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"go.uber.org/zap"
+)
+
+//go:embed openapi.json
+var openAPIDoc []byte
+
+// LoadOpenAPI parses and validates the embedded OpenAPI document.
+func LoadOpenAPI() (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(openAPIDoc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load OpenAPI document: %w", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("OpenAPI document is invalid: %w", err)
+	}
+	return doc, nil
+}
+
+func (gr *GophermartHandler) OpenAPIGet(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+
+	if _, err := rw.Write(openAPIDoc); err != nil {
+		gr.logger.Sugar().Error("failed to write OpenAPI document", zap.Error(err))
+		return
+	}
+}
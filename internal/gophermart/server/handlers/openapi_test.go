@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/helpers"
+	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
+	mock_handlers "github.com/vkupriya/go-gophermart/internal/gophermart/server/handlers/mocks"
+)
+
+func TestOpenAPIRoutes(t *testing.T) {
+	spec, err := LoadOpenAPI()
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	cfg := &models.Config{Logger: zap.NewNop(), JWTKey: "test", MetricsPublic: true}
+	r, err := NewGophermartRouter(cfg, NewGophermartHandler(mock_handlers.NewMockService(ctrl), cfg))
+	require.NoError(t, err)
+
+	var routes []string
+	err = chi.Walk(r, func(method string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routes = append(routes, method+" "+route)
+		return nil
+	})
+	require.NoError(t, err)
+
+	var documented []string
+	for path, item := range spec.Paths.Map() {
+		for method := range item.Operations() {
+			documented = append(documented, method+" "+path)
+		}
+	}
+
+	sort.Strings(routes)
+	sort.Strings(documented)
+	assert.Equal(t, documented, routes, "router and OpenAPI document must declare the same routes")
+}
+
+func TestOpenAPIValidation(t *testing.T) {
+	cfg := &models.Config{Logger: zap.NewNop(), JWTKey: "test", JWTTokenTTL: time.Minute}
+	token, err := helpers.CreateJWTString(cfg, "user01", models.RoleUser)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		mockSvc      func(*gomock.Controller) *mock_handlers.MockService
+		name         string
+		path         string
+		contentType  string
+		body         string
+		expectedCode int
+	}{
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserGet(gomock.Any(), "user01").Return(models.User{UserID: "user01", Accrual: 500}, nil)
+				s.EXPECT().RiskAssess(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(
+					models.RiskDecision{Action: models.RiskAllow}, nil).AnyTimes()
+				s.EXPECT().AccrualWithdraw(gomock.Any(), gomock.Any()).Return(nil)
+				return s
+			},
+			name:         "#withdraw_valid_OK",
+			path:         "/api/user/balance/withdraw",
+			contentType:  "application/json",
+			body:         `{"order":"12345678903","sum":250}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			mockSvc:      mock_handlers.NewMockService,
+			name:         "#withdraw_unknown_field_FAIL",
+			path:         "/api/user/balance/withdraw",
+			contentType:  "application/json",
+			body:         `{"order":"12345678903","sum":250,"extra":true}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			mockSvc:      mock_handlers.NewMockService,
+			name:         "#withdraw_missing_sum_FAIL",
+			path:         "/api/user/balance/withdraw",
+			contentType:  "application/json",
+			body:         `{"order":"12345678903"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			mockSvc:      mock_handlers.NewMockService,
+			name:         "#withdraw_wrong_type_FAIL",
+			path:         "/api/user/balance/withdraw",
+			contentType:  "application/json",
+			body:         `{"order":12345678903,"sum":"250"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			mockSvc:      mock_handlers.NewMockService,
+			name:         "#transfer_no_idempotency_key_FAIL",
+			path:         "/api/user/balance/transfer",
+			contentType:  "application/json",
+			body:         `{"to":"user02","amount":25}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			mockSvc:      mock_handlers.NewMockService,
+			name:         "#register_unknown_field_FAIL",
+			path:         "/api/user/register",
+			contentType:  "application/json",
+			body:         `{"login":"user01","password":"secret","admin":true}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			mockSvc: func(c *gomock.Controller) *mock_handlers.MockService {
+				s := mock_handlers.NewMockService(c)
+				s.EXPECT().UserAdd(gomock.Any(), models.User{UserID: "user02", Password: "secret", ReferralCode: "NOPE"}).
+					Return(fmt.Errorf("failed to register user user02: %w", models.ErrInvalidReferral))
+				return s
+			},
+			name:         "#register_unknown_referral_FAIL",
+			path:         "/api/user/register",
+			contentType:  "application/json",
+			body:         `{"login":"user02","password":"secret","referral_code":"NOPE"}`,
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			svc := tc.mockSvc(ctrl)
+			svc.EXPECT().UserLocked(gomock.Any(), "user01").Return(false, nil).AnyTimes()
+			r, err := NewGophermartRouter(cfg, NewGophermartHandler(svc, cfg))
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+
+			r.ServeHTTP(w, req)
+			res := w.Result()
+			defer func() {
+				if err := res.Body.Close(); err != nil {
+					assert.Error(t, err)
+				}
+			}()
+			_, _ = io.ReadAll(res.Body)
+
+			assert.Equal(t, tc.expectedCode, res.StatusCode, "Response code didn't match expected")
+		})
+	}
+}
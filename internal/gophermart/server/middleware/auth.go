@@ -2,21 +2,44 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
+	"go.uber.org/zap"
+
 	"github.com/vkupriya/go-gophermart/internal/gophermart/helpers"
+	"github.com/vkupriya/go-gophermart/internal/gophermart/logging"
 	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
 )
 
 type CtxKey struct{}
 
+// CtxRoleKey holds the role claim of the authenticated user.
+type CtxRoleKey struct{}
+
+// UserLocker reports whether an account has been locked, so that tokens
+// issued before the lock stop working immediately. Missing accounts are
+// reported with models.ErrNotFound.
+type UserLocker interface {
+	UserLocked(ctx context.Context, uid string) (bool, error)
+}
+
+// UserAccess also reports the current role of an account, so that tokens
+// issued before the admin role was revoked stop granting it.
+type UserAccess interface {
+	UserLocker
+	UserRole(ctx context.Context, uid string) (string, error)
+}
+
 type MiddlewareAuth struct {
 	config *models.Config
+	users  UserAccess
 }
 
-func NewMiddlewareAuth(c *models.Config) *MiddlewareAuth {
+func NewMiddlewareAuth(c *models.Config, users UserAccess) *MiddlewareAuth {
 	return &MiddlewareAuth{
 		config: c,
+		users:  users,
 	}
 }
 
@@ -36,8 +59,50 @@ func (m *MiddlewareAuth) Auth(h http.Handler) http.Handler {
 			return
 		}
 
+		locked, err := m.users.UserLocked(r.Context(), claims.UserID)
+		if err != nil {
+			if errors.Is(err, models.ErrNotFound) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if locked {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
 		ctx := context.WithValue(r.Context(), CtxKey{}, claims.UserID)
+		ctx = context.WithValue(ctx, CtxRoleKey{}, claims.Role)
+		ctx = logging.With(ctx, zap.String("user_id", claims.UserID))
 		h.ServeHTTP(w, r.WithContext(ctx))
 	}
 	return http.HandlerFunc(logFn)
 }
+
+// Admin rejects requests of users without the admin role. The role claim of
+// the token is confirmed against the current role of the user, so that a
+// revoked admin loses access immediately. It must run after Auth.
+func (m *MiddlewareAuth) Admin(h http.Handler) http.Handler {
+	adminFn := func(w http.ResponseWriter, r *http.Request) {
+		role, _ := r.Context().Value(CtxRoleKey{}).(string)
+		if role != models.RoleAdmin {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		uid, _ := r.Context().Value(CtxKey{}).(string)
+		role, err := m.users.UserRole(r.Context(), uid)
+		if err != nil {
+			logging.FromContext(r.Context(), m.config.Logger).Error("failed to get user role", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if role != models.RoleAdmin {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(adminFn)
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/logging"
+	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
+)
+
+type MiddlewareClientCert struct {
+	logger   *zap.Logger
+	required bool
+}
+
+func NewMiddlewareClientCert(c *models.Config) *MiddlewareClientCert {
+	return &MiddlewareClientCert{
+		logger:   c.Logger,
+		required: c.TLSClientCAFile != "",
+	}
+}
+
+// ClientCert rejects requests without a client certificate verified against
+// the client CA. It does nothing when no client CA is configured.
+func (m *MiddlewareClientCert) ClientCert(h http.Handler) http.Handler {
+	if !m.required {
+		return h
+	}
+	certFn := func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			logging.FromContext(r.Context(), m.logger).Warn("request without a verified client certificate")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(certFn)
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
+)
+
+func TestClientCert(t *testing.T) {
+	verified := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+
+	testCases := []struct {
+		tls          *tls.ConnectionState
+		name         string
+		clientCA     string
+		expectedCode int
+	}{
+		{name: "#no_client_ca", expectedCode: http.StatusOK},
+		{name: "#plain_http", clientCA: "ca.crt", expectedCode: http.StatusForbidden},
+		{name: "#no_client_cert", clientCA: "ca.crt", tls: &tls.ConnectionState{}, expectedCode: http.StatusForbidden},
+		{name: "#verified", clientCA: "ca.crt", tls: verified, expectedCode: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewMiddlewareClientCert(&models.Config{Logger: zap.NewNop(), TLSClientCAFile: tc.clientCA})
+			h := m.ClientCert(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/users", http.NoBody)
+			req.TLS = tc.tls
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedCode, rec.Code)
+		})
+	}
+}
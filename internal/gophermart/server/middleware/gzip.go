@@ -8,6 +8,8 @@ import (
 	"strings"
 
 	"go.uber.org/zap"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/logging"
 )
 
 const (
@@ -39,7 +41,7 @@ func (w gzipWriter) Write(b []byte) (int, error) {
 
 func (l *MiddlewareGzip) GzipHandler(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		logger := l.logger
+		logger := logging.FromContext(r.Context(), l.logger)
 		contentEncoding := r.Header.Get("Content-Encoding")
 		sendsGzip := strings.Contains(contentEncoding, compressionLib)
 		if sendsGzip {
@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/logging"
+	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
+)
+
+const (
+	idempotencyHeader    string = "Idempotency-Key"
+	idempotencyReplayed  string = "Idempotent-Replayed"
+	idempotencyKeyMaxLen int    = 255
+)
+
+type IdempotencyStore interface {
+	IdempotencyReserve(ctx context.Context, rec models.IdempotencyRecord) (models.IdempotencyRecord, bool, error)
+	IdempotencySave(ctx context.Context, rec models.IdempotencyRecord) error
+	IdempotencyRelease(ctx context.Context, uid string, key string) error
+}
+
+type MiddlewareIdempotency struct {
+	store  IdempotencyStore
+	logger *zap.Logger
+}
+
+func NewMiddlewareIdempotency(zl *zap.Logger, s IdempotencyStore) *MiddlewareIdempotency {
+	return &MiddlewareIdempotency{
+		store:  s,
+		logger: zl,
+	}
+}
+
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	body   bytes.Buffer
+	status int
+}
+
+func (r *recordingResponseWriter) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(b)
+	size, err := r.ResponseWriter.Write(b)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write into http.ResponseWriter: %w", err)
+	}
+	return size, nil
+}
+
+func (r *recordingResponseWriter) WriteHeader(statusCode int) {
+	if r.status == 0 {
+		r.status = statusCode
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *recordingResponseWriter) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Idempotency replays the stored response when a state-changing request is
+// retried with the same Idempotency-Key and body. It must run after Auth.
+func (m *MiddlewareIdempotency) Idempotency(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), m.logger)
+		key := r.Header.Get(idempotencyHeader)
+		if key == "" || r.Method == http.MethodGet || r.Method == http.MethodHead {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > idempotencyKeyMaxLen {
+			logger.Sugar().Errorf("idempotency key exceeds %d characters", idempotencyKeyMaxLen)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		uid, ok := r.Context().Value(CtxKey{}).(string)
+		if !ok {
+			logger.Sugar().Error("failed to get user from context value")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			logger.Sugar().Error("failed to read request body", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := sha256.New()
+		hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+		hash.Write(body)
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+
+		rec, created, err := m.store.IdempotencyReserve(r.Context(), models.IdempotencyRecord{
+			UserID:      uid,
+			Key:         key,
+			RequestHash: requestHash,
+		})
+		if err != nil {
+			logger.Sugar().Error("failed to reserve idempotency key", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if !created {
+			switch {
+			case rec.RequestHash != requestHash:
+				logger.Sugar().Errorf("idempotency key %s reused with a different request", key)
+				w.WriteHeader(http.StatusUnprocessableEntity)
+			case rec.Status == 0:
+				logger.Sugar().Errorf("request with idempotency key %s is still in progress", key)
+				w.WriteHeader(http.StatusConflict)
+			default:
+				if rec.ContentType != "" {
+					w.Header().Set("Content-Type", rec.ContentType)
+				}
+				w.Header().Set(idempotencyReplayed, "true")
+				w.WriteHeader(rec.Status)
+				if _, err := w.Write(rec.Body); err != nil {
+					logger.Sugar().Error("failed to write replayed response", zap.Error(err))
+				}
+			}
+			return
+		}
+
+		// the outcome is stored even if the client has gone away meanwhile
+		ctx := context.WithoutCancel(r.Context())
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			// the handler panicked, release the key so that the client can retry
+			if err := m.store.IdempotencyRelease(ctx, uid, key); err != nil {
+				logger.Sugar().Error("failed to release idempotency key", zap.Error(err))
+			}
+		}()
+
+		rw := &recordingResponseWriter{ResponseWriter: w}
+		h.ServeHTTP(rw, r)
+		completed = true
+		if rw.status == 0 {
+			rw.status = http.StatusOK
+		}
+
+		// server errors are not cached so that the client can retry
+		if rw.status >= http.StatusInternalServerError {
+			if err := m.store.IdempotencyRelease(ctx, uid, key); err != nil {
+				logger.Sugar().Error("failed to release idempotency key", zap.Error(err))
+			}
+			return
+		}
+		rec.Status = rw.status
+		rec.ContentType = w.Header().Get("Content-Type")
+		rec.Body = rw.body.Bytes()
+		if err := m.store.IdempotencySave(ctx, rec); err != nil {
+			logger.Sugar().Error("failed to save idempotent response", zap.Error(err))
+		}
+	})
+}
+
+// Required rejects requests without an Idempotency-Key, for routes where a
+// retried request must never be applied twice.
+func (m *MiddlewareIdempotency) Required(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(idempotencyHeader) == "" {
+			logging.FromContext(r.Context(), m.logger).Warn("request without a required idempotency key")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,188 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
+)
+
+type memIdempotencyStore struct {
+	records map[string]models.IdempotencyRecord
+}
+
+func (m *memIdempotencyStore) IdempotencyReserve(_ context.Context, rec models.IdempotencyRecord) (
+	models.IdempotencyRecord, bool, error) {
+	if stored, ok := m.records[rec.UserID+rec.Key]; ok {
+		return stored, false, nil
+	}
+	m.records[rec.UserID+rec.Key] = rec
+	return rec, true, nil
+}
+
+func (m *memIdempotencyStore) IdempotencySave(_ context.Context, rec models.IdempotencyRecord) error {
+	m.records[rec.UserID+rec.Key] = rec
+	return nil
+}
+
+func (m *memIdempotencyStore) IdempotencyRelease(_ context.Context, uid string, key string) error {
+	delete(m.records, uid+key)
+	return nil
+}
+
+func TestIdempotency(t *testing.T) {
+	calls := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if strings.Contains(r.URL.Path, "fail") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	})
+
+	store := &memIdempotencyStore{records: make(map[string]models.IdempotencyRecord)}
+	h := NewMiddlewareIdempotency(zap.NewNop(), store).Idempotency(next)
+
+	testCases := []struct {
+		name          string
+		path          string
+		key           string
+		body          string
+		expectedCode  int
+		expectedBody  string
+		expectedCalls int
+		replayed      bool
+	}{
+		{
+			name:          "#first_request_OK",
+			path:          "/api/user/balance/withdraw",
+			key:           "key-1",
+			body:          `{"order":"2377225624","sum":10}`,
+			expectedCode:  http.StatusAccepted,
+			expectedBody:  `{"ok":true}`,
+			expectedCalls: 1,
+		},
+		{
+			name:          "#same_request_replayed_OK",
+			path:          "/api/user/balance/withdraw",
+			key:           "key-1",
+			body:          `{"order":"2377225624","sum":10}`,
+			expectedCode:  http.StatusAccepted,
+			expectedBody:  `{"ok":true}`,
+			expectedCalls: 1,
+			replayed:      true,
+		},
+		{
+			name:          "#different_body_FAIL",
+			path:          "/api/user/balance/withdraw",
+			key:           "key-1",
+			body:          `{"order":"2377225624","sum":20}`,
+			expectedCode:  http.StatusUnprocessableEntity,
+			expectedCalls: 1,
+		},
+		{
+			name:          "#no_key_OK",
+			path:          "/api/user/balance/withdraw",
+			body:          `{"order":"2377225624","sum":10}`,
+			expectedCode:  http.StatusAccepted,
+			expectedBody:  `{"ok":true}`,
+			expectedCalls: 2,
+		},
+		{
+			name:          "#server_error_not_stored",
+			path:          "/fail",
+			key:           "key-2",
+			expectedCode:  http.StatusInternalServerError,
+			expectedCalls: 3,
+		},
+		{
+			name:          "#server_error_retried",
+			path:          "/fail",
+			key:           "key-2",
+			expectedCode:  http.StatusInternalServerError,
+			expectedCalls: 4,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+			if tc.key != "" {
+				r.Header.Set(idempotencyHeader, tc.key)
+			}
+			r = r.WithContext(context.WithValue(r.Context(), CtxKey{}, "user01"))
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, r)
+			res := w.Result()
+			defer func() {
+				if err := res.Body.Close(); err != nil {
+					assert.Error(t, err)
+				}
+			}()
+
+			b, _ := io.ReadAll(res.Body)
+			assert.Equal(t, tc.expectedCode, res.StatusCode, "Response code didn't match expected")
+			assert.Equal(t, tc.expectedCalls, calls, "handler calls didn't match expected")
+			if tc.expectedBody != "" {
+				assert.Equal(t, tc.expectedBody, string(b))
+			}
+			if tc.replayed {
+				assert.Equal(t, "true", res.Header.Get(idempotencyReplayed))
+			}
+		})
+	}
+}
+
+func TestIdempotencyPanicReleasesKey(t *testing.T) {
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("handler failed")
+	})
+	store := &memIdempotencyStore{records: make(map[string]models.IdempotencyRecord)}
+	h := NewMiddlewareIdempotency(zap.NewNop(), store).Idempotency(next)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", strings.NewReader(`{}`))
+	r.Header.Set(idempotencyHeader, "key-1")
+	r = r.WithContext(context.WithValue(r.Context(), CtxKey{}, "user01"))
+
+	assert.Panics(t, func() { h.ServeHTTP(httptest.NewRecorder(), r) })
+	assert.Empty(t, store.records)
+}
+
+func TestIdempotencyRequired(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+	h := NewMiddlewareIdempotency(zap.NewNop(), nil).Required(next)
+
+	testCases := []struct {
+		name         string
+		key          string
+		expectedCode int
+	}{
+		{name: "#no_key", expectedCode: http.StatusBadRequest},
+		{name: "#key", key: "key-1", expectedCode: http.StatusAccepted},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/user/withdrawals/2377225624/reversal", http.NoBody)
+			if tc.key != "" {
+				r.Header.Set(idempotencyHeader, tc.key)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			assert.Equal(t, tc.expectedCode, w.Code)
+		})
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"go.uber.org/zap"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/logging"
 )
 
 type (
@@ -44,9 +46,13 @@ func (r *loggingResponseWriter) WriteHeader(statusCode int) {
 	r.responseData.status = statusCode
 }
 
+func (r *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
 func (m *MiddlewareLogger) Logging(h http.Handler) http.Handler {
 	logFn := func(w http.ResponseWriter, r *http.Request) {
-		logger := m.logger
+		logger := logging.FromContext(r.Context(), m.logger)
 
 		start := time.Now()
 
@@ -65,13 +71,12 @@ func (m *MiddlewareLogger) Logging(h http.Handler) http.Handler {
 
 		h.ServeHTTP(&lw, r)
 
-		duration := time.Since(start)
-		logger.Sugar().Infoln(
-			"uri", uri,
-			"method", method,
-			"status", responseData.status,
-			"duration", duration,
-			"size", responseData.size,
+		logger.Info("request completed",
+			zap.String("uri", uri),
+			zap.String("method", method),
+			zap.Int("status", responseData.status),
+			zap.Duration("duration", time.Since(start)),
+			zap.Int("size", responseData.size),
 		)
 	}
 	return http.HandlerFunc(logFn)
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/metrics"
+)
+
+// unmatchedRoute labels requests that did not match any route, so that
+// scanners probing random paths do not blow up the label cardinality.
+const unmatchedRoute string = "unmatched"
+
+type MiddlewareMetrics struct {
+	metrics *metrics.Metrics
+}
+
+func NewMiddlewareMetrics(m *metrics.Metrics) *MiddlewareMetrics {
+	return &MiddlewareMetrics{
+		metrics: m,
+	}
+}
+
+func (m *MiddlewareMetrics) Metrics(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		responseData := &responseData{
+			status: http.StatusOK,
+		}
+		lw := loggingResponseWriter{
+			ResponseWriter: w,
+			responseData:   responseData,
+		}
+
+		h.ServeHTTP(&lw, r)
+
+		// the route pattern is only known once chi has routed the request
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		m.metrics.ObserveHTTP(route, r.Method, responseData.status, time.Since(start))
+	})
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/metrics"
+)
+
+func TestMetrics(t *testing.T) {
+	m := metrics.New()
+	counted := 0
+	m.RegisterOrders(func(context.Context) (map[string]int64, error) {
+		counted++
+		return map[string]int64{"NEW": 2, "PROCESSED": 5}, nil
+	})
+
+	r := chi.NewRouter()
+	r.Use(NewMiddlewareMetrics(m).Metrics)
+	r.Get("/api/user/webhooks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	r.Method(http.MethodGet, "/metrics", m.Handler())
+
+	for _, path := range []string{"/api/user/webhooks/1", "/api/user/webhooks/2", "/random/probe"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, http.NoBody))
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+	require.Equal(t, http.StatusOK, rec.Code)
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(body),
+		`gophermart_http_requests_total{method="GET",route="/api/user/webhooks/{id}",status="404"} 2`)
+	assert.Contains(t, string(body),
+		`gophermart_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.Contains(t, string(body), `gophermart_orders{status="PROCESSED"} 5`)
+
+	// a second scrape within the cache TTL does not count the orders again
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+	assert.Equal(t, 1, counted)
+}
+
+func TestMetricsDisabled(t *testing.T) {
+	var m *metrics.Metrics
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	})
+	rec := httptest.NewRecorder()
+	NewMiddlewareMetrics(m).Metrics(next).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", http.NoBody))
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	m.AccrualBackoff()
+	m.Credited(10)
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/logging"
+)
+
+type MiddlewareOpenAPI struct {
+	spec   *openapi3.T
+	logger *zap.Logger
+}
+
+func NewMiddlewareOpenAPI(zl *zap.Logger, spec *openapi3.T) *MiddlewareOpenAPI {
+	return &MiddlewareOpenAPI{
+		spec:   spec,
+		logger: zl,
+	}
+}
+
+// Validate checks the request parameters and body against the operation
+// of the matched chi route. It must be used inside a route group, so that the
+// route pattern is known, and after any middleware that decodes the body.
+// Authentication is left to the Auth middleware.
+func (m *MiddlewareOpenAPI) Validate(h http.Handler) http.Handler {
+	opts := &openapi3filter.Options{
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		MultiError:         true,
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := logging.FromContext(r.Context(), m.logger)
+		rctx := chi.RouteContext(r.Context())
+		if rctx == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		pattern := rctx.RoutePattern()
+
+		pathItem := m.spec.Paths.Find(pattern)
+		if pathItem == nil || pathItem.GetOperation(r.Method) == nil {
+			logger.Sugar().Errorf("route %s %s is missing in the OpenAPI document", r.Method, pattern)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		params := make(map[string]string, len(rctx.URLParams.Keys))
+		for i, k := range rctx.URLParams.Keys {
+			params[k] = rctx.URLParams.Values[i]
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: params,
+			Options:    opts,
+			Route: &routers.Route{
+				Spec:      m.spec,
+				Path:      pattern,
+				PathItem:  pathItem,
+				Method:    r.Method,
+				Operation: pathItem.GetOperation(r.Method),
+			},
+		}
+		if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+			logger.Sugar().Errorf("request does not match the OpenAPI document: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
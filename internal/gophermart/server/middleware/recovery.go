@@ -6,6 +6,8 @@ import (
 	"net/http"
 
 	"go.uber.org/zap"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/logging"
 )
 
 type MiddlewareRecovery struct {
@@ -20,7 +22,7 @@ func NewMiddlewareRecovery(zl *zap.Logger) *MiddlewareRecovery {
 
 func (m *MiddlewareRecovery) Recovery(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		logger := m.logger
+		logger := logging.FromContext(r.Context(), m.logger)
 		defer func() {
 			errRec := recover()
 			if errRec != nil {
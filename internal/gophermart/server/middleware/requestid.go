@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/logging"
+)
+
+const HeaderRequestID string = "X-Request-ID"
+
+type MiddlewareRequestID struct {
+	logger *zap.Logger
+}
+
+func NewMiddlewareRequestID(zl *zap.Logger) *MiddlewareRequestID {
+	return &MiddlewareRequestID{
+		logger: zl,
+	}
+}
+
+// RequestID tags every request with the X-Request-ID of the client, or a new
+// one if the header is missing or malformed, and echoes it in the response.
+// The request logger stored in the context carries the request ID, the route
+// pattern and the trace ID; Auth adds the user ID.
+func (m *MiddlewareRequestID) RequestID(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := logging.RequestID(r.Header.Get(HeaderRequestID))
+		w.Header().Set(HeaderRequestID, id)
+
+		logger := m.logger.With(zap.String("request_id", id))
+		if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+			logger = logger.With(zap.String("trace_id", sc.TraceID().String()))
+		}
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			logger = logger.WithLazy(zap.Stringer("route", routePattern{rctx}))
+		}
+
+		ctx := logging.NewContext(r.Context(), logger)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// routePattern renders the chi route pattern on first use of the request
+// logger, as the pattern is only complete once the request has been routed.
+type routePattern struct {
+	rctx *chi.Context
+}
+
+func (p routePattern) String() string {
+	if route := p.rctx.RoutePattern(); route != "" {
+		return route
+	}
+	return unmatchedRoute
+}
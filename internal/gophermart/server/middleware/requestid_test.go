@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/helpers"
+	"github.com/vkupriya/go-gophermart/internal/gophermart/logging"
+	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
+)
+
+type unlockedUsers struct{}
+
+func (unlockedUsers) UserLocked(context.Context, string) (bool, error) {
+	return false, nil
+}
+
+func (unlockedUsers) UserRole(context.Context, string) (string, error) {
+	return models.RoleUser, nil
+}
+
+func TestRequestID(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	zl := zap.New(core)
+	cfg := &models.Config{JWTKey: "test-key", JWTTokenTTL: time.Hour}
+
+	r := chi.NewRouter()
+	r.Use(NewMiddlewareRequestID(zl).RequestID)
+	r.Use(NewMiddlewareLogger(zl).Logging)
+	r.Group(func(r chi.Router) {
+		r.Use(NewMiddlewareAuth(cfg, unlockedUsers{}).Auth)
+		r.Get("/api/user/webhooks/{id}", func(w http.ResponseWriter, r *http.Request) {
+			logging.FromContext(r.Context(), nil).Info("handler")
+		})
+	})
+
+	token, err := helpers.CreateJWTString(cfg, "user01", models.RoleUser)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		requestID string
+		wantEcho  bool
+	}{
+		{name: "#incoming", requestID: "req-42", wantEcho: true},
+		{name: "#missing", requestID: ""},
+		{name: "#malformed", requestID: "req\n42"},
+		{name: "#too_long", requestID: strings.Repeat("a", 129)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			logs.TakeAll()
+			req := httptest.NewRequest(http.MethodGet, "/api/user/webhooks/7", http.NoBody)
+			req.Header.Set("Authorization", "Bearer "+token)
+			if tc.requestID != "" {
+				req.Header.Set(HeaderRequestID, tc.requestID)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			id := rec.Header().Get(HeaderRequestID)
+			require.NotEmpty(t, id)
+			if tc.wantEcho {
+				assert.Equal(t, tc.requestID, id)
+			} else {
+				assert.NotEqual(t, tc.requestID, id)
+			}
+
+			entries := logs.All()
+			require.Len(t, entries, 2)
+			handler := entries[0].ContextMap()
+			assert.Equal(t, id, handler["request_id"])
+			assert.Equal(t, "user01", handler["user_id"])
+			assert.Equal(t, "/api/user/webhooks/{id}", handler["route"])
+
+			access := entries[1].ContextMap()
+			assert.Equal(t, "request completed", entries[1].Message)
+			assert.Equal(t, id, access["request_id"])
+			assert.Equal(t, "/api/user/webhooks/{id}", access["route"])
+			assert.EqualValues(t, http.StatusOK, access["status"])
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName string = "github.com/vkupriya/go-gophermart"
+
+type MiddlewareTracing struct {
+	tracer trace.Tracer
+}
+
+func NewMiddlewareTracing(tp trace.TracerProvider) *MiddlewareTracing {
+	return &MiddlewareTracing{
+		tracer: tp.Tracer(tracerName),
+	}
+}
+
+// Tracing starts a server span for every request, continuing the trace of
+// the client if the request carries a traceparent header. The span is named
+// after the route pattern once chi has routed the request.
+func (m *MiddlewareTracing) Tracing(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := m.tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPRequestMethodKey.String(r.Method), semconv.URLPath(r.URL.Path)))
+		defer span.End()
+
+		responseData := &responseData{
+			status: http.StatusOK,
+		}
+		lw := loggingResponseWriter{
+			ResponseWriter: w,
+			responseData:   responseData,
+		}
+
+		h.ServeHTTP(&lw, r.WithContext(ctx))
+
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route), semconv.HTTPResponseStatusCode(responseData.status))
+		if responseData.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(responseData.status))
+		}
+	})
+}
@@ -7,9 +7,26 @@ import (
 	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
 )
 
+// NewServer returns the API server. Timeouts bound slow clients, the write
+// deadline is lifted by handlers of long-lived streams.
 func NewServer(c *models.Config, gr chi.Router) *http.Server {
 	return &http.Server{
-		Addr:    c.Address,
-		Handler: gr,
+		Addr:              c.Address,
+		Handler:           gr,
+		ReadHeaderTimeout: c.ReadHeaderTimeout,
+		ReadTimeout:       c.ReadTimeout,
+		WriteTimeout:      c.WriteTimeout,
+		IdleTimeout:       c.IdleTimeout,
+		MaxHeaderBytes:    int(c.MaxHeaderBytes),
 	}
 }
+
+// NewMetricsServer returns the metrics listener, it has the timeouts of the
+// API server.
+func NewMetricsServer(c *models.Config, h http.Handler) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", h)
+	srv := NewServer(c, nil)
+	srv.Addr, srv.Handler = c.MetricsAddress, mux
+	return srv
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
+)
+
+// Certificates are usually rotated by writing several files, or by swapping a
+// symlinked directory, the reload waits for the files to settle.
+const certReloadDebounce time.Duration = time.Second
+
+// Certificates holds the server key pair and the client CA pool. They are
+// reloaded when their files change, new TLS handshakes use the new ones.
+type Certificates struct {
+	logger       *zap.Logger
+	cert         atomic.Pointer[tls.Certificate]
+	clientCAs    atomic.Pointer[x509.CertPool]
+	certFile     string
+	keyFile      string
+	clientCAFile string
+}
+
+// NewCertificates loads the TLS files set in the configuration.
+func NewCertificates(c *models.Config) (*Certificates, error) {
+	cs := &Certificates{
+		logger:       c.Logger,
+		certFile:     c.TLSCertFile,
+		keyFile:      c.TLSKeyFile,
+		clientCAFile: c.TLSClientCAFile,
+	}
+	if err := cs.load(); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+func (cs *Certificates) load() error {
+	cert, err := tls.LoadX509KeyPair(cs.certFile, cs.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load server key pair: %w", err)
+	}
+
+	var pool *x509.CertPool
+	if cs.clientCAFile != "" {
+		pem, err := os.ReadFile(cs.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("no certificates found in client CA file")
+		}
+	}
+
+	cs.cert.Store(&cert)
+	cs.clientCAs.Store(pool)
+	return nil
+}
+
+// TLSConfig returns the server TLS configuration. HTTP/2 is negotiated with
+// clients supporting it. With a client CA, client certificates are verified
+// when presented; routes requiring one check the verified chains.
+func (cs *Certificates) TLSConfig() *tls.Config {
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return cs.cert.Load(), nil
+		},
+	}
+	if cs.clientCAFile == "" {
+		return base
+	}
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c := base.Clone()
+		c.ClientAuth = tls.VerifyClientCertIfGiven
+		c.ClientCAs = cs.clientCAs.Load()
+		return c, nil
+	}
+	return base
+}
+
+// Run reloads the certificates when their files change, until ctx is done.
+// Invalid files are logged and the current certificates kept.
+func (cs *Certificates) Run(ctx context.Context) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create certificate watcher: %w", err)
+	}
+	defer func() {
+		if err := w.Close(); err != nil {
+			cs.logger.Sugar().Errorw("failed to close certificate watcher", zap.Error(err))
+		}
+	}()
+
+	// watch the directories, as files replaced by a rename drop their own watch
+	dirs := map[string]bool{}
+	for _, f := range []string{cs.certFile, cs.keyFile, cs.clientCAFile} {
+		if f == "" || dirs[filepath.Dir(f)] {
+			continue
+		}
+		dirs[filepath.Dir(f)] = true
+		if err := w.Add(filepath.Dir(f)); err != nil {
+			return fmt.Errorf("failed to watch certificate directory: %w", err)
+		}
+	}
+
+	debounce := time.NewTimer(certReloadDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev := <-w.Events:
+			if !ev.Has(fsnotify.Chmod) {
+				debounce.Reset(certReloadDebounce)
+			}
+		case err := <-w.Errors:
+			cs.logger.Sugar().Warnw("certificate watcher error", zap.Error(err))
+		case <-debounce.C:
+			if err := cs.load(); err != nil {
+				cs.logger.Error("failed to reload TLS certificates, keeping the current ones", zap.Error(err))
+				continue
+			}
+			cs.logger.Info("TLS certificates reloaded")
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+// newTestCert issues a certificate signed by parent, or a self-signed CA.
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+func (c *testCert) write(t *testing.T, certFile, keyFile string) {
+	t.Helper()
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0o600))
+	if keyFile != "" {
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+		require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+	}
+}
+
+func (c *testCert) tls() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+func TestCertificates(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &models.Config{
+		Logger:          zap.NewNop(),
+		TLSCertFile:     filepath.Join(dir, "server.crt"),
+		TLSKeyFile:      filepath.Join(dir, "server.key"),
+		TLSClientCAFile: filepath.Join(dir, "ca.crt"),
+	}
+
+	ca := newTestCert(t, "ca", nil)
+	ca.write(t, cfg.TLSClientCAFile, "")
+	first := newTestCert(t, "first", ca)
+	first.write(t, cfg.TLSCertFile, cfg.TLSKeyFile)
+	client := newTestCert(t, "client", ca)
+
+	certs, err := NewCertificates(cfg)
+	require.NoError(t, err)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Proto", r.Proto)
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+			w.Header().Set("X-Client", r.TLS.VerifiedChains[0][0].Subject.CommonName)
+		}
+	}))
+	srv.EnableHTTP2 = true
+	srv.TLS = certs.TLSConfig()
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(t *testing.T, clientCerts ...tls.Certificate) *http.Response {
+		t.Helper()
+		c := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: roots, Certificates: clientCerts, MinVersion: tls.VersionTLS12},
+			ForceAttemptHTTP2: true,
+		}}
+		res, err := c.Get(srv.URL)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		return res
+	}
+
+	t.Run("#http2_without_client_cert", func(t *testing.T) {
+		res := get(t)
+		assert.Equal(t, "HTTP/2.0", res.Header.Get("X-Proto"))
+		assert.Empty(t, res.Header.Get("X-Client"))
+		assert.Equal(t, "first", res.TLS.PeerCertificates[0].Subject.CommonName)
+	})
+
+	t.Run("#verified_client_cert", func(t *testing.T) {
+		res := get(t, client.tls())
+		assert.Equal(t, "client", res.Header.Get("X-Client"))
+	})
+
+	t.Run("#reload", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- certs.Run(ctx) }()
+		defer func() {
+			cancel()
+			require.NoError(t, <-done)
+		}()
+
+		// give the watcher time to start before rotating the certificate
+		time.Sleep(100 * time.Millisecond)
+		newTestCert(t, "second", ca).write(t, cfg.TLSCertFile, cfg.TLSKeyFile)
+
+		require.Eventually(t, func() bool {
+			leaf, err := x509.ParseCertificate(certs.cert.Load().Certificate[0])
+			return err == nil && leaf.Subject.CommonName == "second"
+		}, 5*time.Second, 50*time.Millisecond)
+		res := get(t)
+		assert.Equal(t, "second", res.TLS.PeerCertificates[0].Subject.CommonName)
+	})
+
+	t.Run("#invalid_files_rejected", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(cfg.TLSClientCAFile, []byte("not a certificate"), 0o600))
+		require.Error(t, certs.load())
+		res := get(t, client.tls())
+		assert.Equal(t, "client", res.Header.Get("X-Client"))
+	})
+}
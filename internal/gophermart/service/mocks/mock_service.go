@@ -0,0 +1,824 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: service/service.go
+
+// Package mock_service is a generated GoMock package.
+package mock_service
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+	models "github.com/vkupriya/go-gophermart/internal/gophermart/models"
+)
+
+// MockStorage is a mock of Storage interface.
+type MockStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockStorageMockRecorder
+}
+
+// MockStorageMockRecorder is the mock recorder for MockStorage.
+type MockStorageMockRecorder struct {
+	mock *MockStorage
+}
+
+// NewMockStorage creates a new mock instance.
+func NewMockStorage(ctrl *gomock.Controller) *MockStorage {
+	mock := &MockStorage{ctrl: ctrl}
+	mock.recorder = &MockStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
+	return m.recorder
+}
+
+// AccrualWithdraw mocks base method.
+func (m *MockStorage) AccrualWithdraw(ctx context.Context, c *models.Config, w models.Withdrawal, check func(models.WithdrawalStats) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AccrualWithdraw", ctx, c, w, check)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AccrualWithdraw indicates an expected call of AccrualWithdraw.
+func (mr *MockStorageMockRecorder) AccrualWithdraw(ctx, c, w, check interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AccrualWithdraw", reflect.TypeOf((*MockStorage)(nil).AccrualWithdraw), ctx, c, w, check)
+}
+
+// AdjustmentAdd mocks base method.
+func (m *MockStorage) AdjustmentAdd(ctx context.Context, c *models.Config, a models.Adjustment) (models.Adjustment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdjustmentAdd", ctx, c, a)
+	ret0, _ := ret[0].(models.Adjustment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdjustmentAdd indicates an expected call of AdjustmentAdd.
+func (mr *MockStorageMockRecorder) AdjustmentAdd(ctx, c, a interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdjustmentAdd", reflect.TypeOf((*MockStorage)(nil).AdjustmentAdd), ctx, c, a)
+}
+
+// AdjustmentDecide mocks base method.
+func (m *MockStorage) AdjustmentDecide(ctx context.Context, c *models.Config, admin string, id int64, approve bool) (models.Adjustment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdjustmentDecide", ctx, c, admin, id, approve)
+	ret0, _ := ret[0].(models.Adjustment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdjustmentDecide indicates an expected call of AdjustmentDecide.
+func (mr *MockStorageMockRecorder) AdjustmentDecide(ctx, c, admin, id, approve interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdjustmentDecide", reflect.TypeOf((*MockStorage)(nil).AdjustmentDecide), ctx, c, admin, id, approve)
+}
+
+// AdjustmentsGet mocks base method.
+func (m *MockStorage) AdjustmentsGet(ctx context.Context, c *models.Config, status string, limit, offset int64) (models.Adjustments, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdjustmentsGet", ctx, c, status, limit, offset)
+	ret0, _ := ret[0].(models.Adjustments)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdjustmentsGet indicates an expected call of AdjustmentsGet.
+func (mr *MockStorageMockRecorder) AdjustmentsGet(ctx, c, status, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdjustmentsGet", reflect.TypeOf((*MockStorage)(nil).AdjustmentsGet), ctx, c, status, limit, offset)
+}
+
+// AuditAdd mocks base method.
+func (m *MockStorage) AuditAdd(ctx context.Context, c *models.Config, admin, action, target string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuditAdd", ctx, c, admin, action, target)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AuditAdd indicates an expected call of AuditAdd.
+func (mr *MockStorageMockRecorder) AuditAdd(ctx, c, admin, action, target interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuditAdd", reflect.TypeOf((*MockStorage)(nil).AuditAdd), ctx, c, admin, action, target)
+}
+
+// AuditGet mocks base method.
+func (m *MockStorage) AuditGet(ctx context.Context, c *models.Config, limit, offset int64) (models.AuditEntries, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuditGet", ctx, c, limit, offset)
+	ret0, _ := ret[0].(models.AuditEntries)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AuditGet indicates an expected call of AuditGet.
+func (mr *MockStorageMockRecorder) AuditGet(ctx, c, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuditGet", reflect.TypeOf((*MockStorage)(nil).AuditGet), ctx, c, limit, offset)
+}
+
+// BalanceGet mocks base method.
+func (m *MockStorage) BalanceGet(ctx context.Context, c *models.Config, userid string) (models.Balance, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BalanceGet", ctx, c, userid)
+	ret0, _ := ret[0].(models.Balance)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BalanceGet indicates an expected call of BalanceGet.
+func (mr *MockStorageMockRecorder) BalanceGet(ctx, c, userid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BalanceGet", reflect.TypeOf((*MockStorage)(nil).BalanceGet), ctx, c, userid)
+}
+
+// CampaignAdd mocks base method.
+func (m *MockStorage) CampaignAdd(ctx context.Context, c *models.Config, cmp models.Campaign) (models.Campaign, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CampaignAdd", ctx, c, cmp)
+	ret0, _ := ret[0].(models.Campaign)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CampaignAdd indicates an expected call of CampaignAdd.
+func (mr *MockStorageMockRecorder) CampaignAdd(ctx, c, cmp interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CampaignAdd", reflect.TypeOf((*MockStorage)(nil).CampaignAdd), ctx, c, cmp)
+}
+
+// CampaignStop mocks base method.
+func (m *MockStorage) CampaignStop(ctx context.Context, c *models.Config, admin string, id int64) (models.Campaign, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CampaignStop", ctx, c, admin, id)
+	ret0, _ := ret[0].(models.Campaign)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CampaignStop indicates an expected call of CampaignStop.
+func (mr *MockStorageMockRecorder) CampaignStop(ctx, c, admin, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CampaignStop", reflect.TypeOf((*MockStorage)(nil).CampaignStop), ctx, c, admin, id)
+}
+
+// CampaignsGet mocks base method.
+func (m *MockStorage) CampaignsGet(ctx context.Context, c *models.Config, limit, offset int64) (models.Campaigns, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CampaignsGet", ctx, c, limit, offset)
+	ret0, _ := ret[0].(models.Campaigns)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CampaignsGet indicates an expected call of CampaignsGet.
+func (mr *MockStorageMockRecorder) CampaignsGet(ctx, c, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CampaignsGet", reflect.TypeOf((*MockStorage)(nil).CampaignsGet), ctx, c, limit, offset)
+}
+
+// GetUnprocessedOrders mocks base method.
+func (m *MockStorage) GetUnprocessedOrders(ctx context.Context, c *models.Config) (models.Orders, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUnprocessedOrders", ctx, c)
+	ret0, _ := ret[0].(models.Orders)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUnprocessedOrders indicates an expected call of GetUnprocessedOrders.
+func (mr *MockStorageMockRecorder) GetUnprocessedOrders(ctx, c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUnprocessedOrders", reflect.TypeOf((*MockStorage)(nil).GetUnprocessedOrders), ctx, c)
+}
+
+// IdempotencyPurge mocks base method.
+func (m *MockStorage) IdempotencyPurge(ctx context.Context, c *models.Config) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IdempotencyPurge", ctx, c)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IdempotencyPurge indicates an expected call of IdempotencyPurge.
+func (mr *MockStorageMockRecorder) IdempotencyPurge(ctx, c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IdempotencyPurge", reflect.TypeOf((*MockStorage)(nil).IdempotencyPurge), ctx, c)
+}
+
+// IdempotencyRelease mocks base method.
+func (m *MockStorage) IdempotencyRelease(ctx context.Context, c *models.Config, userid, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IdempotencyRelease", ctx, c, userid, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IdempotencyRelease indicates an expected call of IdempotencyRelease.
+func (mr *MockStorageMockRecorder) IdempotencyRelease(ctx, c, userid, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IdempotencyRelease", reflect.TypeOf((*MockStorage)(nil).IdempotencyRelease), ctx, c, userid, key)
+}
+
+// IdempotencyReserve mocks base method.
+func (m *MockStorage) IdempotencyReserve(ctx context.Context, c *models.Config, rec models.IdempotencyRecord) (models.IdempotencyRecord, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IdempotencyReserve", ctx, c, rec)
+	ret0, _ := ret[0].(models.IdempotencyRecord)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// IdempotencyReserve indicates an expected call of IdempotencyReserve.
+func (mr *MockStorageMockRecorder) IdempotencyReserve(ctx, c, rec interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IdempotencyReserve", reflect.TypeOf((*MockStorage)(nil).IdempotencyReserve), ctx, c, rec)
+}
+
+// IdempotencySave mocks base method.
+func (m *MockStorage) IdempotencySave(ctx context.Context, c *models.Config, rec models.IdempotencyRecord) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IdempotencySave", ctx, c, rec)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IdempotencySave indicates an expected call of IdempotencySave.
+func (mr *MockStorageMockRecorder) IdempotencySave(ctx, c, rec interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IdempotencySave", reflect.TypeOf((*MockStorage)(nil).IdempotencySave), ctx, c, rec)
+}
+
+// LedgerGet mocks base method.
+func (m *MockStorage) LedgerGet(ctx context.Context, c *models.Config, userid string) (models.LedgerEntries, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LedgerGet", ctx, c, userid)
+	ret0, _ := ret[0].(models.LedgerEntries)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LedgerGet indicates an expected call of LedgerGet.
+func (mr *MockStorageMockRecorder) LedgerGet(ctx, c, userid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LedgerGet", reflect.TypeOf((*MockStorage)(nil).LedgerGet), ctx, c, userid)
+}
+
+// LotsExpire mocks base method.
+func (m *MockStorage) LotsExpire(ctx context.Context, c *models.Config, limit int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LotsExpire", ctx, c, limit)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LotsExpire indicates an expected call of LotsExpire.
+func (mr *MockStorageMockRecorder) LotsExpire(ctx, c, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LotsExpire", reflect.TypeOf((*MockStorage)(nil).LotsExpire), ctx, c, limit)
+}
+
+// MigrationStatus mocks base method.
+func (m *MockStorage) MigrationStatus(ctx context.Context, c *models.Config) (models.MigrationStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MigrationStatus", ctx, c)
+	ret0, _ := ret[0].(models.MigrationStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MigrationStatus indicates an expected call of MigrationStatus.
+func (mr *MockStorageMockRecorder) MigrationStatus(ctx, c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MigrationStatus", reflect.TypeOf((*MockStorage)(nil).MigrationStatus), ctx, c)
+}
+
+// OrderAdd mocks base method.
+func (m *MockStorage) OrderAdd(ctx context.Context, c *models.Config, userid, oid string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OrderAdd", ctx, c, userid, oid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// OrderAdd indicates an expected call of OrderAdd.
+func (mr *MockStorageMockRecorder) OrderAdd(ctx, c, userid, oid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrderAdd", reflect.TypeOf((*MockStorage)(nil).OrderAdd), ctx, c, userid, oid)
+}
+
+// OrderConflictsAdd mocks base method.
+func (m *MockStorage) OrderConflictsAdd(ctx context.Context, c *models.Config, userid string, oids []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OrderConflictsAdd", ctx, c, userid, oids)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// OrderConflictsAdd indicates an expected call of OrderConflictsAdd.
+func (mr *MockStorageMockRecorder) OrderConflictsAdd(ctx, c, userid, oids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrderConflictsAdd", reflect.TypeOf((*MockStorage)(nil).OrderConflictsAdd), ctx, c, userid, oids)
+}
+
+// OrderEventsGet mocks base method.
+func (m *MockStorage) OrderEventsGet(ctx context.Context, c *models.Config, userid string, afterSeq int64) (models.OrderEvents, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OrderEventsGet", ctx, c, userid, afterSeq)
+	ret0, _ := ret[0].(models.OrderEvents)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// OrderEventsGet indicates an expected call of OrderEventsGet.
+func (mr *MockStorageMockRecorder) OrderEventsGet(ctx, c, userid, afterSeq interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrderEventsGet", reflect.TypeOf((*MockStorage)(nil).OrderEventsGet), ctx, c, userid, afterSeq)
+}
+
+// OrderEventsListen mocks base method.
+func (m *MockStorage) OrderEventsListen(ctx context.Context, c *models.Config, fn func(models.OrderEvent)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OrderEventsListen", ctx, c, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// OrderEventsListen indicates an expected call of OrderEventsListen.
+func (mr *MockStorageMockRecorder) OrderEventsListen(ctx, c, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrderEventsListen", reflect.TypeOf((*MockStorage)(nil).OrderEventsListen), ctx, c, fn)
+}
+
+// OrderGet mocks base method.
+func (m *MockStorage) OrderGet(ctx context.Context, c *models.Config, oid string) (models.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OrderGet", ctx, c, oid)
+	ret0, _ := ret[0].(models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// OrderGet indicates an expected call of OrderGet.
+func (mr *MockStorageMockRecorder) OrderGet(ctx, c, oid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrderGet", reflect.TypeOf((*MockStorage)(nil).OrderGet), ctx, c, oid)
+}
+
+// OrderRecheck mocks base method.
+func (m *MockStorage) OrderRecheck(ctx context.Context, c *models.Config, admin, oid string) (models.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OrderRecheck", ctx, c, admin, oid)
+	ret0, _ := ret[0].(models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// OrderRecheck indicates an expected call of OrderRecheck.
+func (mr *MockStorageMockRecorder) OrderRecheck(ctx, c, admin, oid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrderRecheck", reflect.TypeOf((*MockStorage)(nil).OrderRecheck), ctx, c, admin, oid)
+}
+
+// OrdersAddBatch mocks base method.
+func (m *MockStorage) OrdersAddBatch(ctx context.Context, c *models.Config, userid string, oids []string) (models.OrderBatchResults, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OrdersAddBatch", ctx, c, userid, oids)
+	ret0, _ := ret[0].(models.OrderBatchResults)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// OrdersAddBatch indicates an expected call of OrdersAddBatch.
+func (mr *MockStorageMockRecorder) OrdersAddBatch(ctx, c, userid, oids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrdersAddBatch", reflect.TypeOf((*MockStorage)(nil).OrdersAddBatch), ctx, c, userid, oids)
+}
+
+// OrdersGet mocks base method.
+func (m *MockStorage) OrdersGet(ctx context.Context, c *models.Config, userid string) (models.Orders, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OrdersGet", ctx, c, userid)
+	ret0, _ := ret[0].(models.Orders)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// OrdersGet indicates an expected call of OrdersGet.
+func (mr *MockStorageMockRecorder) OrdersGet(ctx, c, userid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OrdersGet", reflect.TypeOf((*MockStorage)(nil).OrdersGet), ctx, c, userid)
+}
+
+// Ping mocks base method.
+func (m *MockStorage) Ping(ctx context.Context, c *models.Config) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", ctx, c)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping.
+func (mr *MockStorageMockRecorder) Ping(ctx, c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockStorage)(nil).Ping), ctx, c)
+}
+
+// ReferralStatsGet mocks base method.
+func (m *MockStorage) ReferralStatsGet(ctx context.Context, c *models.Config, userid string) (models.ReferralStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReferralStatsGet", ctx, c, userid)
+	ret0, _ := ret[0].(models.ReferralStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReferralStatsGet indicates an expected call of ReferralStatsGet.
+func (mr *MockStorageMockRecorder) ReferralStatsGet(ctx, c, userid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReferralStatsGet", reflect.TypeOf((*MockStorage)(nil).ReferralStatsGet), ctx, c, userid)
+}
+
+// ReversalDecide mocks base method.
+func (m *MockStorage) ReversalDecide(ctx context.Context, c *models.Config, admin string, id int64, approve bool) (models.WithdrawalReversal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReversalDecide", ctx, c, admin, id, approve)
+	ret0, _ := ret[0].(models.WithdrawalReversal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReversalDecide indicates an expected call of ReversalDecide.
+func (mr *MockStorageMockRecorder) ReversalDecide(ctx, c, admin, id, approve interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReversalDecide", reflect.TypeOf((*MockStorage)(nil).ReversalDecide), ctx, c, admin, id, approve)
+}
+
+// ReversalsGet mocks base method.
+func (m *MockStorage) ReversalsGet(ctx context.Context, c *models.Config, status string, limit, offset int64) (models.WithdrawalReversals, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReversalsGet", ctx, c, status, limit, offset)
+	ret0, _ := ret[0].(models.WithdrawalReversals)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReversalsGet indicates an expected call of ReversalsGet.
+func (mr *MockStorageMockRecorder) ReversalsGet(ctx, c, status, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReversalsGet", reflect.TypeOf((*MockStorage)(nil).ReversalsGet), ctx, c, status, limit, offset)
+}
+
+// RiskDecide mocks base method.
+func (m *MockStorage) RiskDecide(ctx context.Context, c *models.Config, admin string, id int64, approve bool, check func(float32, models.WithdrawalStats) error) (models.RiskDecision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RiskDecide", ctx, c, admin, id, approve, check)
+	ret0, _ := ret[0].(models.RiskDecision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RiskDecide indicates an expected call of RiskDecide.
+func (mr *MockStorageMockRecorder) RiskDecide(ctx, c, admin, id, approve, check interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RiskDecide", reflect.TypeOf((*MockStorage)(nil).RiskDecide), ctx, c, admin, id, approve, check)
+}
+
+// RiskDecisionAdd mocks base method.
+func (m *MockStorage) RiskDecisionAdd(ctx context.Context, c *models.Config, d models.RiskDecision) (models.RiskDecision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RiskDecisionAdd", ctx, c, d)
+	ret0, _ := ret[0].(models.RiskDecision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RiskDecisionAdd indicates an expected call of RiskDecisionAdd.
+func (mr *MockStorageMockRecorder) RiskDecisionAdd(ctx, c, d interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RiskDecisionAdd", reflect.TypeOf((*MockStorage)(nil).RiskDecisionAdd), ctx, c, d)
+}
+
+// RiskDecisionsGet mocks base method.
+func (m *MockStorage) RiskDecisionsGet(ctx context.Context, c *models.Config, action, status string, limit, offset int64) (models.RiskDecisions, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RiskDecisionsGet", ctx, c, action, status, limit, offset)
+	ret0, _ := ret[0].(models.RiskDecisions)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RiskDecisionsGet indicates an expected call of RiskDecisionsGet.
+func (mr *MockStorageMockRecorder) RiskDecisionsGet(ctx, c, action, status, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RiskDecisionsGet", reflect.TypeOf((*MockStorage)(nil).RiskDecisionsGet), ctx, c, action, status, limit, offset)
+}
+
+// RiskSignalsGet mocks base method.
+func (m *MockStorage) RiskSignalsGet(ctx context.Context, c *models.Config, userid, kind string) (models.RiskSignals, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RiskSignalsGet", ctx, c, userid, kind)
+	ret0, _ := ret[0].(models.RiskSignals)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RiskSignalsGet indicates an expected call of RiskSignalsGet.
+func (mr *MockStorageMockRecorder) RiskSignalsGet(ctx, c, userid, kind interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RiskSignalsGet", reflect.TypeOf((*MockStorage)(nil).RiskSignalsGet), ctx, c, userid, kind)
+}
+
+// TiersRecalculate mocks base method.
+func (m *MockStorage) TiersRecalculate(ctx context.Context, c *models.Config) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TiersRecalculate", ctx, c)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TiersRecalculate indicates an expected call of TiersRecalculate.
+func (mr *MockStorageMockRecorder) TiersRecalculate(ctx, c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TiersRecalculate", reflect.TypeOf((*MockStorage)(nil).TiersRecalculate), ctx, c)
+}
+
+// TiersSinceLastRun mocks base method.
+func (m *MockStorage) TiersSinceLastRun(ctx context.Context, c *models.Config) (time.Duration, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TiersSinceLastRun", ctx, c)
+	ret0, _ := ret[0].(time.Duration)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// TiersSinceLastRun indicates an expected call of TiersSinceLastRun.
+func (mr *MockStorageMockRecorder) TiersSinceLastRun(ctx, c interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TiersSinceLastRun", reflect.TypeOf((*MockStorage)(nil).TiersSinceLastRun), ctx, c)
+}
+
+// Transfer mocks base method.
+func (m *MockStorage) Transfer(ctx context.Context, c *models.Config, t models.Transfer, check func(models.WithdrawalStats) error) (models.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Transfer", ctx, c, t, check)
+	ret0, _ := ret[0].(models.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Transfer indicates an expected call of Transfer.
+func (mr *MockStorageMockRecorder) Transfer(ctx, c, t, check interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Transfer", reflect.TypeOf((*MockStorage)(nil).Transfer), ctx, c, t, check)
+}
+
+// UpdateOrder mocks base method.
+func (m *MockStorage) UpdateOrder(ctx context.Context, c *models.Config, order *models.Order) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOrder", ctx, c, order)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateOrder indicates an expected call of UpdateOrder.
+func (mr *MockStorageMockRecorder) UpdateOrder(ctx, c, order interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrder", reflect.TypeOf((*MockStorage)(nil).UpdateOrder), ctx, c, order)
+}
+
+// UserAdd mocks base method.
+func (m *MockStorage) UserAdd(ctx context.Context, c *models.Config, user models.User) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserAdd", ctx, c, user)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UserAdd indicates an expected call of UserAdd.
+func (mr *MockStorageMockRecorder) UserAdd(ctx, c, user interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserAdd", reflect.TypeOf((*MockStorage)(nil).UserAdd), ctx, c, user)
+}
+
+// UserGet mocks base method.
+func (m *MockStorage) UserGet(ctx context.Context, c *models.Config, userid string) (models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserGet", ctx, c, userid)
+	ret0, _ := ret[0].(models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UserGet indicates an expected call of UserGet.
+func (mr *MockStorageMockRecorder) UserGet(ctx, c, userid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserGet", reflect.TypeOf((*MockStorage)(nil).UserGet), ctx, c, userid)
+}
+
+// UserLockSet mocks base method.
+func (m *MockStorage) UserLockSet(ctx context.Context, c *models.Config, admin, userid string, locked bool) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserLockSet", ctx, c, admin, userid, locked)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UserLockSet indicates an expected call of UserLockSet.
+func (mr *MockStorageMockRecorder) UserLockSet(ctx, c, admin, userid, locked interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserLockSet", reflect.TypeOf((*MockStorage)(nil).UserLockSet), ctx, c, admin, userid, locked)
+}
+
+// UserLoginRecord mocks base method.
+func (m *MockStorage) UserLoginRecord(ctx context.Context, c *models.Config, userid, ip string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserLoginRecord", ctx, c, userid, ip)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UserLoginRecord indicates an expected call of UserLoginRecord.
+func (mr *MockStorageMockRecorder) UserLoginRecord(ctx, c, userid, ip interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserLoginRecord", reflect.TypeOf((*MockStorage)(nil).UserLoginRecord), ctx, c, userid, ip)
+}
+
+// UserPasswordSet mocks base method.
+func (m *MockStorage) UserPasswordSet(ctx context.Context, c *models.Config, userid, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserPasswordSet", ctx, c, userid, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UserPasswordSet indicates an expected call of UserPasswordSet.
+func (mr *MockStorageMockRecorder) UserPasswordSet(ctx, c, userid, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserPasswordSet", reflect.TypeOf((*MockStorage)(nil).UserPasswordSet), ctx, c, userid, password)
+}
+
+// UsersSearch mocks base method.
+func (m *MockStorage) UsersSearch(ctx context.Context, c *models.Config, query string, limit, offset int64) (models.UserSummaries, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UsersSearch", ctx, c, query, limit, offset)
+	ret0, _ := ret[0].(models.UserSummaries)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UsersSearch indicates an expected call of UsersSearch.
+func (mr *MockStorageMockRecorder) UsersSearch(ctx, c, query, limit, offset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UsersSearch", reflect.TypeOf((*MockStorage)(nil).UsersSearch), ctx, c, query, limit, offset)
+}
+
+// UsersSyncAdmins mocks base method.
+func (m *MockStorage) UsersSyncAdmins(ctx context.Context, c *models.Config, userids []string) (int64, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UsersSyncAdmins", ctx, c, userids)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// UsersSyncAdmins indicates an expected call of UsersSyncAdmins.
+func (mr *MockStorageMockRecorder) UsersSyncAdmins(ctx, c, userids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UsersSyncAdmins", reflect.TypeOf((*MockStorage)(nil).UsersSyncAdmins), ctx, c, userids)
+}
+
+// WebhookAdd mocks base method.
+func (m *MockStorage) WebhookAdd(ctx context.Context, c *models.Config, wh models.Webhook) (models.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WebhookAdd", ctx, c, wh)
+	ret0, _ := ret[0].(models.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WebhookAdd indicates an expected call of WebhookAdd.
+func (mr *MockStorageMockRecorder) WebhookAdd(ctx, c, wh interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WebhookAdd", reflect.TypeOf((*MockStorage)(nil).WebhookAdd), ctx, c, wh)
+}
+
+// WebhookDelete mocks base method.
+func (m *MockStorage) WebhookDelete(ctx context.Context, c *models.Config, userid string, id int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WebhookDelete", ctx, c, userid, id)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WebhookDelete indicates an expected call of WebhookDelete.
+func (mr *MockStorageMockRecorder) WebhookDelete(ctx, c, userid, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WebhookDelete", reflect.TypeOf((*MockStorage)(nil).WebhookDelete), ctx, c, userid, id)
+}
+
+// WebhookDeliveriesGet mocks base method.
+func (m *MockStorage) WebhookDeliveriesGet(ctx context.Context, c *models.Config, userid string, id int64) (models.WebhookDeliveries, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WebhookDeliveriesGet", ctx, c, userid, id)
+	ret0, _ := ret[0].(models.WebhookDeliveries)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WebhookDeliveriesGet indicates an expected call of WebhookDeliveriesGet.
+func (mr *MockStorageMockRecorder) WebhookDeliveriesGet(ctx, c, userid, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WebhookDeliveriesGet", reflect.TypeOf((*MockStorage)(nil).WebhookDeliveriesGet), ctx, c, userid, id)
+}
+
+// WebhookDeliveryAdd mocks base method.
+func (m *MockStorage) WebhookDeliveryAdd(ctx context.Context, c *models.Config, d models.WebhookDelivery, delivered bool, retryIn time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WebhookDeliveryAdd", ctx, c, d, delivered, retryIn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WebhookDeliveryAdd indicates an expected call of WebhookDeliveryAdd.
+func (mr *MockStorageMockRecorder) WebhookDeliveryAdd(ctx, c, d, delivered, retryIn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WebhookDeliveryAdd", reflect.TypeOf((*MockStorage)(nil).WebhookDeliveryAdd), ctx, c, d, delivered, retryIn)
+}
+
+// WebhookOutboxClaim mocks base method.
+func (m *MockStorage) WebhookOutboxClaim(ctx context.Context, c *models.Config, lease time.Duration) (models.WebhookMessage, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WebhookOutboxClaim", ctx, c, lease)
+	ret0, _ := ret[0].(models.WebhookMessage)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// WebhookOutboxClaim indicates an expected call of WebhookOutboxClaim.
+func (mr *MockStorageMockRecorder) WebhookOutboxClaim(ctx, c, lease interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WebhookOutboxClaim", reflect.TypeOf((*MockStorage)(nil).WebhookOutboxClaim), ctx, c, lease)
+}
+
+// WebhooksGet mocks base method.
+func (m *MockStorage) WebhooksGet(ctx context.Context, c *models.Config, userid string) (models.Webhooks, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WebhooksGet", ctx, c, userid)
+	ret0, _ := ret[0].(models.Webhooks)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WebhooksGet indicates an expected call of WebhooksGet.
+func (mr *MockStorageMockRecorder) WebhooksGet(ctx, c, userid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WebhooksGet", reflect.TypeOf((*MockStorage)(nil).WebhooksGet), ctx, c, userid)
+}
+
+// WithdrawalReverse mocks base method.
+func (m *MockStorage) WithdrawalReverse(ctx context.Context, c *models.Config, admin string, r models.WithdrawalReversal) (models.WithdrawalReversal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithdrawalReverse", ctx, c, admin, r)
+	ret0, _ := ret[0].(models.WithdrawalReversal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WithdrawalReverse indicates an expected call of WithdrawalReverse.
+func (mr *MockStorageMockRecorder) WithdrawalReverse(ctx, c, admin, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithdrawalReverse", reflect.TypeOf((*MockStorage)(nil).WithdrawalReverse), ctx, c, admin, r)
+}
+
+// WithdrawalsGet mocks base method.
+func (m *MockStorage) WithdrawalsGet(ctx context.Context, c *models.Config, userid string) (models.Withdrawals, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithdrawalsGet", ctx, c, userid)
+	ret0, _ := ret[0].(models.Withdrawals)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WithdrawalsGet indicates an expected call of WithdrawalsGet.
+func (mr *MockStorageMockRecorder) WithdrawalsGet(ctx, c, userid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithdrawalsGet", reflect.TypeOf((*MockStorage)(nil).WithdrawalsGet), ctx, c, userid)
+}
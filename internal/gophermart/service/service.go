@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"strconv"
 	"sync/atomic"
@@ -14,84 +15,275 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/go-resty/resty/v2"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
+	"github.com/vkupriya/go-gophermart/internal/gophermart/broker"
 	"github.com/vkupriya/go-gophermart/internal/gophermart/helpers"
+	"github.com/vkupriya/go-gophermart/internal/gophermart/logging"
 	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
+	"github.com/vkupriya/go-gophermart/internal/gophermart/risk"
 	"github.com/vkupriya/go-gophermart/internal/gophermart/storage"
+	"github.com/vkupriya/go-gophermart/internal/gophermart/tracing"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 type Storage interface {
-	UserAdd(c *models.Config, user models.User) error
-	UserGet(c *models.Config, userid string) (models.User, error)
-	OrderAdd(c *models.Config, userid string, oid string) error
-	OrderGet(c *models.Config, oid string) (models.Order, error)
-	OrdersGet(c *models.Config, userid string) (models.Orders, error)
-	GetUnprocessedOrders(c *models.Config) (models.Orders, error)
-	UpdateOrder(c *models.Config, order *models.Order) error
-	UserAddAccrual(c *models.Config, order *models.Order) error
-	AccrualWithdraw(c *models.Config, w models.Withdrawal) error
-	WithdrawalsGet(c *models.Config, userid string) (models.Withdrawals, error)
-	BalanceGet(c *models.Config, userid string) (models.Balance, error)
+	UserAdd(ctx context.Context, c *models.Config, user models.User) error
+	UserGet(ctx context.Context, c *models.Config, userid string) (models.User, error)
+	OrderAdd(ctx context.Context, c *models.Config, userid string, oid string) error
+	OrdersAddBatch(ctx context.Context, c *models.Config, userid string, oids []string) (models.OrderBatchResults, error)
+	OrderGet(ctx context.Context, c *models.Config, oid string) (models.Order, error)
+	OrdersGet(ctx context.Context, c *models.Config, userid string) (models.Orders, error)
+	GetUnprocessedOrders(ctx context.Context, c *models.Config) (models.Orders, error)
+	UpdateOrder(ctx context.Context, c *models.Config, order *models.Order) (bool, error)
+	AccrualWithdraw(ctx context.Context, c *models.Config, w models.Withdrawal,
+		check func(models.WithdrawalStats) error) error
+	WithdrawalsGet(ctx context.Context, c *models.Config, userid string) (models.Withdrawals, error)
+	BalanceGet(ctx context.Context, c *models.Config, userid string) (models.Balance, error)
+	IdempotencyReserve(ctx context.Context, c *models.Config, rec models.IdempotencyRecord) (
+		models.IdempotencyRecord, bool, error)
+	IdempotencySave(ctx context.Context, c *models.Config, rec models.IdempotencyRecord) error
+	IdempotencyRelease(ctx context.Context, c *models.Config, userid string, key string) error
+	IdempotencyPurge(ctx context.Context, c *models.Config) (int64, error)
+	OrderEventsGet(ctx context.Context, c *models.Config, userid string, afterSeq int64) (models.OrderEvents, error)
+	OrderEventsListen(ctx context.Context, c *models.Config, fn func(models.OrderEvent)) error
+	WebhookAdd(ctx context.Context, c *models.Config, wh models.Webhook) (models.Webhook, error)
+	WebhooksGet(ctx context.Context, c *models.Config, userid string) (models.Webhooks, error)
+	WebhookDelete(ctx context.Context, c *models.Config, userid string, id int64) (bool, error)
+	WebhookDeliveriesGet(ctx context.Context, c *models.Config, userid string, id int64) (models.WebhookDeliveries, error)
+	WebhookOutboxClaim(ctx context.Context, c *models.Config, lease time.Duration) (models.WebhookMessage, bool, error)
+	WebhookDeliveryAdd(ctx context.Context, c *models.Config, d models.WebhookDelivery, delivered bool,
+		retryIn time.Duration) error
+	UsersSyncAdmins(ctx context.Context, c *models.Config, userids []string) (int64, int64, error)
+	UsersSearch(ctx context.Context, c *models.Config, query string, limit int64, offset int64) (
+		models.UserSummaries, error)
+	UserLockSet(ctx context.Context, c *models.Config, admin string, userid string, locked bool) (bool, error)
+	OrderRecheck(ctx context.Context, c *models.Config, admin string, oid string) (models.Order, error)
+	AuditAdd(ctx context.Context, c *models.Config, admin string, action string, target string) error
+	AuditGet(ctx context.Context, c *models.Config, limit int64, offset int64) (models.AuditEntries, error)
+	LedgerGet(ctx context.Context, c *models.Config, userid string) (models.LedgerEntries, error)
+	AdjustmentAdd(ctx context.Context, c *models.Config, a models.Adjustment) (models.Adjustment, error)
+	AdjustmentDecide(ctx context.Context, c *models.Config, admin string, id int64, approve bool) (
+		models.Adjustment, error)
+	AdjustmentsGet(ctx context.Context, c *models.Config, status string, limit int64, offset int64) (
+		models.Adjustments, error)
+	WithdrawalReverse(ctx context.Context, c *models.Config, admin string, r models.WithdrawalReversal) (
+		models.WithdrawalReversal, error)
+	ReversalDecide(ctx context.Context, c *models.Config, admin string, id int64, approve bool) (
+		models.WithdrawalReversal, error)
+	ReversalsGet(ctx context.Context, c *models.Config, status string, limit int64, offset int64) (
+		models.WithdrawalReversals, error)
+	LotsExpire(ctx context.Context, c *models.Config, limit int64) (int64, error)
+	TiersRecalculate(ctx context.Context, c *models.Config) (int64, error)
+	TiersSinceLastRun(ctx context.Context, c *models.Config) (time.Duration, bool, error)
+	CampaignAdd(ctx context.Context, c *models.Config, cmp models.Campaign) (models.Campaign, error)
+	CampaignStop(ctx context.Context, c *models.Config, admin string, id int64) (models.Campaign, error)
+	CampaignsGet(ctx context.Context, c *models.Config, limit int64, offset int64) (models.Campaigns, error)
+	ReferralStatsGet(ctx context.Context, c *models.Config, userid string) (models.ReferralStats, error)
+	Transfer(ctx context.Context, c *models.Config, t models.Transfer,
+		check func(models.WithdrawalStats) error) (models.Transfer, error)
+	UserPasswordSet(ctx context.Context, c *models.Config, userid string, password string) error
+	UserLoginRecord(ctx context.Context, c *models.Config, userid string, ip string) error
+	RiskSignalsGet(ctx context.Context, c *models.Config, userid string, kind string) (models.RiskSignals, error)
+	OrderConflictsAdd(ctx context.Context, c *models.Config, userid string, oids []string) error
+	RiskDecisionAdd(ctx context.Context, c *models.Config, d models.RiskDecision) (models.RiskDecision, error)
+	RiskDecisionsGet(ctx context.Context, c *models.Config, action string, status string, limit int64, offset int64) (
+		models.RiskDecisions, error)
+	RiskDecide(ctx context.Context, c *models.Config, admin string, id int64, approve bool,
+		check func(float32, models.WithdrawalStats) error) (models.RiskDecision, error)
+	Ping(ctx context.Context, c *models.Config) error
+	MigrationStatus(ctx context.Context, c *models.Config) (models.MigrationStatus, error)
 }
 
+// maximum number of accrual lots expired in one transaction
+const expiryBatchSize int64 = 1000
+
+// the dispatcher is stale when it has not made progress for this many
+// accrual intervals or HTTP timeouts, whichever is longer
+const dispatcherStaleFactor = 3
+
+// connection pool of the webhook delivery client
+const (
+	webhookMaxIdleConns    int           = 100
+	webhookIdleConnTimeout time.Duration = 90 * time.Second
+)
+
 type GophermartService struct {
 	store  Storage
-	config *models.Config
+	config atomic.Pointer[models.Config]
+	broker *broker.Broker
+	risk   atomic.Pointer[risk.Engine]
+	// reconfigured wakes up the dispatcher to resize its worker pool.
+	reconfigured chan struct{}
+	// accrualOpen is set while the workers back off after 429 Too Many Requests.
+	accrualOpen atomic.Bool
+	// dispatcherBeat is the time of the last dispatcher progress in unix nanoseconds.
+	dispatcherBeat atomic.Int64
+	draining       atomic.Bool
 }
 
 func NewGophermartService(store *storage.PostgresDB, cfg *models.Config) *GophermartService {
-	return &GophermartService{
-		store:  store,
-		config: cfg}
+	g := &GophermartService{
+		store:        store,
+		broker:       broker.NewBroker(),
+		reconfigured: make(chan struct{}, 1),
+	}
+	g.config.Store(cfg)
+	g.risk.Store(risk.NewEngine(cfg, risk.DefaultRules()...))
+	return g
+}
+
+func (g *GophermartService) cfg() *models.Config {
+	return g.config.Load()
+}
+
+// Reconfigure applies a reloaded configuration. Requests and dispatcher
+// runs already in progress complete with the previous one.
+func (g *GophermartService) Reconfigure(c *models.Config) {
+	g.config.Store(c)
+	g.risk.Store(risk.NewEngine(c, risk.DefaultRules()...))
+	select {
+	case g.reconfigured <- struct{}{}:
+	default:
+	}
 }
 
-func (g *GophermartService) UserAdd(user models.User) error {
-	logger := g.config.Logger
-	password, err := helpers.HashPassword(user.Password)
+func (g *GophermartService) UserAdd(ctx context.Context, user models.User) error {
+	ctx, span := tracing.Start(ctx, "service.UserAdd")
+	defer span.End()
+
+	logger := logging.FromContext(ctx, g.cfg().Logger)
+	password, err := hashPassword(ctx, user.Password)
 	if err != nil {
 		return fmt.Errorf("failed to register user %s: %w", user.UserID, err)
 	}
 	user.Password = password
-	if err = g.store.UserAdd(g.config, user); err != nil {
+	if err = g.store.UserAdd(ctx, g.cfg(), user); err != nil {
 		return fmt.Errorf("failed to register user %s: %w", user.UserID, err)
 	}
 	logger.Sugar().Debugw("user has been registered",
-		"userID", user.UserID)
+		"userID", user.UserID,
+		"referred", user.ReferralCode != "")
 	return nil
 }
 
-func (g *GophermartService) UserGet(userid string) (models.User, error) {
-	user, err := g.store.UserGet(g.config, userid)
+func (g *GophermartService) UserGet(ctx context.Context, userid string) (models.User, error) {
+	user, err := g.store.UserGet(ctx, g.cfg(), userid)
 	if err != nil {
 		return user, fmt.Errorf("failed to get user %s: %w", userid, err)
 	}
 	return user, nil
 }
 
-func (g *GophermartService) UserLogin(userid string, passwd string) (string, error) {
+// UserLogin returns a JWT token for the user and records the client IP
+// address, ip may be empty if it is unknown.
+func (g *GophermartService) UserLogin(ctx context.Context, userid string, passwd string, ip string) (string, error) {
+	ctx, span := tracing.Start(ctx, "service.UserLogin")
+	defer span.End()
+
 	// logger := g.config.Logger
-	user, err := g.store.UserGet(g.config, userid)
+	user, err := g.store.UserGet(ctx, g.cfg(), userid)
 	if err != nil {
 		return "", fmt.Errorf("failed to query user: %w", err)
 	}
-	ok := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(passwd))
+	ok := comparePassword(ctx, user.Password, passwd)
 	if ok != nil {
 		return "", fmt.Errorf("incorrect password for user %s", userid)
 	}
+	if user.Locked {
+		return "", fmt.Errorf("user %s is locked", userid)
+	}
+	if ip != "" {
+		if err := g.store.UserLoginRecord(ctx, g.cfg(), userid, ip); err != nil {
+			return "", fmt.Errorf("failed to record login for user %s: %w", userid, err)
+		}
+	}
 
-	tokenStr, err := helpers.CreateJWTString(g.config, userid)
+	tokenStr, err := helpers.CreateJWTString(g.cfg(), userid, user.Role)
 	if err != nil {
 		return "", fmt.Errorf("failed to create JWT token for user %s", userid)
 	}
 	return tokenStr, nil
 }
 
-func (g *GophermartService) OrderAdd(userid string, oid string) error {
-	logger := g.config.Logger
+// UserPasswordChange replaces the user's password if the old one matches.
+// Withdrawals are held for WithdrawalCooldown afterwards.
+func (g *GophermartService) UserPasswordChange(ctx context.Context, userid string, oldPasswd string,
+	newPasswd string) error {
+	ctx, span := tracing.Start(ctx, "service.UserPasswordChange")
+	defer span.End()
+
+	logger := logging.FromContext(ctx, g.cfg().Logger)
+	user, err := g.store.UserGet(ctx, g.cfg(), userid)
+	if err != nil {
+		return fmt.Errorf("failed to query user: %w", err)
+	}
+	if err := comparePassword(ctx, user.Password, oldPasswd); err != nil {
+		return fmt.Errorf("user %s: %w", userid, models.ErrWrongPassword)
+	}
+	password, err := hashPassword(ctx, newPasswd)
+	if err != nil {
+		return fmt.Errorf("failed to change password for user %s: %w", userid, err)
+	}
+	if err := g.store.UserPasswordSet(ctx, g.cfg(), userid, password); err != nil {
+		return fmt.Errorf("failed to change password for user %s: %w", userid, err)
+	}
+	logger.Sugar().Infow("user password has been changed",
+		"userID", userid)
+	return nil
+}
+
+// hashPassword and comparePassword trace bcrypt, which is deliberately slow
+// and often dominates the latency of the requests using it.
+func hashPassword(ctx context.Context, password string) (string, error) {
+	_, span := tracing.Start(ctx, "bcrypt.hash")
+	defer span.End()
+	hash, err := helpers.HashPassword(password)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return hash, nil
+}
+
+func comparePassword(ctx context.Context, hash string, password string) error {
+	_, span := tracing.Start(ctx, "bcrypt.compare")
+	defer span.End()
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return fmt.Errorf("failed to compare password: %w", err)
+	}
+	return nil
+}
+
+// UserLocked reports whether the user account has been locked by an admin.
+// Missing users are reported with models.ErrNotFound.
+func (g *GophermartService) UserLocked(ctx context.Context, userid string) (bool, error) {
+	user, err := g.store.UserGet(ctx, g.cfg(), userid)
+	if err != nil {
+		return false, fmt.Errorf("failed to get user %s: %w", userid, err)
+	}
+	return user.Locked, nil
+}
+
+// UserRole returns the current role of the user, which may differ from the
+// role in a token issued earlier.
+func (g *GophermartService) UserRole(ctx context.Context, userid string) (string, error) {
+	user, err := g.store.UserGet(ctx, g.cfg(), userid)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user %s: %w", userid, err)
+	}
+	return user.Role, nil
+}
+
+func (g *GophermartService) OrderAdd(ctx context.Context, userid string, oid string) error {
+	ctx, span := tracing.Start(ctx, "service.OrderAdd")
+	defer span.End()
+
+	logger := logging.FromContext(ctx, g.cfg().Logger)
 
-	err := g.store.OrderAdd(g.config, userid, oid)
+	err := g.store.OrderAdd(ctx, g.cfg(), userid, oid)
 	if err != nil {
 		return fmt.Errorf("failed to register order %s: %w", oid, err)
 	}
@@ -100,52 +292,246 @@ func (g *GophermartService) OrderAdd(userid string, oid string) error {
 	return nil
 }
 
-func (g *GophermartService) OrderGet(oid string) (models.Order, error) {
-	order, err := g.store.OrderGet(g.config, oid)
+// OrdersAddBatch registers the given order numbers for the user in a single
+// transaction. Numbers repeated within the batch are reported as duplicates.
+func (g *GophermartService) OrdersAddBatch(ctx context.Context, userid string, oids []string) (
+	models.OrderBatchResults, error) {
+	ctx, span := tracing.Start(ctx, "service.OrdersAddBatch")
+	defer span.End()
+
+	logger := logging.FromContext(ctx, g.cfg().Logger)
+
+	seen := make(map[string]bool, len(oids))
+	unique := make([]string, 0, len(oids))
+	for _, oid := range oids {
+		if !seen[oid] {
+			seen[oid] = true
+			unique = append(unique, oid)
+		}
+	}
+
+	stored, err := g.store.OrdersAddBatch(ctx, g.cfg(), userid, unique)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register orders batch for user %s: %w", userid, err)
+	}
+	byNumber := make(map[string]string, len(stored))
+	for _, r := range stored {
+		byNumber[r.Number] = r.Status
+	}
+
+	results := make(models.OrderBatchResults, 0, len(oids))
+	conflicts := make([]string, 0)
+	repeated := make(map[string]bool, len(oids))
+	for _, oid := range oids {
+		// ownership decides first, then only the first occurrence of an own
+		// number can be accepted
+		status := byNumber[oid]
+		switch {
+		case status == models.BatchOwnedByOther:
+			if !repeated[oid] {
+				conflicts = append(conflicts, oid)
+			}
+		case repeated[oid]:
+			status = models.BatchDuplicateOwn
+		}
+		repeated[oid] = true
+		results = append(results, models.OrderBatchResult{Number: oid, Status: status})
+	}
+	if len(conflicts) != 0 {
+		if err := g.OrderConflicts(ctx, userid, conflicts); err != nil {
+			logger.Sugar().Error("failed to record order conflicts", zap.Error(err))
+		}
+	}
+	logger.Sugar().Debugw("orders batch has been registered",
+		"userID", userid,
+		"count", len(unique))
+	return results, nil
+}
+
+// OrderConflicts records uploads of orders registered by other users, they
+// are a risk signal of order number probing.
+func (g *GophermartService) OrderConflicts(ctx context.Context, userid string, oids []string) error {
+	if err := g.store.OrderConflictsAdd(ctx, g.cfg(), userid, oids); err != nil {
+		return fmt.Errorf("failed to record order conflicts for user %s: %w", userid, err)
+	}
+	return nil
+}
+
+// RiskAssess scores an order upload or withdrawal of the user and records
+// the decision. Subject is the order number, amount the withdrawal sum.
+// Nothing is recorded if the risk engine is disabled.
+func (g *GophermartService) RiskAssess(ctx context.Context, userid string, kind string, subject string,
+	amount float32) (
+	models.RiskDecision, error) {
+	ctx, span := tracing.Start(ctx, "service.RiskAssess")
+	defer span.End()
+
+	logger := logging.FromContext(ctx, g.cfg().Logger)
+	if !g.risk.Load().Enabled() {
+		return models.RiskDecision{UserID: userid, Kind: kind, Subject: subject, Action: models.RiskAllow}, nil
+	}
+
+	s, err := g.store.RiskSignalsGet(ctx, g.cfg(), userid, kind)
+	if err != nil {
+		return models.RiskDecision{}, fmt.Errorf("failed to assess %s risk for user %s: %w", kind, userid, err)
+	}
+	s.Amount = amount
+
+	d := g.risk.Load().Evaluate(s)
+	d.UserID, d.Subject, d.Amount = userid, subject, amount
+	d, err = g.store.RiskDecisionAdd(ctx, g.cfg(), d)
+	if err != nil {
+		return d, fmt.Errorf("failed to record %s risk decision for user %s: %w", kind, userid, err)
+	}
+	if d.Action != models.RiskAllow {
+		logger.Sugar().Infow("risk engine has flagged an operation",
+			"userID", userid,
+			"kind", kind,
+			"action", d.Action,
+			"score", d.Score,
+			"reasons", d.Reasons)
+	}
+	return d, nil
+}
+
+func (g *GophermartService) AdminRiskDecisionsGet(ctx context.Context, admin string, action string, status string,
+	limit int64, offset int64) (models.RiskDecisions, error) {
+	if err := g.store.AuditAdd(ctx, g.cfg(), admin, models.AuditRiskView, action); err != nil {
+		return nil, fmt.Errorf("failed to get risk decisions: %w", err)
+	}
+	decisions, err := g.store.RiskDecisionsGet(ctx, g.cfg(), action, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get risk decisions: %w", err)
+	}
+	return decisions, nil
+}
+
+func (g *GophermartService) AdminRiskDecide(ctx context.Context, admin string, id int64, approve bool) (
+	models.RiskDecision, error) {
+	logger := logging.FromContext(ctx, g.cfg().Logger)
+	check := func(sum float32, st models.WithdrawalStats) error {
+		return helpers.CheckWithdrawal(g.cfg(), sum, st)
+	}
+	d, err := g.store.RiskDecide(ctx, g.cfg(), admin, id, approve, check)
+	if err != nil {
+		return d, fmt.Errorf("failed to decide risk decision %d: %w", id, err)
+	}
+	logger.Sugar().Infow("risk decision has been reviewed",
+		"admin", admin,
+		"id", id,
+		"status", d.Status)
+	return d, nil
+}
+
+func (g *GophermartService) OrderGet(ctx context.Context, oid string) (models.Order, error) {
+	order, err := g.store.OrderGet(ctx, g.cfg(), oid)
 	if err != nil {
 		return models.Order{}, fmt.Errorf("failed to get order %s: %w", oid, err)
 	}
 	return order, nil
 }
 
-func (g *GophermartService) OrdersGet(userid string) (models.Orders, error) {
-	orders, err := g.store.OrdersGet(g.config, userid)
+func (g *GophermartService) OrdersGet(ctx context.Context, userid string) (models.Orders, error) {
+	orders, err := g.store.OrdersGet(ctx, g.cfg(), userid)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get orders for user %s: %w", userid, err)
 	}
 	return orders, nil
 }
 
-func (g *GophermartService) AccrualWithdraw(w models.Withdrawal) error {
-	err := g.store.AccrualWithdraw(g.config, w)
+// AccrualWithdraw registers a withdrawal. The configured withdrawal limits
+// are checked in the storage transaction with the user row locked, so
+// concurrent withdrawals cannot exceed them.
+func (g *GophermartService) AccrualWithdraw(ctx context.Context, w models.Withdrawal) error {
+	ctx, span := tracing.Start(ctx, "service.AccrualWithdraw")
+	defer span.End()
+
+	check := func(st models.WithdrawalStats) error {
+		return helpers.CheckWithdrawal(g.cfg(), w.Sum, st)
+	}
+	err := g.store.AccrualWithdraw(ctx, g.cfg(), w, check)
 	if err != nil {
-		return fmt.Errorf("failed to withdraw accrual for user %s", w.UserID)
+		return fmt.Errorf("failed to withdraw accrual for user %s: %w", w.UserID, err)
 	}
 	return nil
 }
 
-func (g *GophermartService) WithdrawalsGet(userid string) (models.Withdrawals, error) {
-	w, err := g.store.WithdrawalsGet(g.config, userid)
+func (g *GophermartService) WithdrawalsGet(ctx context.Context, userid string) (models.Withdrawals, error) {
+	w, err := g.store.WithdrawalsGet(ctx, g.cfg(), userid)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get orders for user %s: %w", userid, err)
 	}
 	return w, nil
 }
 
-func (g *GophermartService) BalanceGet(userid string) (models.Balance, error) {
-	bal, err := g.store.BalanceGet(g.config, userid)
+// WithdrawalReverse requests a refund of all or part of the user's own
+// withdrawal. The request is pending until an admin approves it.
+func (g *GophermartService) WithdrawalReverse(ctx context.Context, r models.WithdrawalReversal) (
+	models.WithdrawalReversal, error) {
+	logger := logging.FromContext(ctx, g.cfg().Logger)
+	r.CreatedBy = r.UserID
+	r, err := g.store.WithdrawalReverse(ctx, g.cfg(), "", r)
+	if err != nil {
+		return r, fmt.Errorf("failed to request reversal of withdrawal %s for user %s: %w", r.Number, r.UserID, err)
+	}
+	logger.Sugar().Infow("withdrawal refund has been requested",
+		"userID", r.UserID,
+		"order", r.Number,
+		"sum", r.Sum)
+	return r, nil
+}
+
+func (g *GophermartService) BalanceGet(ctx context.Context, userid string) (models.Balance, error) {
+	bal, err := g.store.BalanceGet(ctx, g.cfg(), userid)
 	if err != nil {
 		return models.Balance{}, fmt.Errorf("failed to get orders for user %s: %w", userid, err)
 	}
 	return bal, nil
 }
 
+func (g *GophermartService) BalanceHistoryGet(ctx context.Context, userid string) (models.LedgerEntries, error) {
+	entries, err := g.store.LedgerGet(ctx, g.cfg(), userid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance history for user %s: %w", userid, err)
+	}
+	return entries, nil
+}
+
+// Transfer sends points to another user. Transfers are subject to the
+// withdrawal limits and count toward them, so that points cannot leave an
+// account around the limits.
+func (g *GophermartService) Transfer(ctx context.Context, t models.Transfer) (models.Transfer, error) {
+	ctx, span := tracing.Start(ctx, "service.Transfer")
+	defer span.End()
+
+	logger := logging.FromContext(ctx, g.cfg().Logger)
+	check := func(st models.WithdrawalStats) error {
+		return helpers.CheckWithdrawal(g.cfg(), t.Amount, st)
+	}
+	t, err := g.store.Transfer(ctx, g.cfg(), t, check)
+	if err != nil {
+		return t, fmt.Errorf("failed to transfer points from user %s to %s: %w", t.From, t.To, err)
+	}
+	logger.Sugar().Infow("points have been transferred",
+		"from", t.From,
+		"to", t.To,
+		"amount", t.Amount,
+		"id", t.ID)
+	return t, nil
+}
+
+func (g *GophermartService) ReferralStatsGet(ctx context.Context, userid string) (models.ReferralStats, error) {
+	stats, err := g.store.ReferralStatsGet(ctx, g.cfg(), userid)
+	if err != nil {
+		return stats, fmt.Errorf("failed to get referral stats for user %s: %w", userid, err)
+	}
+	return stats, nil
+}
+
 func (g *GophermartService) OrderDispatcher(ctx context.Context) error {
-	var RetryFlag atomic.Bool
-	// setting RetryFlag to false
-	RetryFlag.Store(false)
+	g.dispatcherBeat.Store(time.Now().UnixNano())
 
-	inputCh := make(chan models.Order, g.config.AccrualWorkers)
+	inputCh := make(chan models.Order, g.cfg().AccrualWorkers)
 	eg, egCtx := errgroup.WithContext(ctx)
 
 	eg.Go(func() error {
@@ -154,14 +540,43 @@ func (g *GophermartService) OrderDispatcher(ctx context.Context) error {
 		}
 		return nil
 	})
-	for w := 1; w <= int(g.config.AccrualWorkers); w++ {
-		eg.Go(func() error {
-			if err := g.getAccrualWorker(egCtx, inputCh, &RetryFlag); err != nil {
-				return fmt.Errorf("accrual worker failed: %w", err)
-			}
-			return nil
-		})
+
+	// stopping a worker lets it finish the order in progress
+	var workers []chan struct{}
+	resize := func(n int) {
+		for len(workers) < n {
+			stop := make(chan struct{})
+			workers = append(workers, stop)
+			eg.Go(func() error {
+				if err := g.getAccrualWorker(egCtx, stop, inputCh, &g.accrualOpen); err != nil {
+					return fmt.Errorf("accrual worker failed: %w", err)
+				}
+				return nil
+			})
+		}
+		for len(workers) > n {
+			close(workers[len(workers)-1])
+			workers = workers[:len(workers)-1]
+		}
 	}
+	resize(int(g.cfg().AccrualWorkers))
+
+	eg.Go(func() error {
+		logger := logging.FromContext(egCtx, g.cfg().Logger)
+		for {
+			select {
+			case <-egCtx.Done():
+				return nil
+			case <-g.reconfigured:
+				if n := int(g.cfg().AccrualWorkers); n != len(workers) {
+					logger.Sugar().Infow("resizing accrual worker pool",
+						"from", len(workers),
+						"to", n)
+					resize(n)
+				}
+			}
+		}
+	})
 
 	if err := eg.Wait(); err != nil {
 		return fmt.Errorf("failed to run collector/sender go routines: %w", err)
@@ -170,7 +585,7 @@ func (g *GophermartService) OrderDispatcher(ctx context.Context) error {
 }
 
 func (g *GophermartService) orderTicker(ctx context.Context, ch chan<- models.Order) error {
-	ordersTicker := time.NewTicker(g.config.AccrualInterval)
+	ordersTicker := time.NewTicker(g.cfg().AccrualInterval)
 	defer ordersTicker.Stop()
 
 	for {
@@ -178,98 +593,738 @@ func (g *GophermartService) orderTicker(ctx context.Context, ch chan<- models.Or
 		case <-ctx.Done():
 			return nil
 		case <-ordersTicker.C:
-			orders, err := g.store.GetUnprocessedOrders(g.config)
+			g.dispatcherBeat.Store(time.Now().UnixNano())
+			orders, err := g.store.GetUnprocessedOrders(ctx, g.cfg())
 			if err != nil {
 				return fmt.Errorf("failed to get unprocessed orders: %w", err)
 			}
+			g.cfg().Metrics.OrdersClaimed(len(orders))
 			for _, order := range orders {
 				ch <- order
+				g.cfg().Metrics.SetQueueDepth(len(ch))
 			}
+			// picks up a reloaded interval
+			ordersTicker.Reset(g.cfg().AccrualInterval)
 		}
 	}
 }
 
-func (g *GophermartService) getAccrualWorker(ctx context.Context, ch <-chan models.Order, rf *atomic.Bool) error {
-	var (
-		ar         models.AccrualResponse
-		retryAfter time.Duration
-	)
-
-	logger := g.config.Logger
-	h := g.config.AccrualAddress
-
+func (g *GophermartService) getAccrualWorker(ctx context.Context, stop <-chan struct{}, ch <-chan models.Order,
+	rf *atomic.Bool) error {
 	client := resty.New().
-		SetTimeout(g.config.AccrualHTTPTimeout)
+		SetTimeout(g.cfg().AccrualHTTPTimeout).
+		SetTransport(otelhttp.NewTransport(http.DefaultTransport))
 
 	for {
 		select {
 		case <-ctx.Done():
 			return nil
+		case <-stop:
+			return nil
 		case order := <-ch:
-			url := fmt.Sprintf("%s/api/orders/%s", h, order.Number)
+			g.cfg().Metrics.SetQueueDepth(len(ch))
 			if rf.Load() {
 				for {
-					time.Sleep(g.config.AccrualWorkerRetry)
+					time.Sleep(g.cfg().AccrualWorkerRetry)
 					if !rf.Load() {
 						break
 					}
 				}
 			}
-			for {
-				resp, err := client.R().
-					SetHeader("Content-Type", "application/json").
-					Get(url)
+			if err := g.fetchAccrual(ctx, client, order, rf); err != nil {
+				return err
+			}
+			g.dispatcherBeat.Store(time.Now().UnixNano())
+		}
+	}
+}
+
+// fetchAccrual polls the accrual service for the order and stores the
+// result. Every order is traced from the request to the DB update.
+func (g *GophermartService) fetchAccrual(ctx context.Context, client *resty.Client, order models.Order,
+	rf *atomic.Bool) error {
+	var (
+		ar         models.AccrualResponse
+		retryAfter time.Duration
+	)
 
+	logger := logging.FromContext(ctx, g.cfg().Logger)
+	url := fmt.Sprintf("%s/api/orders/%s", g.cfg().AccrualAddress, order.Number)
+
+	ctx, span := tracing.Start(ctx, "dispatcher.fetchAccrual",
+		trace.WithAttributes(attribute.String("order.number", order.Number)))
+	defer span.End()
+
+	for {
+		resp, err := client.R().
+			SetContext(ctx).
+			SetHeader("Content-Type", "application/json").
+			Get(url)
+
+		if err != nil {
+			logger.Sugar().Errorf("failed to connect to accrual service, retrying: %v\n", err)
+			return nil
+		}
+
+		g.cfg().Metrics.AccrualResponse(resp.StatusCode())
+		if resp.StatusCode() == http.StatusTooManyRequests {
+			logger.Sugar().Error("request limit exceeded, retrying in 60 seconds")
+			g.cfg().Metrics.AccrualBackoff()
+			// checking if Retry-After is set in the Header otherwise use configured parameter
+			r := resp.Header().Get("Retry-After")
+			if r != "" {
+				retryAfterInt, err := strconv.ParseInt(r, 10, 64)
 				if err != nil {
-					logger.Sugar().Errorf("failed to connect to accrual service, retrying: %v\n", err)
-					break
+					logger.Sugar().Errorf("failed to convert Retry-After into int64", zap.Error(err))
+					return nil
 				}
+				retryAfter = time.Duration(retryAfterInt) * time.Second
+			} else {
+				retryAfter = g.cfg().AccrualRetryAfter
+			}
+			// setting RetryFlag to true
+			rf.Store(true)
+			time.Sleep(retryAfter)
+			rf.Store(false)
+			continue
+		}
 
-				if resp.StatusCode() == http.StatusTooManyRequests {
-					logger.Sugar().Error("request limit exceeded, retrying in 60 seconds")
-					// checking if Retry-After is set in the Header otherwise use configured parameter
-					r := resp.Header().Get("Retry-After")
-					if r != "" {
-						retryAfterInt, err := strconv.ParseInt(r, 10, 64)
-						if err != nil {
-							logger.Sugar().Errorf("failed to convert Retry-After into int64", zap.Error(err))
-							break
-						}
-						retryAfter = time.Duration(retryAfterInt) * time.Second
-					} else {
-						retryAfter = g.config.AccrualRetryAfter
-					}
-					// setting RetryFlag to true
-					rf.Store(true)
-					time.Sleep(retryAfter)
-					rf.Store(false)
-					continue
+		if resp.StatusCode() == http.StatusOK {
+			if err := json.Unmarshal(resp.Body(), &ar); err != nil {
+				return fmt.Errorf("failed to unmarshal accrual response: %w", err)
+			}
+			order.Status = ar.Status
+			order.Accrual = ar.Accrual
+			// the fetched result is stored even on shutdown, the trace is kept and
+			// the storage timeout still bounds the writes
+			if err := g.OrderUpdate(context.WithoutCancel(ctx), &order); err != nil {
+				logger.Sugar().Error("failed to update order in DB", zap.Error(err))
+			}
+		}
+		return nil
+	}
+}
+
+// Drain marks the service as shutting down, so that readiness fails while
+// in-flight requests complete.
+func (g *GophermartService) Drain() {
+	g.draining.Store(true)
+}
+
+// Readiness checks the dependencies of the service. Failed checks make the
+// service unready; degraded ones are only reported.
+func (g *GophermartService) Readiness(ctx context.Context) models.HealthReport {
+	report := models.HealthReport{
+		Ready:  true,
+		Checks: make(map[string]models.HealthCheck),
+	}
+	add := func(name string, hc models.HealthCheck) {
+		if hc.Status == models.HealthFail {
+			report.Ready = false
+		}
+		report.Checks[name] = hc
+	}
+
+	if g.draining.Load() {
+		add("shutdown", models.HealthCheck{Status: models.HealthFail, Detail: "shutting down"})
+	}
+
+	if err := g.store.Ping(ctx, g.cfg()); err != nil {
+		add("database", models.HealthCheck{Status: models.HealthFail, Detail: err.Error()})
+	} else {
+		add("database", models.HealthCheck{Status: models.HealthOK})
+	}
+
+	add("migrations", g.migrationsCheck(ctx))
+	add("dispatcher", g.dispatcherCheck())
+
+	if g.accrualOpen.Load() {
+		add("accrual", models.HealthCheck{Status: models.HealthDegraded, Detail: "backing off after 429"})
+	} else {
+		add("accrual", models.HealthCheck{Status: models.HealthOK})
+	}
+	return report
+}
+
+func (g *GophermartService) migrationsCheck(ctx context.Context) models.HealthCheck {
+	ms, err := g.store.MigrationStatus(ctx, g.cfg())
+	if err != nil {
+		return models.HealthCheck{Status: models.HealthFail, Detail: err.Error()}
+	}
+	if ms.Dirty {
+		return models.HealthCheck{Status: models.HealthFail, Detail: fmt.Sprintf("version %d is dirty", ms.Version)}
+	}
+	if ms.Version < ms.Latest {
+		return models.HealthCheck{Status: models.HealthFail,
+			Detail: fmt.Sprintf("version %d, expected %d", ms.Version, ms.Latest)}
+	}
+	return models.HealthCheck{Status: models.HealthOK, Detail: fmt.Sprintf("version %d", ms.Version)}
+}
+
+func (g *GophermartService) dispatcherCheck() models.HealthCheck {
+	beat := g.dispatcherBeat.Load()
+	if beat == 0 {
+		return models.HealthCheck{Status: models.HealthFail, Detail: "not started"}
+	}
+
+	age := time.Since(time.Unix(0, beat)).Truncate(time.Second)
+	staleAfter := dispatcherStaleFactor * max(g.cfg().AccrualInterval, g.cfg().AccrualHTTPTimeout)
+	switch {
+	case age <= staleAfter:
+		return models.HealthCheck{Status: models.HealthOK}
+	case g.accrualOpen.Load():
+		// workers are asleep until the accrual service accepts requests again
+		return models.HealthCheck{Status: models.HealthDegraded, Detail: fmt.Sprintf("idle for %s", age)}
+	default:
+		return models.HealthCheck{Status: models.HealthFail, Detail: fmt.Sprintf("no progress for %s", age)}
+	}
+}
+
+func (g *GophermartService) OrderUpdate(ctx context.Context, order *models.Order) error {
+	changed, err := g.store.UpdateOrder(ctx, g.cfg(), order)
+	if err != nil {
+		return fmt.Errorf("error updating order %s: %w", order.Number, err)
+	}
+	// another worker has already stored the same result
+	if !changed {
+		return nil
+	}
+	if order.Status == "PROCESSED" || order.Status == "INVALID" {
+		g.cfg().Metrics.OrderProcessed(order.Status, order.Uploaded)
+	}
+	if order.Status == "PROCESSED" && order.Accrual != 0 {
+		g.cfg().Metrics.Credited(order.Accrual)
+	}
+	return nil
+}
+
+func (g *GophermartService) IdempotencyReserve(ctx context.Context, rec models.IdempotencyRecord) (
+	models.IdempotencyRecord, bool, error) {
+	rec, created, err := g.store.IdempotencyReserve(ctx, g.cfg(), rec)
+	if err != nil {
+		return rec, false, fmt.Errorf("failed to reserve idempotency key for user %s: %w", rec.UserID, err)
+	}
+	return rec, created, nil
+}
+
+func (g *GophermartService) IdempotencySave(ctx context.Context, rec models.IdempotencyRecord) error {
+	if err := g.store.IdempotencySave(ctx, g.cfg(), rec); err != nil {
+		return fmt.Errorf("failed to save idempotent response for user %s: %w", rec.UserID, err)
+	}
+	return nil
+}
+
+func (g *GophermartService) IdempotencyRelease(ctx context.Context, userid string, key string) error {
+	if err := g.store.IdempotencyRelease(ctx, g.cfg(), userid, key); err != nil {
+		return fmt.Errorf("failed to release idempotency key for user %s: %w", userid, err)
+	}
+	return nil
+}
+
+// IdempotencyCleaner periodically removes idempotency records older than
+// the configured retention period.
+func (g *GophermartService) IdempotencyCleaner(ctx context.Context) error {
+	logger := logging.FromContext(ctx, g.cfg().Logger)
+	cleanupTicker := time.NewTicker(g.cfg().IdempotencyCleanup)
+	defer cleanupTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-cleanupTicker.C:
+			n, err := g.store.IdempotencyPurge(ctx, g.cfg())
+			if err != nil {
+				logger.Sugar().Error("failed to purge idempotency keys", zap.Error(err))
+				continue
+			}
+			logger.Sugar().Debugw("purged expired idempotency keys",
+				"count", n)
+		}
+	}
+}
+
+// PointsExpirer periodically expires points earned more than
+// PointsExpiryMonths ago. It does nothing if expiry is disabled.
+func (g *GophermartService) PointsExpirer(ctx context.Context) error {
+	logger := logging.FromContext(ctx, g.cfg().Logger)
+	if g.cfg().PointsExpiryMonths <= 0 {
+		return nil
+	}
+	expiryTicker := time.NewTicker(g.cfg().ExpiryInterval)
+	defer expiryTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-expiryTicker.C:
+			for ctx.Err() == nil {
+				n, err := g.store.LotsExpire(ctx, g.cfg(), expiryBatchSize)
+				if err != nil {
+					logger.Sugar().Error("failed to expire accrual lots", zap.Error(err))
+					break
+				}
+				if n > 0 {
+					logger.Sugar().Infow("accrual lots have expired",
+						"count", n)
+				}
+				if n < expiryBatchSize {
+					break
 				}
+			}
+		}
+	}
+}
 
-				if resp.StatusCode() == http.StatusOK {
-					if err := json.Unmarshal(resp.Body(), &ar); err != nil {
-						return fmt.Errorf("failed to unmarshal accrual response: %w", err)
-					}
-					order.Status = ar.Status
-					order.Accrual = ar.Accrual
-					if err := g.OrderUpdate(&order); err != nil {
-						logger.Sugar().Error("failed to update order in DB", zap.Error(err))
-					}
+// TierRecalculator periodically recalculates user tiers so that users whose
+// accruals dropped out of the rolling window are downgraded. Upgrades are
+// also applied as accruals are credited. Runs are scheduled TierInterval
+// after the last recorded one, so a run missed while the service was down is
+// caught up on start. It does nothing if no tiers are configured.
+func (g *GophermartService) TierRecalculator(ctx context.Context) error {
+	logger := logging.FromContext(ctx, g.cfg().Logger)
+	if len(g.cfg().Tiers) == 0 {
+		return nil
+	}
+
+	var wait time.Duration
+	since, ok, err := g.store.TiersSinceLastRun(ctx, g.cfg())
+	switch {
+	case err != nil:
+		logger.Sugar().Error("failed to get last tier recalculation, recalculating now", zap.Error(err))
+	case ok:
+		wait = max(g.cfg().TierInterval-since, 0)
+	}
+	tierTimer := time.NewTimer(wait)
+	defer tierTimer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tierTimer.C:
+			tierTimer.Reset(g.cfg().TierInterval)
+			n, err := g.store.TiersRecalculate(ctx, g.cfg())
+			if err != nil {
+				logger.Sugar().Error("failed to recalculate user tiers", zap.Error(err))
+				continue
+			}
+			if n > 0 {
+				logger.Sugar().Infow("user tiers have been recalculated",
+					"count", n)
+			}
+		}
+	}
+}
+
+// OrderEventsSubscribe returns the user's events numbered after lastID and a
+// channel of live events. The subscription is taken before the backlog is
+// read, so the caller must skip live events it has already seen.
+func (g *GophermartService) OrderEventsSubscribe(ctx context.Context, userid string, lastID int64) (
+	models.OrderEvents, <-chan models.OrderEvent, func(), error) {
+	ch, unsubscribe := g.broker.Subscribe(userid)
+
+	backlog, err := g.store.OrderEventsGet(ctx, g.cfg(), userid, lastID)
+	if err != nil {
+		unsubscribe()
+		return nil, nil, nil, fmt.Errorf("failed to get order events for user %s: %w", userid, err)
+	}
+	return backlog, ch, unsubscribe, nil
+}
+
+// OrderEventsListener feeds the in-process broker from Postgres notifications,
+// so that every instance sees events produced by any instance's dispatcher.
+func (g *GophermartService) OrderEventsListener(ctx context.Context) error {
+	logger := logging.FromContext(ctx, g.cfg().Logger)
+
+	for {
+		err := g.store.OrderEventsListen(ctx, g.cfg(), g.broker.Publish)
+		if err == nil {
+			return nil
+		}
+		logger.Sugar().Errorf("order events listener failed, retrying: %v", err)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(g.cfg().AccrualWorkerRetry):
+		}
+	}
+}
+
+func (g *GophermartService) WebhookAdd(ctx context.Context, wh models.Webhook) (models.Webhook, error) {
+	if wh.Secret == "" {
+		secret, err := helpers.GenerateSecret()
+		if err != nil {
+			return wh, fmt.Errorf("failed to register webhook for user %s: %w", wh.UserID, err)
+		}
+		wh.Secret = secret
+	}
+	wh, err := g.store.WebhookAdd(ctx, g.cfg(), wh)
+	if err != nil {
+		return wh, fmt.Errorf("failed to register webhook for user %s: %w", wh.UserID, err)
+	}
+	return wh, nil
+}
+
+func (g *GophermartService) WebhooksGet(ctx context.Context, userid string) (models.Webhooks, error) {
+	webhooks, err := g.store.WebhooksGet(ctx, g.cfg(), userid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks for user %s: %w", userid, err)
+	}
+	return webhooks, nil
+}
+
+func (g *GophermartService) WebhookDelete(ctx context.Context, userid string, id int64) (bool, error) {
+	ok, err := g.store.WebhookDelete(ctx, g.cfg(), userid, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete webhook for user %s: %w", userid, err)
+	}
+	return ok, nil
+}
+
+func (g *GophermartService) WebhookDeliveriesGet(ctx context.Context, userid string, id int64) (
+	models.WebhookDeliveries, error) {
+	d, err := g.store.WebhookDeliveriesGet(ctx, g.cfg(), userid, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook deliveries for user %s: %w", userid, err)
+	}
+	return d, nil
+}
+
+// WebhookDispatcher delivers queued webhook events, retrying failed
+// deliveries with exponential backoff until WebhookMaxAttempts is reached.
+func (g *GophermartService) WebhookDispatcher(ctx context.Context) error {
+	logger := logging.FromContext(ctx, g.cfg().Logger)
+	client := newWebhookClient(g.cfg().WebhookTimeout)
+
+	webhookTicker := time.NewTicker(g.cfg().WebhookInterval)
+	defer webhookTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-webhookTicker.C:
+			// entries are claimed one at a time, so that the lease only has to
+			// cover a single delivery and its recording
+			for range g.cfg().WebhookBatchSize {
+				if ctx.Err() != nil {
+					return nil
 				}
-				break
+				lease := g.cfg().WebhookTimeout + g.cfg().ContextTimeout
+				m, ok, err := g.store.WebhookOutboxClaim(ctx, g.cfg(), lease)
+				if err != nil {
+					logger.Sugar().Error("failed to claim webhook outbox", zap.Error(err))
+					break
+				}
+				if !ok {
+					break
+				}
+				g.deliverWebhook(ctx, client, m)
 			}
+			// picks up a reloaded interval
+			webhookTicker.Reset(g.cfg().WebhookInterval)
 		}
 	}
 }
 
-func (g *GophermartService) OrderUpdate(order *models.Order) error {
-	if err := g.store.UpdateOrder(g.config, order); err != nil {
-		return fmt.Errorf("error updating order %s: %w", order.Number, err)
+// newWebhookClient returns the client delivering webhooks to user supplied
+// URLs. It only connects to public addresses, bypasses proxies that could
+// reach internal ones and does not follow redirects.
+func newWebhookClient(timeout time.Duration) *resty.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: helpers.WebhookDialControl,
+	}
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: timeout,
+		MaxIdleConns:        webhookMaxIdleConns,
+		IdleConnTimeout:     webhookIdleConnTimeout,
+		ForceAttemptHTTP2:   true,
+	}
+	return resty.New().
+		SetTimeout(timeout).
+		SetRedirectPolicy(resty.NoRedirectPolicy()).
+		SetTransport(otelhttp.NewTransport(transport))
+}
+
+func (g *GophermartService) deliverWebhook(ctx context.Context, client *resty.Client, m models.WebhookMessage) {
+	logger := logging.FromContext(ctx, g.cfg().Logger)
+	start := time.Now()
+
+	d := models.WebhookDelivery{
+		OutboxID: m.ID,
+		Attempt:  m.Attempts,
 	}
-	if order.Accrual != 0 {
-		if err := g.store.UserAddAccrual(g.config, order); err != nil {
-			return fmt.Errorf("failed to add accrual for user %s: %w", order.UserID, err)
+	resp, err := client.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetHeader("X-Gophermart-Event", m.Event).
+		SetHeader("X-Gophermart-Delivery", strconv.FormatInt(m.ID, 10)).
+		SetHeader("X-Gophermart-Signature", helpers.SignWebhook(m.Secret, start.Unix(), m.Payload)).
+		SetBody(m.Payload).
+		Post(m.URL)
+	d.Duration = time.Since(start).Milliseconds()
+
+	if err != nil {
+		d.Error = err.Error()
+	} else {
+		d.StatusCode = int64(resp.StatusCode())
+		if !resp.IsSuccess() {
+			d.Error = resp.Status()
 		}
 	}
+	delivered := d.Error == ""
+
+	var retryIn time.Duration
+	if !delivered && m.Attempts < g.cfg().WebhookMaxAttempts {
+		retryIn = webhookBackoff(g.cfg().WebhookBackoff, g.cfg().WebhookBackoffMax, m.Attempts)
+	}
+	if err := g.store.WebhookDeliveryAdd(ctx, g.cfg(), d, delivered, retryIn); err != nil {
+		logger.Sugar().Error("failed to record webhook delivery", zap.Error(err))
+	}
+	if !delivered {
+		logger.Sugar().Debugw("webhook delivery failed",
+			"outboxID", m.ID,
+			"attempt", m.Attempts,
+			"error", d.Error)
+	}
+}
+
+func webhookBackoff(base time.Duration, maxBackoff time.Duration, attempt int64) time.Duration {
+	backoff := base
+	for i := int64(1); i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
+}
+
+// AdminsSync grants the admin role to the already registered users listed in
+// AdminUsers and revokes it from users no longer listed. Logins that are not
+// registered yet are not reserved, so an admin account must exist before it
+// is listed.
+func (g *GophermartService) AdminsSync(ctx context.Context) error {
+	logger := logging.FromContext(ctx, g.cfg().Logger)
+	promoted, demoted, err := g.store.UsersSyncAdmins(ctx, g.cfg(), g.cfg().AdminUsers)
+	if err != nil {
+		return fmt.Errorf("failed to sync admin users: %w", err)
+	}
+	logger.Sugar().Infow("admin users have been synced",
+		"promoted", promoted,
+		"demoted", demoted)
 	return nil
 }
+
+func (g *GophermartService) AdminUsersSearch(ctx context.Context, admin string, query string, limit int64,
+	offset int64) (
+	models.UserSummaries, error) {
+	if err := g.store.AuditAdd(ctx, g.cfg(), admin, models.AuditUsersSearch, query); err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+	users, err := g.store.UsersSearch(ctx, g.cfg(), query, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+	return users, nil
+}
+
+func (g *GophermartService) AdminOrdersGet(ctx context.Context, admin string, userid string) (models.Orders, error) {
+	if err := g.store.AuditAdd(ctx, g.cfg(), admin, models.AuditUserOrders, userid); err != nil {
+		return nil, fmt.Errorf("failed to get orders for user %s: %w", userid, err)
+	}
+	return g.OrdersGet(ctx, userid)
+}
+
+func (g *GophermartService) AdminWithdrawalsGet(ctx context.Context, admin string, userid string) (
+	models.Withdrawals, error) {
+	if err := g.store.AuditAdd(ctx, g.cfg(), admin, models.AuditUserWithdrawals, userid); err != nil {
+		return nil, fmt.Errorf("failed to get withdrawals for user %s: %w", userid, err)
+	}
+	return g.WithdrawalsGet(ctx, userid)
+}
+
+func (g *GophermartService) AdminBalanceGet(ctx context.Context, admin string, userid string) (models.Balance, error) {
+	if err := g.store.AuditAdd(ctx, g.cfg(), admin, models.AuditUserBalance, userid); err != nil {
+		return models.Balance{}, fmt.Errorf("failed to get balance for user %s: %w", userid, err)
+	}
+	return g.BalanceGet(ctx, userid)
+}
+
+func (g *GophermartService) AdminUserLock(ctx context.Context, admin string, userid string, locked bool) (bool, error) {
+	logger := logging.FromContext(ctx, g.cfg().Logger)
+	ok, err := g.store.UserLockSet(ctx, g.cfg(), admin, userid, locked)
+	if err != nil {
+		return false, fmt.Errorf("failed to update lock of user %s: %w", userid, err)
+	}
+	if ok {
+		logger.Sugar().Infow("user lock has been updated",
+			"admin", admin,
+			"userID", userid,
+			"locked", locked)
+	}
+	return ok, nil
+}
+
+func (g *GophermartService) AdminOrderRecheck(ctx context.Context, admin string, oid string) (models.Order, error) {
+	order, err := g.store.OrderRecheck(ctx, g.cfg(), admin, oid)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("failed to recheck order %s: %w", oid, err)
+	}
+	return order, nil
+}
+
+func (g *GophermartService) AdminAuditGet(ctx context.Context, admin string, limit int64, offset int64) (
+	models.AuditEntries, error) {
+	if err := g.store.AuditAdd(ctx, g.cfg(), admin, models.AuditLogView, ""); err != nil {
+		return nil, fmt.Errorf("failed to get admin audit: %w", err)
+	}
+	entries, err := g.store.AuditGet(ctx, g.cfg(), limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get admin audit: %w", err)
+	}
+	return entries, nil
+}
+
+// AdminAdjustmentAdd creates a manual balance adjustment. Adjustments above
+// the AdjustmentApproval threshold stay pending until another admin approves
+// them, the others are applied immediately.
+func (g *GophermartService) AdminAdjustmentAdd(ctx context.Context, admin string, a models.Adjustment) (
+	models.Adjustment, error) {
+	logger := logging.FromContext(ctx, g.cfg().Logger)
+	a.CreatedBy = admin
+	a.Status = models.AdjustmentApplied
+	if g.cfg().AdjustmentApproval > 0 && a.Amount > g.cfg().AdjustmentApproval {
+		a.Status = models.AdjustmentPending
+	} else {
+		a.DecidedBy = admin
+	}
+
+	a, err := g.store.AdjustmentAdd(ctx, g.cfg(), a)
+	if err != nil {
+		return a, fmt.Errorf("failed to create adjustment for user %s: %w", a.UserID, err)
+	}
+	logger.Sugar().Infow("balance adjustment has been created",
+		"admin", admin,
+		"userID", a.UserID,
+		"id", a.ID,
+		"status", a.Status)
+	return a, nil
+}
+
+func (g *GophermartService) AdminAdjustmentDecide(ctx context.Context, admin string, id int64, approve bool) (
+	models.Adjustment, error) {
+	logger := logging.FromContext(ctx, g.cfg().Logger)
+	a, err := g.store.AdjustmentDecide(ctx, g.cfg(), admin, id, approve)
+	if err != nil {
+		return a, fmt.Errorf("failed to decide adjustment %d: %w", id, err)
+	}
+	logger.Sugar().Infow("balance adjustment has been decided",
+		"admin", admin,
+		"id", id,
+		"status", a.Status)
+	return a, nil
+}
+
+func (g *GophermartService) AdminAdjustmentsGet(ctx context.Context, admin string, status string, limit int64,
+	offset int64) (
+	models.Adjustments, error) {
+	if err := g.store.AuditAdd(ctx, g.cfg(), admin, models.AuditAdjustmentsView, status); err != nil {
+		return nil, fmt.Errorf("failed to get adjustments: %w", err)
+	}
+	adjustments, err := g.store.AdjustmentsGet(ctx, g.cfg(), status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get adjustments: %w", err)
+	}
+	return adjustments, nil
+}
+
+func (g *GophermartService) AdminReversalDecide(ctx context.Context, admin string, id int64, approve bool) (
+	models.WithdrawalReversal, error) {
+	logger := logging.FromContext(ctx, g.cfg().Logger)
+	r, err := g.store.ReversalDecide(ctx, g.cfg(), admin, id, approve)
+	if err != nil {
+		return r, fmt.Errorf("failed to decide reversal %d: %w", id, err)
+	}
+	logger.Sugar().Infow("withdrawal refund has been decided",
+		"admin", admin,
+		"userID", r.UserID,
+		"id", id,
+		"status", r.Status)
+	return r, nil
+}
+
+func (g *GophermartService) AdminReversalsGet(ctx context.Context, admin string, status string, limit int64,
+	offset int64) (
+	models.WithdrawalReversals, error) {
+	if err := g.store.AuditAdd(ctx, g.cfg(), admin, models.AuditReversalsView, status); err != nil {
+		return nil, fmt.Errorf("failed to get reversals: %w", err)
+	}
+	reversals, err := g.store.ReversalsGet(ctx, g.cfg(), status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reversals: %w", err)
+	}
+	return reversals, nil
+}
+
+// AdminCampaignAdd creates a campaign, its bonuses are granted as orders
+// are credited between the start and end dates.
+func (g *GophermartService) AdminCampaignAdd(ctx context.Context, admin string, cmp models.Campaign) (
+	models.Campaign, error) {
+	logger := logging.FromContext(ctx, g.cfg().Logger)
+	cmp.CreatedBy = admin
+	cmp.Starts = cmp.Starts.UTC()
+	cmp.Ends = cmp.Ends.UTC()
+
+	cmp, err := g.store.CampaignAdd(ctx, g.cfg(), cmp)
+	if err != nil {
+		return cmp, fmt.Errorf("failed to create campaign %s: %w", cmp.Name, err)
+	}
+	logger.Sugar().Infow("campaign has been created",
+		"admin", admin,
+		"id", cmp.ID,
+		"rule", cmp.Rule)
+	return cmp, nil
+}
+
+func (g *GophermartService) AdminCampaignStop(ctx context.Context, admin string, id int64) (models.Campaign, error) {
+	logger := logging.FromContext(ctx, g.cfg().Logger)
+	cmp, err := g.store.CampaignStop(ctx, g.cfg(), admin, id)
+	if err != nil {
+		return cmp, fmt.Errorf("failed to stop campaign %d: %w", id, err)
+	}
+	logger.Sugar().Infow("campaign has been stopped",
+		"admin", admin,
+		"id", id)
+	return cmp, nil
+}
+
+func (g *GophermartService) AdminCampaignsGet(ctx context.Context, admin string, limit int64, offset int64) (
+	models.Campaigns, error) {
+	if err := g.store.AuditAdd(ctx, g.cfg(), admin, models.AuditCampaignsView, ""); err != nil {
+		return nil, fmt.Errorf("failed to get campaigns: %w", err)
+	}
+	campaigns, err := g.store.CampaignsGet(ctx, g.cfg(), limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get campaigns: %w", err)
+	}
+	return campaigns, nil
+}
+
+func (g *GophermartService) AdminWithdrawalReverse(ctx context.Context, admin string, r models.WithdrawalReversal) (
+	models.WithdrawalReversal, error) {
+	logger := logging.FromContext(ctx, g.cfg().Logger)
+	r.CreatedBy = admin
+	r, err := g.store.WithdrawalReverse(ctx, g.cfg(), admin, r)
+	if err != nil {
+		return r, fmt.Errorf("failed to reverse withdrawal %s: %w", r.Number, err)
+	}
+	logger.Sugar().Infow("withdrawal has been reversed",
+		"admin", admin,
+		"userID", r.UserID,
+		"order", r.Number,
+		"sum", r.Sum)
+	return r, nil
+}
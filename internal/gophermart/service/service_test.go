@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
+	mock_service "github.com/vkupriya/go-gophermart/internal/gophermart/service/mocks"
+)
+
+func newTestService(cfg *models.Config, store Storage) *GophermartService {
+	g := &GophermartService{store: store}
+	g.config.Store(cfg)
+	return g
+}
+
+func TestFetchAccrualStoredOnShutdown(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"order":"2377225624","status":"PROCESSED","accrual":500}`))
+	}))
+	defer srv.Close()
+
+	cfg := &models.Config{Logger: zap.NewNop(), AccrualAddress: srv.URL}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the service shuts down once the accrual response has been received
+	client := resty.New().OnAfterResponse(func(*resty.Client, *resty.Response) error {
+		cancel()
+		return nil
+	})
+
+	var storeErr error
+	ctrl := gomock.NewController(t)
+	s := mock_service.NewMockStorage(ctrl)
+	order := &models.Order{UserID: "user01", Number: "2377225624", Status: "PROCESSED", Accrual: 500}
+	s.EXPECT().UpdateOrder(gomock.Any(), cfg, order).DoAndReturn(
+		func(ctx context.Context, _ *models.Config, _ *models.Order) (bool, error) {
+			storeErr = ctx.Err()
+			return storeErr == nil, storeErr
+		})
+
+	g := newTestService(cfg, s)
+	var rf atomic.Bool
+	err := g.fetchAccrual(ctx, client, models.Order{UserID: "user01", Number: "2377225624", Status: "NEW"}, &rf)
+	require.NoError(t, err)
+	require.Error(t, ctx.Err())
+	require.NoError(t, storeErr)
+}
@@ -0,0 +1,16 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE idempotency_keys(
+    userid VARCHAR(200) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    request_hash VARCHAR(64) NOT NULL,
+    status INTEGER NOT NULL DEFAULT 0,
+    content_type VARCHAR(200) NOT NULL DEFAULT '',
+    body BYTEA,
+    created_at timestamp NOT NULL,
+    PRIMARY KEY (userid, key)
+);
+
+CREATE INDEX idempotency_keys_created_at_idx ON idempotency_keys (created_at);
+
+COMMIT;
@@ -0,0 +1,25 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE order_events(
+    id BIGSERIAL PRIMARY KEY,
+    userid VARCHAR(200) NOT NULL,
+    number VARCHAR(200) NOT NULL,
+    type VARCHAR(50) NOT NULL,
+    status VARCHAR(200) NOT NULL,
+    accrual FLOAT NOT NULL,
+    created_at timestamp NOT NULL
+);
+
+CREATE INDEX order_events_userid_id_idx ON order_events (userid, id);
+
+CREATE FUNCTION notify_order_event() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('order_events', NEW.id::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER order_events_notify AFTER INSERT ON order_events
+    FOR EACH ROW EXECUTE FUNCTION notify_order_event();
+
+COMMIT;
@@ -0,0 +1,40 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE webhooks(
+    id BIGSERIAL PRIMARY KEY,
+    userid VARCHAR(200) NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(200) NOT NULL,
+    created_at timestamp NOT NULL
+);
+
+CREATE INDEX webhooks_userid_idx ON webhooks (userid);
+
+CREATE TABLE webhook_outbox(
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id BIGINT NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at timestamp NOT NULL,
+    delivered_at timestamp,
+    failed_at timestamp,
+    created_at timestamp NOT NULL
+);
+
+CREATE INDEX webhook_outbox_pending_idx ON webhook_outbox (next_attempt_at)
+    WHERE delivered_at IS NULL AND failed_at IS NULL;
+
+CREATE TABLE webhook_deliveries(
+    id BIGSERIAL PRIMARY KEY,
+    outbox_id BIGINT NOT NULL REFERENCES webhook_outbox (id) ON DELETE CASCADE,
+    attempt INTEGER NOT NULL,
+    status_code INTEGER NOT NULL,
+    error TEXT NOT NULL,
+    duration_ms BIGINT NOT NULL,
+    created_at timestamp NOT NULL
+);
+
+CREATE INDEX webhook_deliveries_outbox_id_idx ON webhook_deliveries (outbox_id);
+
+COMMIT;
@@ -0,0 +1,17 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE users
+    ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user',
+    ADD COLUMN locked BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE admin_audit(
+    id BIGSERIAL PRIMARY KEY,
+    admin VARCHAR(200) NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    target VARCHAR(200) NOT NULL,
+    created_at timestamp NOT NULL
+);
+
+CREATE INDEX admin_audit_created_at_idx ON admin_audit (created_at);
+
+COMMIT;
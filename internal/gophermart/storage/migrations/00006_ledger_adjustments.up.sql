@@ -0,0 +1,40 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE ledger(
+    id BIGSERIAL PRIMARY KEY,
+    userid VARCHAR(200) NOT NULL,
+    type VARCHAR(50) NOT NULL,
+    amount FLOAT NOT NULL,
+    number VARCHAR(200) NOT NULL DEFAULT '',
+    reason VARCHAR(50) NOT NULL DEFAULT '',
+    ref_id BIGINT NOT NULL DEFAULT 0,
+    created_at timestamp NOT NULL
+);
+
+CREATE INDEX ledger_userid_idx ON ledger (userid, id);
+
+INSERT INTO ledger (userid, type, amount, number, created_at)
+    SELECT userid, 'accrual', accrual, number, COALESCE(uploaded_at, now())
+    FROM orders WHERE status='PROCESSED' AND accrual > 0;
+
+INSERT INTO ledger (userid, type, amount, number, created_at)
+    SELECT userid, 'withdrawal', -sum, number, COALESCE(processed_at, now())
+    FROM withdrawals;
+
+CREATE TABLE adjustments(
+    id BIGSERIAL PRIMARY KEY,
+    userid VARCHAR(200) NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    amount FLOAT NOT NULL CHECK (amount > 0),
+    reason VARCHAR(50) NOT NULL,
+    note TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    created_by VARCHAR(200) NOT NULL,
+    decided_by VARCHAR(200) NOT NULL DEFAULT '',
+    created_at timestamp NOT NULL,
+    decided_at timestamp
+);
+
+CREATE INDEX adjustments_status_idx ON adjustments (status, id);
+
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE withdrawal_reversals(
+    id BIGSERIAL PRIMARY KEY,
+    userid VARCHAR(200) NOT NULL,
+    number VARCHAR(200) NOT NULL REFERENCES withdrawals (number),
+    sum FLOAT NOT NULL CHECK (sum > 0),
+    reason VARCHAR(200) NOT NULL DEFAULT '',
+    created_by VARCHAR(200) NOT NULL,
+    created_at timestamp NOT NULL
+);
+
+CREATE INDEX withdrawal_reversals_number_idx ON withdrawal_reversals (number);
+
+COMMIT;
@@ -0,0 +1,34 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE accrual_lots(
+    id BIGSERIAL PRIMARY KEY,
+    userid VARCHAR(200) NOT NULL,
+    number VARCHAR(200) NOT NULL DEFAULT '',
+    amount FLOAT NOT NULL,
+    remaining FLOAT NOT NULL,
+    expired FLOAT NOT NULL DEFAULT 0,
+    earned_at timestamp NOT NULL,
+    expired_at timestamp
+);
+
+CREATE INDEX accrual_lots_userid_idx ON accrual_lots (userid, earned_at) WHERE remaining > 0;
+CREATE INDEX accrual_lots_earned_at_idx ON accrual_lots (earned_at) WHERE remaining > 0;
+
+-- every credit in the ledger becomes a lot, debits made so far are consumed
+-- from the oldest lots first
+INSERT INTO accrual_lots (userid, number, amount, remaining, earned_at)
+    SELECT l.userid, l.number, l.amount,
+        LEAST(l.amount, GREATEST(0,
+            SUM(l.amount) OVER (PARTITION BY l.userid ORDER BY l.created_at, l.id) - d.debited)),
+        l.created_at
+    FROM ledger l
+    JOIN (
+        SELECT c.userid, c.credited - u.accrual AS debited
+        FROM (SELECT userid, SUM(amount) AS credited FROM ledger WHERE amount > 0 GROUP BY userid) c
+        JOIN users u ON u.userid = c.userid
+    ) d ON d.userid = l.userid
+    WHERE l.amount > 0;
+
+DELETE FROM accrual_lots WHERE remaining <= 0;
+
+COMMIT;
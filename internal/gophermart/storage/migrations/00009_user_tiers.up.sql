@@ -0,0 +1,7 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE users ADD COLUMN tier VARCHAR(50) NOT NULL DEFAULT '';
+
+CREATE INDEX orders_userid_idx ON orders (userid, uploaded_at) WHERE status = 'PROCESSED';
+
+COMMIT;
@@ -0,0 +1,33 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE campaigns(
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL,
+    rule VARCHAR(20) NOT NULL,
+    amount FLOAT NOT NULL DEFAULT 0,
+    multiplier FLOAT NOT NULL DEFAULT 0,
+    threshold FLOAT NOT NULL DEFAULT 0,
+    budget FLOAT NOT NULL DEFAULT 0,
+    user_budget FLOAT NOT NULL DEFAULT 0,
+    spent FLOAT NOT NULL DEFAULT 0,
+    active BOOLEAN NOT NULL DEFAULT true,
+    starts_at timestamp NOT NULL,
+    ends_at timestamp NOT NULL,
+    created_by VARCHAR(200) NOT NULL,
+    created_at timestamp NOT NULL
+);
+
+CREATE INDEX campaigns_active_idx ON campaigns (starts_at, ends_at) WHERE active;
+
+CREATE TABLE campaign_bonuses(
+    id BIGSERIAL PRIMARY KEY,
+    campaign_id BIGINT NOT NULL REFERENCES campaigns(id),
+    userid VARCHAR(200) NOT NULL,
+    number VARCHAR(200) NOT NULL,
+    amount FLOAT NOT NULL,
+    created_at timestamp NOT NULL
+);
+
+CREATE INDEX campaign_bonuses_campaign_idx ON campaign_bonuses (campaign_id, userid);
+
+COMMIT;
@@ -0,0 +1,22 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE users ADD COLUMN invite_code VARCHAR(20);
+UPDATE users SET invite_code = upper(substr(md5(random()::text || userid), 1, 10));
+ALTER TABLE users ALTER COLUMN invite_code SET NOT NULL;
+CREATE UNIQUE INDEX users_invite_code_idx ON users (invite_code);
+
+CREATE TABLE referrals(
+    id BIGSERIAL PRIMARY KEY,
+    referrer VARCHAR(200) NOT NULL,
+    referee VARCHAR(200) NOT NULL UNIQUE,
+    rewarded BOOLEAN NOT NULL DEFAULT false,
+    referrer_bonus FLOAT NOT NULL DEFAULT 0,
+    referee_bonus FLOAT NOT NULL DEFAULT 0,
+    created_at timestamp NOT NULL,
+    rewarded_at timestamp,
+    CHECK (referrer <> referee)
+);
+
+CREATE INDEX referrals_referrer_idx ON referrals (referrer);
+
+COMMIT;
@@ -0,0 +1,14 @@
+BEGIN TRANSACTION;
+
+CREATE TABLE transfers(
+    id BIGSERIAL PRIMARY KEY,
+    sender VARCHAR(200) NOT NULL,
+    recipient VARCHAR(200) NOT NULL,
+    amount FLOAT NOT NULL CHECK (amount > 0),
+    created_at timestamp NOT NULL,
+    CHECK (sender <> recipient)
+);
+
+CREATE INDEX transfers_sender_idx ON transfers (sender, created_at);
+
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN TRANSACTION;
+
+ALTER TABLE users ADD COLUMN password_changed_at timestamp;
+ALTER TABLE users ADD COLUMN new_ip_at timestamp;
+
+CREATE TABLE user_logins(
+    userid VARCHAR(200) NOT NULL,
+    ip VARCHAR(64) NOT NULL,
+    first_seen timestamp NOT NULL,
+    last_seen timestamp NOT NULL,
+    PRIMARY KEY (userid, ip)
+);
+
+CREATE INDEX withdrawals_userid_idx ON withdrawals (userid, processed_at);
+
+COMMIT;
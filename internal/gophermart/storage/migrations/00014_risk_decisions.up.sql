@@ -0,0 +1,32 @@
+BEGIN TRANSACTION;
+
+-- existing users get the migration time as their registration time
+ALTER TABLE users ADD COLUMN created_at timestamp NOT NULL DEFAULT now();
+
+CREATE TABLE order_conflicts(
+    userid VARCHAR(200) NOT NULL,
+    number VARCHAR(200) NOT NULL,
+    created_at timestamp NOT NULL
+);
+
+CREATE INDEX order_conflicts_userid_idx ON order_conflicts (userid, created_at);
+
+CREATE TABLE risk_decisions(
+    id BIGSERIAL PRIMARY KEY,
+    userid VARCHAR(200) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    subject VARCHAR(200) NOT NULL,
+    amount FLOAT NOT NULL DEFAULT 0,
+    score BIGINT NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    reasons TEXT[] NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT '',
+    reviewed_by VARCHAR(200) NOT NULL DEFAULT '',
+    created_at timestamp NOT NULL,
+    reviewed_at timestamp
+);
+
+CREATE INDEX risk_decisions_action_idx ON risk_decisions (action, id);
+CREATE INDEX risk_decisions_open_idx ON risk_decisions (userid) WHERE status = 'open';
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN TRANSACTION;
+
+CREATE INDEX orders_pending_idx ON orders (userid) WHERE status IN ('NEW', 'PROCESSING');
+
+COMMIT;
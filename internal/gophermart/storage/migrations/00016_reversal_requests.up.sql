@@ -0,0 +1,13 @@
+BEGIN TRANSACTION;
+
+-- reversals requested by users stay pending until an admin decides them,
+-- existing reversals were applied when created
+ALTER TABLE withdrawal_reversals ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'applied';
+ALTER TABLE withdrawal_reversals ADD COLUMN decided_by VARCHAR(200) NOT NULL DEFAULT '';
+ALTER TABLE withdrawal_reversals ADD COLUMN decided_at timestamp;
+
+UPDATE withdrawal_reversals SET decided_by = created_by, decided_at = created_at;
+
+CREATE INDEX withdrawal_reversals_status_idx ON withdrawal_reversals (status, id);
+
+COMMIT;
@@ -0,0 +1,14 @@
+BEGIN TRANSACTION;
+
+-- lots consumed by each withdrawal, so that a reversal restores them with
+-- their original expiry
+CREATE TABLE accrual_lot_debits(
+    id BIGSERIAL PRIMARY KEY,
+    lot_id BIGINT NOT NULL,
+    number VARCHAR(200) NOT NULL,
+    amount FLOAT NOT NULL
+);
+
+CREATE INDEX accrual_lot_debits_number_idx ON accrual_lot_debits (number) WHERE amount > 0;
+
+COMMIT;
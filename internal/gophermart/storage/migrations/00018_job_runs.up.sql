@@ -0,0 +1,9 @@
+BEGIN TRANSACTION;
+
+-- last completed run of periodic jobs, so that a restart does not delay them
+CREATE TABLE job_runs(
+    name VARCHAR(50) PRIMARY KEY,
+    last_run timestamp NOT NULL
+);
+
+COMMIT;
@@ -0,0 +1,18 @@
+BEGIN TRANSACTION;
+
+-- ids are assigned on insert, events are numbered in commit order instead:
+-- the counter row stays locked until the inserting transaction ends
+CREATE TABLE order_event_seq(
+    last BIGINT NOT NULL
+);
+
+INSERT INTO order_event_seq (last) SELECT COALESCE(MAX(id), 0) FROM order_events;
+
+ALTER TABLE order_events ADD COLUMN seq BIGINT;
+UPDATE order_events SET seq = id;
+ALTER TABLE order_events ALTER COLUMN seq SET NOT NULL;
+
+DROP INDEX order_events_userid_id_idx;
+CREATE UNIQUE INDEX order_events_userid_seq_idx ON order_events (userid, seq);
+
+COMMIT;
@@ -3,8 +3,13 @@ package storage
 import (
 	"context"
 	"embed"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/fs"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-migrate/migrate/v4"
@@ -15,6 +20,7 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/vkupriya/go-gophermart/internal/gophermart/helpers"
 	"github.com/vkupriya/go-gophermart/internal/gophermart/models"
 )
 
@@ -26,6 +32,23 @@ const (
 	errRollback string = "failed to rollback transaction: %w"
 )
 
+// number of upcoming expiration dates reported in the balance
+const upcomingExpirations int64 = 5
+
+// tiers are based on accruals of this many recent months
+const tierWindowMonths int64 = 12
+
+// amounts of lots below this are rounding errors of float sums
+const lotRoundingError float32 = 1e-3
+
+// name of the tier recalculation job in job_runs
+const jobTiers string = "tiers"
+
+// execer is implemented by both the pool and transactions.
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
 func NewPostgresDB(dsn string) (*PostgresDB, error) {
 	if err := runMigrations(dsn); err != nil {
 		return nil, fmt.Errorf("failed to run DB migrations: %w", err)
@@ -36,6 +59,8 @@ func NewPostgresDB(dsn string) (*PostgresDB, error) {
 		return nil, fmt.Errorf("failed to parse the DSN: %w", err)
 	}
 
+	poolCfg.ConnConfig.Tracer = queryTracer{}
+
 	ctx := context.Background()
 
 	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
@@ -69,43 +94,88 @@ func runMigrations(dsn string) error {
 	return nil
 }
 
-func (p *PostgresDB) UserAdd(c *models.Config, u models.User) error {
+// UserAdd registers a user with a new invite code. A non-empty referral
+// code links the user to the referrer, who must be an existing unlocked
+// user. Referrals are only set at registration, so a user cannot refer
+// themselves or anyone up their own referral chain.
+func (p *PostgresDB) UserAdd(ctx context.Context, c *models.Config, u models.User) error {
 	db := p.pool
 	var pgErr *pgconn.PgError
-	ctx, cancel := context.WithTimeout(context.Background(), c.ContextTimeout)
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
 	defer cancel()
 
-	querySQL := "INSERT INTO users (userid, password, accrual) VALUES($1, $2, $3)"
+	code, err := helpers.GenerateInviteCode()
+	if err != nil {
+		return fmt.Errorf("failed to create invite code for user %s: %w", u.UserID, err)
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	querySQL := "INSERT INTO users (userid, password, accrual, invite_code) VALUES($1, $2, $3, $4)"
 
-	_, err := db.Exec(ctx, querySQL, u.UserID, u.Password, u.Accrual)
+	_, err = tx.Exec(ctx, querySQL, u.UserID, u.Password, u.Accrual, code)
 	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return fmt.Errorf(errRollback, err)
+		}
 		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
 			return fmt.Errorf("user already exists: %w", err)
 		}
 		return fmt.Errorf("failed to insert user %s into Postgres DB: %w", u.UserID, err)
 	}
+
+	if u.ReferralCode != "" {
+		querySQL = `INSERT INTO referrals (referrer, referee, created_at)
+			SELECT userid, $2, now() FROM users WHERE invite_code=upper($1) AND userid <> $2 AND NOT locked`
+
+		tag, err := tx.Exec(ctx, querySQL, u.ReferralCode, u.UserID)
+		if err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				return fmt.Errorf(errRollback, err)
+			}
+			return fmt.Errorf("failed to insert referral of user %s into Postgres DB: %w", u.UserID, err)
+		}
+		if tag.RowsAffected() == 0 {
+			if err := tx.Rollback(ctx); err != nil {
+				return fmt.Errorf(errRollback, err)
+			}
+			return fmt.Errorf("code %s: %w", u.ReferralCode, models.ErrInvalidReferral)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit user %s transaction: %w", u.UserID, err)
+	}
 	return nil
 }
 
-func (p *PostgresDB) UserGet(c *models.Config, userid string) (models.User, error) {
+func (p *PostgresDB) UserGet(ctx context.Context, c *models.Config, userid string) (models.User, error) {
 	db := p.pool
 	var user models.User
-	ctx, cancel := context.WithTimeout(context.Background(), c.ContextTimeout)
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
 	defer cancel()
 
-	row := db.QueryRow(ctx, "SELECT * FROM users WHERE userid=$1", userid)
-	err := row.Scan(&user.UserID, &user.Password, &user.Accrual)
+	querySQL := "SELECT userid, password, accrual, role, locked FROM users WHERE userid=$1"
+
+	row := db.QueryRow(ctx, querySQL, userid)
+	err := row.Scan(&user.UserID, &user.Password, &user.Accrual, &user.Role, &user.Locked)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.User{}, fmt.Errorf("user %s: %w", userid, models.ErrNotFound)
+		}
 		return models.User{}, fmt.Errorf("failed to query user in DB: %w", err)
 	}
 
 	return user, nil
 }
 
-func (p *PostgresDB) OrderAdd(c *models.Config, userid string, oid string) error {
+func (p *PostgresDB) OrderAdd(ctx context.Context, c *models.Config, userid string, oid string) error {
 	db := p.pool
 	var pgErr *pgconn.PgError
-	ctx, cancel := context.WithTimeout(context.Background(), c.ContextTimeout)
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
 	defer cancel()
 
 	t := time.Now().Format(time.RFC3339)
@@ -122,10 +192,87 @@ func (p *PostgresDB) OrderAdd(c *models.Config, userid string, oid string) error
 	return nil
 }
 
-func (p *PostgresDB) OrderGet(c *models.Config, oid string) (models.Order, error) {
+func (p *PostgresDB) OrdersAddBatch(ctx context.Context, c *models.Config, userid string, oids []string) (
+	models.OrderBatchResults, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	t := time.Now().Format(time.RFC3339)
+	querySQL := `INSERT INTO orders (userid, number, status, accrual, uploaded_at)
+		SELECT $1, n, 'NEW', 0, $3 FROM unnest($2::varchar[]) AS n
+		ON CONFLICT (number) DO NOTHING RETURNING number`
+
+	rows, err := tx.Query(ctx, querySQL, userid, oids, t)
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return nil, fmt.Errorf(errRollback, err)
+		}
+		return nil, fmt.Errorf("failed to insert orders for user %s into Postgres DB: %w", userid, err)
+	}
+	inserted, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return nil, fmt.Errorf(errRollback, err)
+		}
+		return nil, fmt.Errorf("failed to scan inserted orders: %w", err)
+	}
+
+	accepted := make(map[string]bool, len(inserted))
+	for _, n := range inserted {
+		accepted[n] = true
+	}
+
+	owners := make(map[string]string)
+	if len(inserted) < len(oids) {
+		querySQL = "SELECT number, userid FROM orders WHERE number = ANY($1::varchar[])"
+		rows, err := tx.Query(ctx, querySQL, oids)
+		if err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				return nil, fmt.Errorf(errRollback, err)
+			}
+			return nil, fmt.Errorf("failed to query existing orders in DB: %w", err)
+		}
+		var number, owner string
+		_, err = pgx.ForEachRow(rows, []any{&number, &owner}, func() error {
+			owners[number] = owner
+			return nil
+		})
+		if err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				return nil, fmt.Errorf(errRollback, err)
+			}
+			return nil, fmt.Errorf("failed to scan existing orders: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit orders batch transaction for user %s: %w", userid, err)
+	}
+
+	results := make(models.OrderBatchResults, 0, len(oids))
+	for _, oid := range oids {
+		status := models.BatchOwnedByOther
+		switch {
+		case accepted[oid]:
+			status = models.BatchAccepted
+		case owners[oid] == userid:
+			status = models.BatchDuplicateOwn
+		}
+		results = append(results, models.OrderBatchResult{Number: oid, Status: status})
+	}
+	return results, nil
+}
+
+func (p *PostgresDB) OrderGet(ctx context.Context, c *models.Config, oid string) (models.Order, error) {
 	db := p.pool
 	var order models.Order
-	ctx, cancel := context.WithTimeout(context.Background(), c.ContextTimeout)
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
 	defer cancel()
 
 	querySQL := "SELECT * FROM orders WHERE number=$1"
@@ -142,10 +289,10 @@ func (p *PostgresDB) OrderGet(c *models.Config, oid string) (models.Order, error
 	return order, nil
 }
 
-func (p *PostgresDB) OrdersGet(c *models.Config, userid string) (models.Orders, error) {
+func (p *PostgresDB) OrdersGet(ctx context.Context, c *models.Config, userid string) (models.Orders, error) {
 	db := p.pool
 
-	ctx, cancel := context.WithTimeout(context.Background(), c.ContextTimeout)
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
 	defer cancel()
 
 	querySQL := "SELECT * FROM orders WHERE userid=$1 ORDER BY uploaded_at ASC"
@@ -163,14 +310,14 @@ func (p *PostgresDB) OrdersGet(c *models.Config, userid string) (models.Orders,
 	return orders, nil
 }
 
-func (p *PostgresDB) BalanceGet(c *models.Config, userid string) (models.Balance, error) {
+func (p *PostgresDB) BalanceGet(ctx context.Context, c *models.Config, userid string) (models.Balance, error) {
 	db := p.pool
 
 	balance := models.Balance{}
 	var accrual float32
 	var sum float32
 
-	ctx, cancel := context.WithTimeout(context.Background(), c.ContextTimeout)
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
 	defer cancel()
 
 	tx, err := db.Begin(ctx)
@@ -178,11 +325,17 @@ func (p *PostgresDB) BalanceGet(c *models.Config, userid string) (models.Balance
 		return balance, fmt.Errorf("failed to start transaction: %w", err)
 	}
 
-	querySQL := "SELECT (accrual) FROM users WHERE userid=$1"
+	querySQL := `SELECT u.accrual, u.tier, p.orders, p.accrual,
+		(SELECT COALESCE(SUM(amount), 0) FROM risk_decisions
+			WHERE userid=$1 AND kind=$2 AND status=$3)
+		FROM users u,
+		(SELECT COUNT(*) AS orders, COALESCE(SUM(accrual), 0) AS accrual FROM orders
+			WHERE userid=$1 AND status IN ('NEW', 'PROCESSING')) p
+		WHERE u.userid=$1`
 
-	row := tx.QueryRow(ctx, querySQL, userid)
+	row := tx.QueryRow(ctx, querySQL, userid, models.RiskWithdrawal, models.RiskOpen)
 
-	err = row.Scan(&accrual)
+	err = row.Scan(&accrual, &balance.Tier, &balance.PendingOrders, &balance.Pending, &balance.Held)
 	if err != nil {
 		if err := tx.Rollback(ctx); err != nil {
 			return balance, fmt.Errorf(errRollback, err)
@@ -191,7 +344,9 @@ func (p *PostgresDB) BalanceGet(c *models.Config, userid string) (models.Balance
 	}
 	balance.Current = accrual
 
-	querySQL = "SELECT COALESCE(SUM(sum), 0) FROM withdrawals WHERE userid=$1"
+	querySQL = `SELECT COALESCE(SUM(sum), 0)
+		- (SELECT COALESCE(SUM(sum), 0) FROM withdrawal_reversals WHERE userid=$1 AND status='applied')
+		FROM withdrawals WHERE userid=$1`
 
 	row = tx.QueryRow(ctx, querySQL, userid)
 
@@ -202,6 +357,24 @@ func (p *PostgresDB) BalanceGet(c *models.Config, userid string) (models.Balance
 		}
 		return balance, fmt.Errorf("failed to query withdrawals table in DB: %w", err)
 	}
+
+	if c.PointsExpiryMonths > 0 {
+		querySQL = `SELECT date_trunc('day', earned_at + make_interval(months => $2)) AS expires_at,
+			SUM(remaining) AS amount
+			FROM accrual_lots WHERE userid=$1 AND remaining > 0
+			GROUP BY 1 ORDER BY 1 ASC LIMIT $3`
+
+		rows, err := tx.Query(ctx, querySQL, userid, c.PointsExpiryMonths, upcomingExpirations)
+		if err == nil {
+			balance.Expiring, err = pgx.CollectRows(rows, pgx.RowToStructByName[models.PointsExpiration])
+		}
+		if err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				return balance, fmt.Errorf(errRollback, err)
+			}
+			return balance, fmt.Errorf("failed to query accrual lots in DB: %w", err)
+		}
+	}
 	if err := tx.Commit(ctx); err != nil {
 		return balance, fmt.Errorf("failed to commit transaction for user %w", err)
 	}
@@ -211,10 +384,10 @@ func (p *PostgresDB) BalanceGet(c *models.Config, userid string) (models.Balance
 	return balance, nil
 }
 
-func (p *PostgresDB) GetUnprocessedOrders(c *models.Config) (models.Orders, error) {
+func (p *PostgresDB) GetUnprocessedOrders(ctx context.Context, c *models.Config) (models.Orders, error) {
 	db := p.pool
 
-	ctx, cancel := context.WithTimeout(context.Background(), c.ContextTimeout)
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
 	defer cancel()
 
 	querySQL := "UPDATE orders SET status='PROCESSING' WHERE (status='NEW' OR status='PROCESSING') RETURNING *"
@@ -232,41 +405,203 @@ func (p *PostgresDB) GetUnprocessedOrders(c *models.Config) (models.Orders, erro
 	return orders, nil
 }
 
-func (p *PostgresDB) UpdateOrder(c *models.Config, order *models.Order) error {
+// UpdateOrder stores the accrual service result for the order and reports
+// whether it changed. PROCESSED and INVALID are final, and the user is
+// credited in the same transaction when the order turns PROCESSED, so an
+// order fetched by several workers is credited once.
+func (p *PostgresDB) UpdateOrder(ctx context.Context, c *models.Config, order *models.Order) (bool, error) {
 	db := p.pool
 
-	ctx, cancel := context.WithTimeout(context.Background(), c.ContextTimeout)
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
 	defer cancel()
 
-	querySQL := "UPDATE orders SET status=$1, accrual=$2 WHERE number=$3"
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to start transaction: %w", err)
+	}
 
-	_, err := db.Exec(ctx, querySQL, order.Status, order.Accrual, order.Number)
+	var (
+		status  string
+		accrual float32
+	)
+	querySQL := "SELECT userid, status, accrual FROM orders WHERE number=$1 FOR UPDATE"
+	err = tx.QueryRow(ctx, querySQL, order.Number).Scan(&order.UserID, &status, &accrual)
 	if err != nil {
-		return fmt.Errorf("failed to update order %s in Postgres DB: %w", order.Number, err)
+		if err := tx.Rollback(ctx); err != nil {
+			return false, fmt.Errorf(errRollback, err)
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to query order %s in Postgres DB: %w", order.Number, err)
+	}
+	if status == "PROCESSED" || status == "INVALID" || (status == order.Status && accrual == order.Accrual) {
+		if err := tx.Rollback(ctx); err != nil {
+			return false, fmt.Errorf(errRollback, err)
+		}
+		return false, nil
 	}
 
-	return nil
+	querySQL = "UPDATE orders SET status=$1, accrual=$2 WHERE number=$3"
+	if _, err := tx.Exec(ctx, querySQL, order.Status, order.Accrual, order.Number); err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return false, fmt.Errorf(errRollback, err)
+		}
+		return false, fmt.Errorf("failed to update order %s in Postgres DB: %w", order.Number, err)
+	}
+
+	if err := insertOrderEvent(ctx, tx, order.UserID, order, models.OrderEventStatus); err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return false, fmt.Errorf(errRollback, err)
+		}
+		return false, err
+	}
+
+	// accruals of orders still in processing are provisional and only shown as pending
+	if order.Status == "PROCESSED" && order.Accrual != 0 {
+		if err := creditAccrual(ctx, tx, c, order); err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				return false, fmt.Errorf(errRollback, err)
+			}
+			return false, err
+		}
+	}
+
+	var event string
+	switch order.Status {
+	case "PROCESSED":
+		event = models.WebhookOrderProcessed
+	case "INVALID":
+		event = models.WebhookOrderInvalid
+	}
+	if event != "" {
+		payload := models.WebhookEvent{
+			Occurred: time.Now(),
+			Event:    event,
+			Order:    order.Number,
+			Status:   order.Status,
+			Accrual:  order.Accrual,
+		}
+		if err := insertWebhookOutbox(ctx, tx, order.UserID, payload); err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				return false, fmt.Errorf(errRollback, err)
+			}
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit order %s update transaction: %w", order.Number, err)
+	}
+	return true, nil
 }
 
-func (p *PostgresDB) UserAddAccrual(c *models.Config, order *models.Order) error {
-	db := p.pool
+// creditAccrual credits the order accrual, multiplied by the user's tier,
+// and applies the referral reward and campaign bonuses it triggers.
+func creditAccrual(ctx context.Context, tx pgx.Tx, c *models.Config, order *models.Order) error {
+	var (
+		tier  string
+		first bool
+	)
+	querySQL := `SELECT tier, NOT EXISTS (SELECT 1 FROM ledger WHERE userid=$1 AND type='accrual')
+		FROM users WHERE userid=$1 FOR UPDATE`
+	if err := tx.QueryRow(ctx, querySQL, order.UserID).Scan(&tier, &first); err != nil {
+		return fmt.Errorf("failed to query user %s tier in Postgres DB: %w", order.UserID, err)
+	}
+	credited := order.Accrual * helpers.TierMultiplier(c.Tiers, tier)
 
-	ctx, cancel := context.WithTimeout(context.Background(), c.ContextTimeout)
-	defer cancel()
+	querySQL = "UPDATE users SET accrual = accrual + $1 WHERE userid=$2"
+	if _, err := tx.Exec(ctx, querySQL, credited, order.UserID); err != nil {
+		return fmt.Errorf("failed to add accrual for user %s in Postgres DB: %w", order.UserID, err)
+	}
+
+	if err := insertOrderEvent(ctx, tx, order.UserID, order, models.OrderEventAccrual); err != nil {
+		return err
+	}
+
+	entry := models.LedgerEntry{
+		UserID: order.UserID,
+		Type:   models.LedgerAccrual,
+		Number: order.Number,
+		Reason: tier,
+		Amount: credited,
+	}
+	if err := insertLedger(ctx, tx, entry); err != nil {
+		return err
+	}
+	if err := insertLot(ctx, tx, order.UserID, order.Number, credited); err != nil {
+		return err
+	}
+	if first {
+		if err := applyReferral(ctx, tx, c, order); err != nil {
+			return err
+		}
+	}
+	if err := applyCampaigns(ctx, tx, order, first); err != nil {
+		return err
+	}
+	if _, err := updateTiers(ctx, tx, c, order.UserID); err != nil {
+		return err
+	}
+	return nil
+}
 
-	querySQL := "UPDATE users SET accrual = accrual + $1 WHERE userid=$2"
+// insertOrderEvent numbers the event from the order_event_seq counter, whose
+// row is locked until the transaction ends, so that streamed events resumed
+// from a sequence number are not skipped by a transaction committing later.
+func insertOrderEvent(ctx context.Context, tx pgx.Tx, userid string, order *models.Order, eventType string) error {
+	querySQL := `WITH s AS (UPDATE order_event_seq SET last = last + 1 RETURNING last)
+		INSERT INTO order_events (seq, userid, number, type, status, accrual, created_at)
+		SELECT s.last, $1, $2, $3, $4, $5, now() FROM s`
 
-	_, err := db.Exec(ctx, querySQL, order.Accrual, order.UserID)
+	_, err := tx.Exec(ctx, querySQL, userid, order.Number, eventType, order.Status, order.Accrual)
 	if err != nil {
-		return fmt.Errorf("failed to add accrual for user %s in Postgres DB: %w", order.UserID, err)
+		return fmt.Errorf("failed to insert %s event for order %s: %w", eventType, order.Number, err)
 	}
 	return nil
 }
 
-func (p *PostgresDB) AccrualWithdraw(c *models.Config, w models.Withdrawal) error {
+// outflowStats locks the user row and returns the user's withdrawal stats,
+// balance and points held by withdrawals under review. Points sent by
+// transfers count toward the daily and monthly limits as withdrawals do.
+func outflowStats(ctx context.Context, tx pgx.Tx, userid string) (models.WithdrawalStats, float32, float32, error) {
+	var (
+		st      models.WithdrawalStats
+		changed *time.Time
+		accrual float32
+		held    float32
+	)
+	querySQL := `SELECT now()::timestamp, GREATEST(password_changed_at, new_ip_at),
+		(SELECT COALESCE(SUM(sum), 0) FROM withdrawals
+			WHERE userid=$1 AND processed_at >= date_trunc('day', now()))
+		+ (SELECT COALESCE(SUM(amount), 0) FROM transfers
+			WHERE sender=$1 AND created_at >= date_trunc('day', now())),
+		(SELECT COALESCE(SUM(sum), 0) FROM withdrawals
+			WHERE userid=$1 AND processed_at >= date_trunc('month', now()))
+		+ (SELECT COALESCE(SUM(amount), 0) FROM transfers
+			WHERE sender=$1 AND created_at >= date_trunc('month', now())),
+		accrual, (SELECT COALESCE(SUM(amount), 0) FROM risk_decisions
+			WHERE userid=$1 AND kind='withdrawal' AND status='open')
+		FROM users WHERE userid=$1 FOR UPDATE`
+
+	err := tx.QueryRow(ctx, querySQL, userid).Scan(&st.Now, &changed, &st.Daily, &st.Monthly, &accrual, &held)
+	if err != nil {
+		return st, 0, 0, fmt.Errorf("failed to query withdrawal stats for user %s in Postgres DB: %w", userid, err)
+	}
+	if changed != nil {
+		st.SecurityChanged = *changed
+	}
+	return st, accrual, held, nil
+}
+
+// AccrualWithdraw registers a withdrawal if check, called with the user row
+// locked, accepts the user's withdrawal stats. Points held by withdrawals
+// under review cannot be withdrawn.
+func (p *PostgresDB) AccrualWithdraw(ctx context.Context, c *models.Config, w models.Withdrawal,
+	check func(models.WithdrawalStats) error) error {
 	db := p.pool
 
-	ctx, cancel := context.WithTimeout(context.Background(), c.ContextTimeout)
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
 	defer cancel()
 
 	tx, err := db.Begin(ctx)
@@ -274,25 +609,32 @@ func (p *PostgresDB) AccrualWithdraw(c *models.Config, w models.Withdrawal) erro
 		return fmt.Errorf("failed to start transaction: %w", err)
 	}
 
-	querySQL := "UPDATE users SET accrual = accrual - $1 WHERE userid=$2"
-
-	_, err = tx.Exec(ctx, querySQL, w.Sum, w.UserID)
+	st, accrual, held, err := outflowStats(ctx, tx, w.UserID)
 	if err != nil {
 		if err := tx.Rollback(ctx); err != nil {
 			return fmt.Errorf(errRollback, err)
 		}
-		return fmt.Errorf("failed to withdraw accrual for user %s in Postgres DB: %w", w.UserID, err)
+		return err
+	}
+	if err := check(st); err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return fmt.Errorf(errRollback, err)
+		}
+		return err
 	}
-	t := time.Now().Format(time.RFC3339)
 
-	querySQL = "INSERT INTO withdrawals (userid, number, sum, processed_at) VALUES($1, $2, $3, $4)"
+	if accrual-held < w.Sum {
+		if err := tx.Rollback(ctx); err != nil {
+			return fmt.Errorf(errRollback, err)
+		}
+		return fmt.Errorf("balance of user %s: %w", w.UserID, models.ErrInsufficientFunds)
+	}
 
-	_, err = tx.Exec(ctx, querySQL, w.UserID, w.Number, w.Sum, t)
-	if err != nil {
+	if err := insertWithdrawal(ctx, tx, w); err != nil {
 		if err := tx.Rollback(ctx); err != nil {
 			return fmt.Errorf(errRollback, err)
 		}
-		return fmt.Errorf("failed to withdraw accrual for user %s in Postgres DB: %w", w.UserID, err)
+		return err
 	}
 	if err := tx.Commit(ctx); err != nil {
 		return fmt.Errorf("failed to commit accrual withdrawal transaction for user %s", w.UserID)
@@ -300,13 +642,59 @@ func (p *PostgresDB) AccrualWithdraw(c *models.Config, w models.Withdrawal) erro
 	return nil
 }
 
-func (p *PostgresDB) WithdrawalsGet(c *models.Config, uid string) (models.Withdrawals, error) {
+// insertWithdrawal debits the user and records the withdrawal with its
+// ledger entry and webhook event.
+func insertWithdrawal(ctx context.Context, tx pgx.Tx, w models.Withdrawal) error {
+	querySQL := "UPDATE users SET accrual = accrual - $1 WHERE userid=$2"
+
+	if _, err := tx.Exec(ctx, querySQL, w.Sum, w.UserID); err != nil {
+		return fmt.Errorf("failed to withdraw accrual for user %s in Postgres DB: %w", w.UserID, err)
+	}
+	now := time.Now()
+	t := now.Format(time.RFC3339)
+
+	querySQL = "INSERT INTO withdrawals (userid, number, sum, processed_at) VALUES($1, $2, $3, $4)"
+
+	if _, err := tx.Exec(ctx, querySQL, w.UserID, w.Number, w.Sum, t); err != nil {
+		return fmt.Errorf("failed to withdraw accrual for user %s in Postgres DB: %w", w.UserID, err)
+	}
+
+	entry := models.LedgerEntry{
+		UserID: w.UserID,
+		Type:   models.LedgerWithdrawal,
+		Number: w.Number,
+		Amount: -w.Sum,
+	}
+	if err := insertLedger(ctx, tx, entry); err != nil {
+		return err
+	}
+	debits, err := consumeLots(ctx, tx, w.UserID, w.Sum)
+	if err != nil {
+		return err
+	}
+	if err := insertLotDebits(ctx, tx, w.Number, debits); err != nil {
+		return err
+	}
+
+	payload := models.WebhookEvent{
+		Occurred: now,
+		Event:    models.WebhookWithdrawalCreate,
+		Order:    w.Number,
+		Sum:      w.Sum,
+	}
+	return insertWebhookOutbox(ctx, tx, w.UserID, payload)
+}
+
+func (p *PostgresDB) WithdrawalsGet(ctx context.Context, c *models.Config, uid string) (models.Withdrawals, error) {
 	db := p.pool
 	var w models.Withdrawals
-	ctx, cancel := context.WithTimeout(context.Background(), c.ContextTimeout)
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
 	defer cancel()
 
-	query := "SELECT * FROM withdrawals WHERE userid=$1 ORDER BY processed_at ASC"
+	query := `SELECT w.*,
+		COALESCE((SELECT SUM(r.sum) FROM withdrawal_reversals r
+			WHERE r.number = w.number AND r.status = 'applied'), 0) AS reversed
+		FROM withdrawals w WHERE userid=$1 ORDER BY processed_at ASC`
 
 	rows, err := db.Query(ctx, query, uid)
 	if err != nil {
@@ -321,6 +709,2063 @@ func (p *PostgresDB) WithdrawalsGet(c *models.Config, uid string) (models.Withdr
 	return w, nil
 }
 
+func (p *PostgresDB) IdempotencyReserve(ctx context.Context, c *models.Config, rec models.IdempotencyRecord) (
+	models.IdempotencyRecord, bool, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return rec, false, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	// requests still in progress after the lease were abandoned by a crash
+	querySQL := `DELETE FROM idempotency_keys
+		WHERE userid=$1 AND key=$2 AND (created_at < now() - make_interval(secs => $3)
+			OR (status = 0 AND created_at < now() - make_interval(secs => $4)))`
+
+	_, err = tx.Exec(ctx, querySQL, rec.UserID, rec.Key, c.IdempotencyTTL.Seconds(), c.IdempotencyLease.Seconds())
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return rec, false, fmt.Errorf(errRollback, err)
+		}
+		return rec, false, fmt.Errorf("failed to delete expired idempotency key %s: %w", rec.Key, err)
+	}
+
+	querySQL = `INSERT INTO idempotency_keys (userid, key, request_hash, created_at)
+		VALUES($1, $2, $3, now()) ON CONFLICT (userid, key) DO NOTHING`
+
+	tag, err := tx.Exec(ctx, querySQL, rec.UserID, rec.Key, rec.RequestHash)
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return rec, false, fmt.Errorf(errRollback, err)
+		}
+		return rec, false, fmt.Errorf("failed to insert idempotency key %s: %w", rec.Key, err)
+	}
+	created := tag.RowsAffected() == 1
+
+	if !created {
+		querySQL = `SELECT request_hash, status, content_type, body, created_at
+			FROM idempotency_keys WHERE userid=$1 AND key=$2`
+
+		row := tx.QueryRow(ctx, querySQL, rec.UserID, rec.Key)
+		if err := row.Scan(&rec.RequestHash, &rec.Status, &rec.ContentType, &rec.Body, &rec.Created); err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				return rec, false, fmt.Errorf(errRollback, err)
+			}
+			return rec, false, fmt.Errorf("failed to query idempotency key %s: %w", rec.Key, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return rec, false, fmt.Errorf("failed to commit idempotency key transaction: %w", err)
+	}
+	return rec, created, nil
+}
+
+func (p *PostgresDB) IdempotencySave(ctx context.Context, c *models.Config, rec models.IdempotencyRecord) error {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	querySQL := "UPDATE idempotency_keys SET status=$1, content_type=$2, body=$3 WHERE userid=$4 AND key=$5"
+
+	_, err := db.Exec(ctx, querySQL, rec.Status, rec.ContentType, rec.Body, rec.UserID, rec.Key)
+	if err != nil {
+		return fmt.Errorf("failed to save response for idempotency key %s: %w", rec.Key, err)
+	}
+	return nil
+}
+
+func (p *PostgresDB) IdempotencyRelease(ctx context.Context, c *models.Config, userid string, key string) error {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	_, err := db.Exec(ctx, "DELETE FROM idempotency_keys WHERE userid=$1 AND key=$2", userid, key)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key %s: %w", key, err)
+	}
+	return nil
+}
+
+func (p *PostgresDB) IdempotencyPurge(ctx context.Context, c *models.Config) (int64, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	querySQL := "DELETE FROM idempotency_keys WHERE created_at < now() - make_interval(secs => $1)"
+
+	tag, err := db.Exec(ctx, querySQL, c.IdempotencyTTL.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge expired idempotency keys: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// OrderEventsGet returns the user's events numbered after afterSeq.
+func (p *PostgresDB) OrderEventsGet(ctx context.Context, c *models.Config, userid string, afterSeq int64) (
+	models.OrderEvents, error) {
+	db := p.pool
+
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	querySQL := "SELECT * FROM order_events WHERE userid=$1 AND seq>$2 ORDER BY seq ASC"
+
+	rows, err := db.Query(ctx, querySQL, userid, afterSeq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DB: %w", err)
+	}
+	defer rows.Close()
+
+	events, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.OrderEvent])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan order events: %w", err)
+	}
+	return events, nil
+}
+
+// OrderEventsListen subscribes to order_events notifications and calls fn for
+// every new event until ctx is cancelled.
+func (p *PostgresDB) OrderEventsListen(ctx context.Context, c *models.Config, fn func(models.OrderEvent)) error {
+	conn, err := p.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "LISTEN order_events"); err != nil {
+		return fmt.Errorf("failed to listen to order events: %w", err)
+	}
+
+	for {
+		n, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to wait for order event notification: %w", err)
+		}
+
+		id, err := strconv.ParseInt(n.Payload, 10, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse order event id %s: %w", n.Payload, err)
+		}
+
+		qctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+		rows, err := conn.Query(qctx, "SELECT * FROM order_events WHERE id=$1", id)
+		if err != nil {
+			cancel()
+			return fmt.Errorf("failed to query order event %s: %w", n.Payload, err)
+		}
+		event, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.OrderEvent])
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to scan order event %s: %w", n.Payload, err)
+		}
+		fn(event)
+	}
+}
+
+// insertWebhookOutbox queues the event for every webhook of the user within
+// the caller's transaction.
+func insertWebhookOutbox(ctx context.Context, tx pgx.Tx, userid string, e models.WebhookEvent) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s webhook payload: %w", e.Event, err)
+	}
+
+	querySQL := `INSERT INTO webhook_outbox (webhook_id, event, payload, next_attempt_at, created_at)
+		SELECT id, $2, $3, now(), now() FROM webhooks WHERE userid=$1`
+
+	if _, err := tx.Exec(ctx, querySQL, userid, e.Event, payload); err != nil {
+		return fmt.Errorf("failed to queue %s webhook for user %s: %w", e.Event, userid, err)
+	}
+	return nil
+}
+
+func (p *PostgresDB) WebhookAdd(ctx context.Context, c *models.Config, wh models.Webhook) (models.Webhook, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	querySQL := `INSERT INTO webhooks (userid, url, secret, created_at)
+		VALUES($1, $2, $3, now()) RETURNING id, created_at`
+
+	if err := db.QueryRow(ctx, querySQL, wh.UserID, wh.URL, wh.Secret).Scan(&wh.ID, &wh.Created); err != nil {
+		return wh, fmt.Errorf("failed to insert webhook for user %s into Postgres DB: %w", wh.UserID, err)
+	}
+	return wh, nil
+}
+
+func (p *PostgresDB) WebhooksGet(ctx context.Context, c *models.Config, userid string) (models.Webhooks, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	querySQL := `SELECT id, userid, url, '' AS secret, created_at
+		FROM webhooks WHERE userid=$1 ORDER BY id ASC`
+
+	rows, err := db.Query(ctx, querySQL, userid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DB: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Webhook])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan webhooks: %w", err)
+	}
+	return webhooks, nil
+}
+
+func (p *PostgresDB) WebhookDelete(ctx context.Context, c *models.Config, userid string, id int64) (bool, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	tag, err := db.Exec(ctx, "DELETE FROM webhooks WHERE id=$1 AND userid=$2", id, userid)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete webhook %d: %w", id, err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (p *PostgresDB) WebhookDeliveriesGet(ctx context.Context, c *models.Config, userid string, id int64) (
+	models.WebhookDeliveries, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	querySQL := `SELECT d.outbox_id, o.event, d.attempt, d.status_code, d.error, d.duration_ms, d.created_at
+		FROM webhook_deliveries d
+		JOIN webhook_outbox o ON o.id = d.outbox_id
+		JOIN webhooks w ON w.id = o.webhook_id
+		WHERE w.id=$1 AND w.userid=$2
+		ORDER BY d.id DESC LIMIT 1000`
+
+	rows, err := db.Query(ctx, querySQL, id, userid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DB: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.WebhookDelivery])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// WebhookOutboxClaim leases the oldest due outbox entry for delivery. A
+// claimed entry is not handed out again until lease passes, which lets
+// several instances run the delivery worker concurrently. It returns false
+// if no entry is due.
+func (p *PostgresDB) WebhookOutboxClaim(ctx context.Context, c *models.Config, lease time.Duration) (
+	models.WebhookMessage, bool, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	querySQL := `UPDATE webhook_outbox o
+		SET attempts = o.attempts + 1, next_attempt_at = now() + make_interval(secs => $1)
+		FROM webhooks w
+		WHERE w.id = o.webhook_id AND o.id = (
+			SELECT id FROM webhook_outbox
+			WHERE delivered_at IS NULL AND failed_at IS NULL AND next_attempt_at <= now()
+			ORDER BY id LIMIT 1 FOR UPDATE SKIP LOCKED)
+		RETURNING o.id, o.event, o.payload, o.attempts, w.url, w.secret`
+
+	var m models.WebhookMessage
+	row := db.QueryRow(ctx, querySQL, lease.Seconds())
+	if err := row.Scan(&m.ID, &m.Event, &m.Payload, &m.Attempts, &m.URL, &m.Secret); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return m, false, nil
+		}
+		return m, false, fmt.Errorf("failed to claim webhook outbox: %w", err)
+	}
+	return m, true, nil
+}
+
+// WebhookDeliveryAdd logs a delivery attempt and moves the outbox entry to
+// delivered, failed, or schedules the next attempt after retryIn.
+func (p *PostgresDB) WebhookDeliveryAdd(ctx context.Context, c *models.Config, d models.WebhookDelivery, delivered bool,
+	retryIn time.Duration) error {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	querySQL := `INSERT INTO webhook_deliveries (outbox_id, attempt, status_code, error, duration_ms, created_at)
+		VALUES($1, $2, $3, $4, $5, now())`
+
+	_, err = tx.Exec(ctx, querySQL, d.OutboxID, d.Attempt, d.StatusCode, d.Error, d.Duration)
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return fmt.Errorf(errRollback, err)
+		}
+		return fmt.Errorf("failed to log webhook delivery %d: %w", d.OutboxID, err)
+	}
+
+	switch {
+	case delivered:
+		querySQL = "UPDATE webhook_outbox SET delivered_at = now() WHERE id=$1"
+		_, err = tx.Exec(ctx, querySQL, d.OutboxID)
+	case retryIn <= 0:
+		querySQL = "UPDATE webhook_outbox SET failed_at = now() WHERE id=$1"
+		_, err = tx.Exec(ctx, querySQL, d.OutboxID)
+	default:
+		querySQL = "UPDATE webhook_outbox SET next_attempt_at = now() + make_interval(secs => $2) WHERE id=$1"
+		_, err = tx.Exec(ctx, querySQL, d.OutboxID, retryIn.Seconds())
+	}
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return fmt.Errorf(errRollback, err)
+		}
+		return fmt.Errorf("failed to update webhook outbox %d: %w", d.OutboxID, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit webhook delivery %d: %w", d.OutboxID, err)
+	}
+	return nil
+}
+
+// UsersSyncAdmins grants the admin role to the existing users with the given
+// logins and revokes it from all other users. It returns the number of
+// promoted and demoted users.
+func (p *PostgresDB) UsersSyncAdmins(ctx context.Context, c *models.Config, userids []string) (int64, int64, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	querySQL := `UPDATE users SET role = CASE WHEN userid = ANY($1::varchar[]) THEN $2 ELSE $3 END
+		WHERE role <> CASE WHEN userid = ANY($1::varchar[]) THEN $2 ELSE $3 END
+		RETURNING role`
+
+	rows, err := db.Query(ctx, querySQL, userids, models.RoleAdmin, models.RoleUser)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to sync admin users: %w", err)
+	}
+
+	var (
+		promoted, demoted int64
+		role              string
+	)
+	_, err = pgx.ForEachRow(rows, []any{&role}, func() error {
+		if role == models.RoleAdmin {
+			promoted++
+		} else {
+			demoted++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to sync admin users: %w", err)
+	}
+	return promoted, demoted, nil
+}
+
+// UsersSearch returns users whose login contains query, ordered by login.
+func (p *PostgresDB) UsersSearch(ctx context.Context, c *models.Config, query string, limit int64, offset int64) (
+	models.UserSummaries, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query) + "%"
+	querySQL := `SELECT userid, role, accrual, locked FROM users
+		WHERE userid LIKE $1 ORDER BY userid ASC LIMIT $2 OFFSET $3`
+
+	rows, err := db.Query(ctx, querySQL, pattern, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DB: %w", err)
+	}
+	defer rows.Close()
+
+	users, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.UserSummary])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan users: %w", err)
+	}
+	return users, nil
+}
+
+// UserLockSet locks or unlocks the user and records the admin action.
+// It reports false if the user does not exist.
+func (p *PostgresDB) UserLockSet(ctx context.Context, c *models.Config, admin string, userid string, locked bool) (
+	bool, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	tag, err := tx.Exec(ctx, "UPDATE users SET locked=$1 WHERE userid=$2", locked, userid)
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return false, fmt.Errorf(errRollback, err)
+		}
+		return false, fmt.Errorf("failed to update user %s lock in Postgres DB: %w", userid, err)
+	}
+	if tag.RowsAffected() == 0 {
+		if err := tx.Rollback(ctx); err != nil {
+			return false, fmt.Errorf(errRollback, err)
+		}
+		return false, nil
+	}
+
+	action := models.AuditUserUnlock
+	if locked {
+		action = models.AuditUserLock
+	}
+	if err := insertAudit(ctx, tx, admin, action, userid); err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return false, fmt.Errorf(errRollback, err)
+		}
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit user %s lock transaction: %w", userid, err)
+	}
+	return true, nil
+}
+
+// OrderRecheck returns the order to the NEW status so that the dispatcher
+// queries the accrual system again. PROCESSED orders have already been
+// credited and are returned unchanged, an unknown order is returned empty.
+func (p *PostgresDB) OrderRecheck(ctx context.Context, c *models.Config, admin string, oid string) (
+	models.Order, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return models.Order{}, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	querySQL := "UPDATE orders SET status='NEW', accrual=0 WHERE number=$1 AND status<>'PROCESSED' RETURNING *"
+
+	rows, err := tx.Query(ctx, querySQL, oid)
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return models.Order{}, fmt.Errorf(errRollback, err)
+		}
+		return models.Order{}, fmt.Errorf("failed to reset order %s in Postgres DB: %w", oid, err)
+	}
+	order, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.Order])
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return models.Order{}, fmt.Errorf(errRollback, err)
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			return p.OrderGet(ctx, c, oid)
+		}
+		return models.Order{}, fmt.Errorf("failed to scan order %s: %w", oid, err)
+	}
+
+	if err := insertOrderEvent(ctx, tx, order.UserID, &order, models.OrderEventStatus); err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return models.Order{}, fmt.Errorf(errRollback, err)
+		}
+		return models.Order{}, err
+	}
+	if err := insertAudit(ctx, tx, admin, models.AuditOrderRecheck, oid); err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return models.Order{}, fmt.Errorf(errRollback, err)
+		}
+		return models.Order{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.Order{}, fmt.Errorf("failed to commit order %s recheck transaction: %w", oid, err)
+	}
+	return order, nil
+}
+
+func insertAudit(ctx context.Context, tx pgx.Tx, admin string, action string, target string) error {
+	querySQL := "INSERT INTO admin_audit (admin, action, target, created_at) VALUES($1, $2, $3, now())"
+
+	if _, err := tx.Exec(ctx, querySQL, admin, action, target); err != nil {
+		return fmt.Errorf("failed to audit %s by admin %s: %w", action, admin, err)
+	}
+	return nil
+}
+
+func (p *PostgresDB) AuditAdd(ctx context.Context, c *models.Config, admin string, action string, target string) error {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	querySQL := "INSERT INTO admin_audit (admin, action, target, created_at) VALUES($1, $2, $3, now())"
+
+	if _, err := db.Exec(ctx, querySQL, admin, action, target); err != nil {
+		return fmt.Errorf("failed to audit %s by admin %s: %w", action, admin, err)
+	}
+	return nil
+}
+
+func (p *PostgresDB) AuditGet(ctx context.Context, c *models.Config, limit int64, offset int64) (
+	models.AuditEntries, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	querySQL := "SELECT * FROM admin_audit ORDER BY id DESC LIMIT $1 OFFSET $2"
+
+	rows, err := db.Query(ctx, querySQL, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DB: %w", err)
+	}
+	defer rows.Close()
+
+	entries, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.AuditEntry])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan admin audit: %w", err)
+	}
+	return entries, nil
+}
+
+func insertLedger(ctx context.Context, tx pgx.Tx, e models.LedgerEntry) error {
+	querySQL := `INSERT INTO ledger (userid, type, amount, number, reason, ref_id, created_at)
+		VALUES($1, $2, $3, $4, $5, $6, now())`
+
+	_, err := tx.Exec(ctx, querySQL, e.UserID, e.Type, e.Amount, e.Number, e.Reason, e.RefID)
+	if err != nil {
+		return fmt.Errorf("failed to insert %s ledger entry for user %s: %w", e.Type, e.UserID, err)
+	}
+	return nil
+}
+
+func (p *PostgresDB) LedgerGet(ctx context.Context, c *models.Config, userid string) (models.LedgerEntries, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	querySQL := "SELECT * FROM ledger WHERE userid=$1 ORDER BY id ASC"
+
+	rows, err := db.Query(ctx, querySQL, userid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DB: %w", err)
+	}
+	defer rows.Close()
+
+	entries, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.LedgerEntry])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan ledger: %w", err)
+	}
+	return entries, nil
+}
+
+// applyAdjustment changes the user balance by the adjustment amount. A debit
+// larger than the current balance fails with ErrInsufficientFunds. Points
+// held by withdrawals under review can be debited, approving such a hold
+// then fails the balance check.
+func applyAdjustment(ctx context.Context, tx pgx.Tx, a *models.Adjustment) error {
+	amount := a.Amount
+	if a.Type == models.AdjustmentDebit {
+		amount = -amount
+	}
+
+	querySQL := "UPDATE users SET accrual = accrual + $1 WHERE userid=$2 AND accrual + $1 >= 0"
+
+	tag, err := tx.Exec(ctx, querySQL, amount, a.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to apply adjustment %d for user %s: %w", a.ID, a.UserID, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("failed to apply adjustment %d for user %s: %w", a.ID, a.UserID, models.ErrInsufficientFunds)
+	}
+
+	entry := models.LedgerEntry{
+		UserID: a.UserID,
+		Type:   models.LedgerAdjustment,
+		Reason: a.Reason,
+		Amount: amount,
+		RefID:  a.ID,
+	}
+	if err := insertLedger(ctx, tx, entry); err != nil {
+		return err
+	}
+	if a.Type == models.AdjustmentDebit {
+		_, err := consumeLots(ctx, tx, a.UserID, a.Amount)
+		return err
+	}
+	return insertLot(ctx, tx, a.UserID, "", a.Amount)
+}
+
+// AdjustmentAdd records a manual adjustment and applies it to the balance
+// right away unless its status is pending.
+func (p *PostgresDB) AdjustmentAdd(ctx context.Context, c *models.Config, a models.Adjustment) (
+	models.Adjustment, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return a, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	var userid string
+	err = tx.QueryRow(ctx, "SELECT userid FROM users WHERE userid=$1 FOR UPDATE", a.UserID).Scan(&userid)
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return a, fmt.Errorf(errRollback, err)
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			return a, fmt.Errorf("user %s: %w", a.UserID, models.ErrNotFound)
+		}
+		return a, fmt.Errorf("failed to query user table in DB: %w", err)
+	}
+
+	querySQL := `INSERT INTO adjustments (userid, type, amount, reason, note, status, created_by, decided_by,
+		created_at, decided_at)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, now(), CASE WHEN $6 = 'pending' THEN NULL ELSE now() END)
+		RETURNING id, created_at, decided_at`
+
+	err = tx.QueryRow(ctx, querySQL, a.UserID, a.Type, a.Amount, a.Reason, a.Note, a.Status, a.CreatedBy,
+		a.DecidedBy).Scan(&a.ID, &a.Created, &a.Decided)
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return a, fmt.Errorf(errRollback, err)
+		}
+		return a, fmt.Errorf("failed to insert adjustment for user %s into Postgres DB: %w", a.UserID, err)
+	}
+
+	if a.Status == models.AdjustmentApplied {
+		if err := applyAdjustment(ctx, tx, &a); err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				return a, fmt.Errorf(errRollback, err)
+			}
+			return a, err
+		}
+	}
+
+	if err := insertAudit(ctx, tx, a.CreatedBy, models.AuditAdjustmentAdd, strconv.FormatInt(a.ID, 10)); err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return a, fmt.Errorf(errRollback, err)
+		}
+		return a, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return a, fmt.Errorf("failed to commit adjustment transaction for user %s: %w", a.UserID, err)
+	}
+	return a, nil
+}
+
+// AdjustmentDecide approves or rejects a pending adjustment. An adjustment
+// can only be approved by an admin other than the one who created it.
+func (p *PostgresDB) AdjustmentDecide(ctx context.Context, c *models.Config, admin string, id int64, approve bool) (
+	models.Adjustment, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return models.Adjustment{}, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, "SELECT * FROM adjustments WHERE id=$1 FOR UPDATE", id)
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return models.Adjustment{}, fmt.Errorf(errRollback, err)
+		}
+		return models.Adjustment{}, fmt.Errorf("failed to query adjustment %d: %w", id, err)
+	}
+	a, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.Adjustment])
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return models.Adjustment{}, fmt.Errorf(errRollback, err)
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Adjustment{}, fmt.Errorf("adjustment %d: %w", id, models.ErrNotFound)
+		}
+		return models.Adjustment{}, fmt.Errorf("failed to scan adjustment %d: %w", id, err)
+	}
+
+	switch {
+	case a.Status != models.AdjustmentPending:
+		err = fmt.Errorf("adjustment %d: %w", id, models.ErrAlreadyDecided)
+	case approve && a.CreatedBy == admin:
+		err = fmt.Errorf("adjustment %d: %w", id, models.ErrSelfApproval)
+	case approve:
+		err = applyAdjustment(ctx, tx, &a)
+	}
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return a, fmt.Errorf(errRollback, err)
+		}
+		return a, err
+	}
+
+	status, action := models.AdjustmentRejected, models.AuditAdjustmentNOK
+	if approve {
+		status, action = models.AdjustmentApplied, models.AuditAdjustmentOK
+	}
+	querySQL := `UPDATE adjustments SET status=$1, decided_by=$2, decided_at=now()
+		WHERE id=$3 RETURNING status, decided_by, decided_at`
+
+	if err := tx.QueryRow(ctx, querySQL, status, admin, id).Scan(&a.Status, &a.DecidedBy, &a.Decided); err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return a, fmt.Errorf(errRollback, err)
+		}
+		return a, fmt.Errorf("failed to update adjustment %d: %w", id, err)
+	}
+
+	if err := insertAudit(ctx, tx, admin, action, strconv.FormatInt(id, 10)); err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return a, fmt.Errorf(errRollback, err)
+		}
+		return a, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return a, fmt.Errorf("failed to commit adjustment %d transaction: %w", id, err)
+	}
+	return a, nil
+}
+
+func (p *PostgresDB) AdjustmentsGet(ctx context.Context, c *models.Config, status string, limit int64, offset int64) (
+	models.Adjustments, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	querySQL := `SELECT * FROM adjustments WHERE $1 = '' OR status=$1
+		ORDER BY id DESC LIMIT $2 OFFSET $3`
+
+	rows, err := db.Query(ctx, querySQL, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DB: %w", err)
+	}
+	defer rows.Close()
+
+	adjustments, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Adjustment])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan adjustments: %w", err)
+	}
+	return adjustments, nil
+}
+
+// applyReversal returns the reversal sum to the user balance.
+func applyReversal(ctx context.Context, tx pgx.Tx, r *models.WithdrawalReversal) error {
+	if _, err := tx.Exec(ctx, "UPDATE users SET accrual = accrual + $1 WHERE userid=$2", r.Sum, r.UserID); err != nil {
+		return fmt.Errorf("failed to restore accrual for user %s in Postgres DB: %w", r.UserID, err)
+	}
+
+	entry := models.LedgerEntry{
+		UserID: r.UserID,
+		Type:   models.LedgerReversal,
+		Number: r.Number,
+		Amount: r.Sum,
+		RefID:  r.ID,
+	}
+	if err := insertLedger(ctx, tx, entry); err != nil {
+		return err
+	}
+	if err := restoreLots(ctx, tx, r.UserID, r.Number, r.Sum); err != nil {
+		return err
+	}
+
+	payload := models.WebhookEvent{
+		Occurred: time.Now(),
+		Event:    models.WebhookWithdrawalRevert,
+		Order:    r.Number,
+		Sum:      r.Sum,
+	}
+	return insertWebhookOutbox(ctx, tx, r.UserID, payload)
+}
+
+// WithdrawalReverse records a reversal of a withdrawal. The withdrawal row is
+// locked, so concurrent reversals, pending ones included, cannot exceed the
+// withdrawn sum. An empty admin requests a reversal of the user's own
+// withdrawal, it stays pending until an admin decides it. Non-empty admin
+// reverses a withdrawal of any user right away and is recorded in the audit
+// log.
+func (p *PostgresDB) WithdrawalReverse(ctx context.Context, c *models.Config, admin string,
+	r models.WithdrawalReversal) (
+	models.WithdrawalReversal, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return r, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	var (
+		userid    string
+		withdrawn float32
+		reversed  float32
+	)
+	querySQL := "SELECT userid, sum FROM withdrawals WHERE number=$1 FOR UPDATE"
+
+	err = tx.QueryRow(ctx, querySQL, r.Number).Scan(&userid, &withdrawn)
+	if err == nil && admin == "" && userid != r.UserID {
+		err = pgx.ErrNoRows
+	}
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return r, fmt.Errorf(errRollback, err)
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			return r, fmt.Errorf("withdrawal %s: %w", r.Number, models.ErrNotFound)
+		}
+		return r, fmt.Errorf("failed to query withdrawal %s: %w", r.Number, err)
+	}
+	r.UserID = userid
+
+	querySQL = "SELECT COALESCE(SUM(sum), 0) FROM withdrawal_reversals WHERE number=$1 AND status <> $2"
+
+	if err := tx.QueryRow(ctx, querySQL, r.Number, models.ReversalRejected).Scan(&reversed); err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return r, fmt.Errorf(errRollback, err)
+		}
+		return r, fmt.Errorf("failed to query reversals of withdrawal %s: %w", r.Number, err)
+	}
+
+	remaining := withdrawn - reversed
+	if r.Sum == 0 {
+		r.Sum = remaining
+	}
+	if remaining <= 0 || r.Sum > remaining {
+		if err := tx.Rollback(ctx); err != nil {
+			return r, fmt.Errorf(errRollback, err)
+		}
+		return r, fmt.Errorf("withdrawal %s: %w", r.Number, models.ErrReversalExceeded)
+	}
+
+	r.Status, r.DecidedBy = models.ReversalPending, ""
+	if admin != "" {
+		r.Status, r.DecidedBy = models.ReversalApplied, admin
+	}
+	querySQL = `INSERT INTO withdrawal_reversals (userid, number, sum, reason, status, created_by, decided_by,
+		created_at, decided_at)
+		VALUES($1, $2, $3, $4, $5, $6, $7, now(), CASE WHEN $5 = 'pending' THEN NULL ELSE now() END)
+		RETURNING id, created_at, decided_at`
+
+	err = tx.QueryRow(ctx, querySQL, r.UserID, r.Number, r.Sum, r.Reason, r.Status, r.CreatedBy,
+		r.DecidedBy).Scan(&r.ID, &r.Created, &r.Decided)
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return r, fmt.Errorf(errRollback, err)
+		}
+		return r, fmt.Errorf("failed to insert reversal of withdrawal %s: %w", r.Number, err)
+	}
+
+	if admin != "" {
+		if err := applyReversal(ctx, tx, &r); err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				return r, fmt.Errorf(errRollback, err)
+			}
+			return r, err
+		}
+		if err := insertAudit(ctx, tx, admin, models.AuditReversal, r.Number); err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				return r, fmt.Errorf(errRollback, err)
+			}
+			return r, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return r, fmt.Errorf("failed to commit reversal of withdrawal %s: %w", r.Number, err)
+	}
+	return r, nil
+}
+
+// ReversalDecide approves or rejects a pending reversal requested by a user.
+// An approved reversal is applied to the user balance.
+func (p *PostgresDB) ReversalDecide(ctx context.Context, c *models.Config, admin string, id int64, approve bool) (
+	models.WithdrawalReversal, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return models.WithdrawalReversal{}, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, "SELECT * FROM withdrawal_reversals WHERE id=$1 FOR UPDATE", id)
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return models.WithdrawalReversal{}, fmt.Errorf(errRollback, err)
+		}
+		return models.WithdrawalReversal{}, fmt.Errorf("failed to query reversal %d: %w", id, err)
+	}
+	r, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.WithdrawalReversal])
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return models.WithdrawalReversal{}, fmt.Errorf(errRollback, err)
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.WithdrawalReversal{}, fmt.Errorf("reversal %d: %w", id, models.ErrNotFound)
+		}
+		return models.WithdrawalReversal{}, fmt.Errorf("failed to scan reversal %d: %w", id, err)
+	}
+
+	switch {
+	case r.Status != models.ReversalPending:
+		err = fmt.Errorf("reversal %d: %w", id, models.ErrAlreadyDecided)
+	case approve:
+		err = applyReversal(ctx, tx, &r)
+	}
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return r, fmt.Errorf(errRollback, err)
+		}
+		return r, err
+	}
+
+	status, action := models.ReversalRejected, models.AuditReversalNOK
+	if approve {
+		status, action = models.ReversalApplied, models.AuditReversalOK
+	}
+	querySQL := `UPDATE withdrawal_reversals SET status=$1, decided_by=$2, decided_at=now()
+		WHERE id=$3 RETURNING status, decided_by, decided_at`
+
+	if err := tx.QueryRow(ctx, querySQL, status, admin, id).Scan(&r.Status, &r.DecidedBy, &r.Decided); err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return r, fmt.Errorf(errRollback, err)
+		}
+		return r, fmt.Errorf("failed to update reversal %d: %w", id, err)
+	}
+
+	if err := insertAudit(ctx, tx, admin, action, strconv.FormatInt(id, 10)); err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return r, fmt.Errorf(errRollback, err)
+		}
+		return r, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return r, fmt.Errorf("failed to commit reversal %d transaction: %w", id, err)
+	}
+	return r, nil
+}
+
+func (p *PostgresDB) ReversalsGet(ctx context.Context, c *models.Config, status string, limit int64, offset int64) (
+	models.WithdrawalReversals, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	querySQL := `SELECT * FROM withdrawal_reversals WHERE $1 = '' OR status=$1
+		ORDER BY id DESC LIMIT $2 OFFSET $3`
+
+	rows, err := db.Query(ctx, querySQL, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DB: %w", err)
+	}
+	defer rows.Close()
+
+	reversals, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.WithdrawalReversal])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan reversals: %w", err)
+	}
+	return reversals, nil
+}
+
+// lotDebit is the part of a lot spent by a debit.
+type lotDebit struct {
+	Earned time.Time
+	ID     int64
+	Amount float32
+}
+
+// insertLot records credited points as a lot that expires on its own
+// schedule.
+func insertLot(ctx context.Context, tx pgx.Tx, userid string, number string, amount float32) error {
+	querySQL := `INSERT INTO accrual_lots (userid, number, amount, remaining, earned_at)
+		VALUES($1, $2, $3, $3, now())`
+
+	if _, err := tx.Exec(ctx, querySQL, userid, number, amount); err != nil {
+		return fmt.Errorf("failed to insert accrual lot for user %s: %w", userid, err)
+	}
+	return nil
+}
+
+// insertLotAt records credited points as a lot earned at the given time, for
+// points that keep the expiry of the lots they came from.
+func insertLotAt(ctx context.Context, tx pgx.Tx, userid string, number string, amount float32,
+	earned time.Time) error {
+	querySQL := `INSERT INTO accrual_lots (userid, number, amount, remaining, earned_at)
+		VALUES($1, $2, $3, $3, $4)`
+
+	if _, err := tx.Exec(ctx, querySQL, userid, number, amount, earned); err != nil {
+		return fmt.Errorf("failed to insert accrual lot for user %s: %w", userid, err)
+	}
+	return nil
+}
+
+// consumeLots spends amount from the user's lots, oldest first, and returns
+// the spent parts. The balance itself is checked by the caller, lots missing
+// for legacy balances are not an error. The user row must be locked first,
+// as LotsExpire does.
+func consumeLots(ctx context.Context, tx pgx.Tx, userid string, amount float32) ([]lotDebit, error) {
+	querySQL := "SELECT id FROM accrual_lots WHERE userid=$1 AND remaining > 0 FOR UPDATE"
+
+	if _, err := tx.Exec(ctx, querySQL, userid); err != nil {
+		return nil, fmt.Errorf("failed to lock accrual lots for user %s: %w", userid, err)
+	}
+
+	querySQL = `UPDATE accrual_lots a
+		SET remaining = LEAST(a.remaining, GREATEST(0, l.cum - $2))
+		FROM (
+			SELECT id, remaining, SUM(remaining) OVER (ORDER BY earned_at, id) AS cum
+			FROM accrual_lots WHERE userid=$1 AND remaining > 0
+		) l
+		WHERE a.id = l.id AND l.cum - a.remaining < $2
+		RETURNING a.earned_at, a.id, l.remaining - a.remaining`
+
+	rows, err := tx.Query(ctx, querySQL, userid, amount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume accrual lots for user %s: %w", userid, err)
+	}
+	debits, err := pgx.CollectRows(rows, pgx.RowToStructByPos[lotDebit])
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume accrual lots for user %s: %w", userid, err)
+	}
+	return debits, nil
+}
+
+// insertLotDebits records the lots spent by a withdrawal, so that reversals
+// can restore them.
+func insertLotDebits(ctx context.Context, tx pgx.Tx, number string, debits []lotDebit) error {
+	ids := make([]int64, 0, len(debits))
+	amounts := make([]float32, 0, len(debits))
+	for _, d := range debits {
+		ids = append(ids, d.ID)
+		amounts = append(amounts, d.Amount)
+	}
+
+	querySQL := `INSERT INTO accrual_lot_debits (lot_id, number, amount)
+		SELECT d.lot_id, $1, d.amount FROM unnest($2::bigint[], $3::float8[]) AS d(lot_id, amount)
+		WHERE d.amount > 0`
+
+	if _, err := tx.Exec(ctx, querySQL, number, ids, amounts); err != nil {
+		return fmt.Errorf("failed to record accrual lots spent by withdrawal %s: %w", number, err)
+	}
+	return nil
+}
+
+// restoreLots returns amount to the lots spent by the withdrawal, the most
+// recently spent ones first, so that reversed points keep their original
+// expiry. Withdrawals made before spent lots were recorded get a lot earned
+// at the withdrawal time instead.
+func restoreLots(ctx context.Context, tx pgx.Tx, userid string, number string, amount float32) error {
+	querySQL := "SELECT id FROM accrual_lot_debits WHERE number=$1 AND amount > 0 FOR UPDATE"
+
+	if _, err := tx.Exec(ctx, querySQL, number); err != nil {
+		return fmt.Errorf("failed to lock accrual lots spent by withdrawal %s: %w", number, err)
+	}
+
+	querySQL = `WITH restored AS (
+			UPDATE accrual_lot_debits d
+			SET amount = LEAST(d.amount, GREATEST(0, l.cum - $2))
+			FROM (
+				SELECT id, amount, SUM(amount) OVER (ORDER BY id DESC) AS cum
+				FROM accrual_lot_debits WHERE number=$1 AND amount > 0
+			) l
+			WHERE d.id = l.id AND l.cum - d.amount < $2
+			RETURNING d.lot_id, l.amount - d.amount AS amount
+		), lots AS (
+			UPDATE accrual_lots a SET remaining = a.remaining + r.amount
+			FROM restored r WHERE a.id = r.lot_id
+		)
+		SELECT COALESCE(SUM(amount), 0) FROM restored`
+
+	var restored float32
+	if err := tx.QueryRow(ctx, querySQL, number, amount).Scan(&restored); err != nil {
+		return fmt.Errorf("failed to restore accrual lots spent by withdrawal %s: %w", number, err)
+	}
+	if amount-restored <= lotRoundingError {
+		return nil
+	}
+
+	var processed time.Time
+	querySQL = "SELECT processed_at FROM withdrawals WHERE number=$1"
+	if err := tx.QueryRow(ctx, querySQL, number).Scan(&processed); err != nil {
+		return fmt.Errorf("failed to query withdrawal %s: %w", number, err)
+	}
+	return insertLotAt(ctx, tx, userid, number, amount-restored, processed)
+}
+
+// LotsExpire expires up to limit lots earned more than PointsExpiryMonths
+// ago, deducts their remaining points from the balances and writes expiry
+// ledger entries. It returns the number of expired lots. User rows are
+// locked before their lots, in the order withdrawals lock them, and users
+// busy in another transaction are left for the next run.
+func (p *PostgresDB) LotsExpire(ctx context.Context, c *models.Config, limit int64) (int64, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	querySQL := `SELECT userid FROM users WHERE userid IN (
+			SELECT userid FROM accrual_lots
+			WHERE remaining > 0 AND earned_at < now() - make_interval(months => $1)
+			ORDER BY earned_at LIMIT $2)
+		ORDER BY userid FOR UPDATE SKIP LOCKED`
+
+	rows, err := tx.Query(ctx, querySQL, c.PointsExpiryMonths, limit)
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return 0, fmt.Errorf(errRollback, err)
+		}
+		return 0, fmt.Errorf("failed to lock users with expired accrual lots: %w", err)
+	}
+	userids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return 0, fmt.Errorf(errRollback, err)
+		}
+		return 0, fmt.Errorf("failed to scan users with expired accrual lots: %w", err)
+	}
+
+	querySQL = `WITH expired AS (
+			UPDATE accrual_lots SET expired = remaining, remaining = 0, expired_at = now()
+			WHERE id IN (
+				SELECT id FROM accrual_lots
+				WHERE userid = ANY($4) AND remaining > 0 AND earned_at < now() - make_interval(months => $1)
+				ORDER BY earned_at LIMIT $2 FOR UPDATE)
+			RETURNING id, userid, number, expired
+		), entries AS (
+			INSERT INTO ledger (userid, type, amount, number, ref_id, created_at)
+			SELECT userid, $3, -expired, number, id, now() FROM expired
+		), balances AS (
+			UPDATE users u SET accrual = GREATEST(0, u.accrual - e.total)
+			FROM (SELECT userid, SUM(expired) AS total FROM expired GROUP BY userid) e
+			WHERE u.userid = e.userid
+		)
+		SELECT COUNT(*) FROM expired`
+
+	var n int64
+	err = tx.QueryRow(ctx, querySQL, c.PointsExpiryMonths, limit, models.LedgerExpiry, userids).Scan(&n)
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return 0, fmt.Errorf(errRollback, err)
+		}
+		return 0, fmt.Errorf("failed to expire accrual lots: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit accrual lots expiry: %w", err)
+	}
+	return n, nil
+}
+
+// updateTiers assigns every user, or only userid if it is not empty, the
+// highest configured tier reached by the accruals of the last
+// tierWindowMonths. Accruals are counted before tier multipliers, so a tier
+// does not feed itself.
+func updateTiers(ctx context.Context, db execer, c *models.Config, userid string) (int64, error) {
+	names := make([]string, 0, len(c.Tiers))
+	thresholds := make([]float32, 0, len(c.Tiers))
+	for _, t := range c.Tiers {
+		names = append(names, t.Name)
+		thresholds = append(thresholds, t.Threshold)
+	}
+
+	querySQL := `UPDATE users u SET tier = s.tier
+		FROM (
+			SELECT a.userid, COALESCE((
+				SELECT t.name FROM unnest($1::varchar[], $2::float8[]) AS t(name, threshold)
+				WHERE t.threshold <= a.total ORDER BY t.threshold DESC LIMIT 1), '') AS tier
+			FROM (
+				SELECT u2.userid, COALESCE(SUM(o.accrual), 0) AS total
+				FROM users u2
+				LEFT JOIN orders o ON o.userid = u2.userid AND o.status = 'PROCESSED'
+					AND o.uploaded_at > now() - make_interval(months => $3)
+				WHERE $4 = '' OR u2.userid = $4
+				GROUP BY u2.userid
+			) a
+		) s
+		WHERE u.userid = s.userid AND u.tier <> s.tier`
+
+	tag, err := db.Exec(ctx, querySQL, names, thresholds, tierWindowMonths, userid)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update user tiers: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// TiersRecalculate updates the tiers of all users, it also downgrades users
+// whose old accruals dropped out of the window. The run is recorded for
+// TiersSinceLastRun.
+func (p *PostgresDB) TiersRecalculate(ctx context.Context, c *models.Config) (int64, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	n, err := updateTiers(ctx, tx, c, "")
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return 0, fmt.Errorf(errRollback, err)
+		}
+		return 0, err
+	}
+
+	querySQL := `INSERT INTO job_runs (name, last_run) VALUES($1, now())
+		ON CONFLICT (name) DO UPDATE SET last_run = EXCLUDED.last_run`
+
+	if _, err := tx.Exec(ctx, querySQL, jobTiers); err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return 0, fmt.Errorf(errRollback, err)
+		}
+		return 0, fmt.Errorf("failed to record tier recalculation run: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit tier recalculation: %w", err)
+	}
+	return n, nil
+}
+
+// TiersSinceLastRun returns the time elapsed since the last tier
+// recalculation, false if tiers have never been recalculated.
+func (p *PostgresDB) TiersSinceLastRun(ctx context.Context, c *models.Config) (time.Duration, bool, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	var seconds float64
+	querySQL := "SELECT EXTRACT(EPOCH FROM now() - last_run)::float8 FROM job_runs WHERE name=$1"
+
+	if err := db.QueryRow(ctx, querySQL, jobTiers).Scan(&seconds); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("failed to query last tier recalculation: %w", err)
+	}
+	return time.Duration(seconds * float64(time.Second)), true, nil
+}
+
+// campaignColumns selects campaign rows as c. The spend of campaigns without
+// a budget is not counted on the campaign row, so that their bonuses do not
+// contend for it, and is summed from their bonuses instead.
+const campaignColumns string = `c.id, c.name, c.rule, c.amount, c.multiplier, c.threshold, c.budget,
+	c.user_budget, c.active, c.starts_at, c.ends_at, c.created_by, c.created_at,
+	CASE WHEN c.budget > 0 THEN c.spent
+		ELSE (SELECT COALESCE(SUM(b.amount), 0) FROM campaign_bonuses b WHERE b.campaign_id = c.id)
+	END AS spent`
+
+// applyCampaigns credits the bonuses of running campaigns for the order.
+// Campaigns are read without a lock, only a budgeted campaign granting a
+// bonus is locked, so that concurrent accruals cannot overspend its budget
+// while other accruals are not serialised. Every bonus is a separate ledger
+// entry referencing the campaign.
+func applyCampaigns(ctx context.Context, tx pgx.Tx, order *models.Order, first bool) error {
+	querySQL := `SELECT * FROM campaigns
+		WHERE active AND starts_at <= now() AND ends_at > now() AND (budget = 0 OR spent < budget)
+		ORDER BY id`
+
+	rows, err := tx.Query(ctx, querySQL)
+	if err != nil {
+		return fmt.Errorf("failed to query campaigns: %w", err)
+	}
+	campaigns, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Campaign])
+	if err != nil {
+		return fmt.Errorf("failed to scan campaigns: %w", err)
+	}
+
+	var now time.Time
+	if err := tx.QueryRow(ctx, "SELECT now()::timestamp").Scan(&now); err != nil {
+		return fmt.Errorf("failed to query DB time: %w", err)
+	}
+
+	for _, cmp := range campaigns {
+		bonus := helpers.CampaignBonus(cmp, order.Accrual, first, now)
+		if bonus <= 0 {
+			continue
+		}
+		if cmp.Budget > 0 {
+			// the spend read above may be stale, lock the row and read it again
+			querySQL = "SELECT spent, active FROM campaigns WHERE id=$1 FOR UPDATE"
+			if err := tx.QueryRow(ctx, querySQL, cmp.ID).Scan(&cmp.Spent, &cmp.Active); err != nil {
+				return fmt.Errorf("failed to lock campaign %d: %w", cmp.ID, err)
+			}
+			if !cmp.Active {
+				continue
+			}
+		}
+		if cmp.UserBudget > 0 {
+			var userSpent float32
+			querySQL = `SELECT COALESCE(SUM(amount), 0) FROM campaign_bonuses
+				WHERE campaign_id=$1 AND userid=$2`
+			if err := tx.QueryRow(ctx, querySQL, cmp.ID, order.UserID).Scan(&userSpent); err != nil {
+				return fmt.Errorf("failed to query campaign %d bonuses: %w", cmp.ID, err)
+			}
+			bonus = helpers.CampaignCap(cmp, bonus, userSpent)
+		} else {
+			bonus = helpers.CampaignCap(cmp, bonus, 0)
+		}
+		if bonus <= 0 {
+			continue
+		}
+
+		querySQL = `INSERT INTO campaign_bonuses (campaign_id, userid, number, amount, created_at)
+			VALUES($1, $2, $3, $4, now())`
+		if _, err := tx.Exec(ctx, querySQL, cmp.ID, order.UserID, order.Number, bonus); err != nil {
+			return fmt.Errorf("failed to insert campaign %d bonus: %w", cmp.ID, err)
+		}
+		if cmp.Budget > 0 {
+			querySQL = "UPDATE campaigns SET spent = spent + $1 WHERE id=$2"
+			if _, err := tx.Exec(ctx, querySQL, bonus, cmp.ID); err != nil {
+				return fmt.Errorf("failed to update campaign %d spend: %w", cmp.ID, err)
+			}
+		}
+		querySQL = "UPDATE users SET accrual = accrual + $1 WHERE userid=$2"
+		if _, err := tx.Exec(ctx, querySQL, bonus, order.UserID); err != nil {
+			return fmt.Errorf("failed to add campaign %d bonus for user %s: %w", cmp.ID, order.UserID, err)
+		}
+
+		entry := models.LedgerEntry{
+			UserID: order.UserID,
+			Type:   models.LedgerBonus,
+			Number: order.Number,
+			Reason: cmp.Name,
+			Amount: bonus,
+			RefID:  cmp.ID,
+		}
+		if err := insertLedger(ctx, tx, entry); err != nil {
+			return err
+		}
+		if err := insertLot(ctx, tx, order.UserID, order.Number, bonus); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *PostgresDB) CampaignAdd(ctx context.Context, c *models.Config, cmp models.Campaign) (models.Campaign, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return cmp, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	querySQL := `INSERT INTO campaigns (name, rule, amount, multiplier, threshold, budget, user_budget,
+		starts_at, ends_at, created_by, created_at)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, now())
+		RETURNING id, created_at, active`
+
+	err = tx.QueryRow(ctx, querySQL, cmp.Name, cmp.Rule, cmp.Amount, cmp.Multiplier, cmp.Threshold, cmp.Budget,
+		cmp.UserBudget, cmp.Starts, cmp.Ends, cmp.CreatedBy).Scan(&cmp.ID, &cmp.Created, &cmp.Active)
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return cmp, fmt.Errorf(errRollback, err)
+		}
+		return cmp, fmt.Errorf("failed to insert campaign into Postgres DB: %w", err)
+	}
+
+	if err := insertAudit(ctx, tx, cmp.CreatedBy, models.AuditCampaignAdd, strconv.FormatInt(cmp.ID, 10)); err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return cmp, fmt.Errorf(errRollback, err)
+		}
+		return cmp, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return cmp, fmt.Errorf("failed to commit campaign transaction: %w", err)
+	}
+	return cmp, nil
+}
+
+// CampaignStop deactivates a campaign, bonuses already granted are kept.
+func (p *PostgresDB) CampaignStop(ctx context.Context, c *models.Config, admin string, id int64) (
+	models.Campaign, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return models.Campaign{}, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	querySQL := "UPDATE campaigns c SET active = false WHERE id=$1 RETURNING " + campaignColumns
+
+	rows, err := tx.Query(ctx, querySQL, id)
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return models.Campaign{}, fmt.Errorf(errRollback, err)
+		}
+		return models.Campaign{}, fmt.Errorf("failed to stop campaign %d: %w", id, err)
+	}
+	cmp, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.Campaign])
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return models.Campaign{}, fmt.Errorf(errRollback, err)
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Campaign{}, fmt.Errorf("campaign %d: %w", id, models.ErrNotFound)
+		}
+		return models.Campaign{}, fmt.Errorf("failed to scan campaign %d: %w", id, err)
+	}
+
+	if err := insertAudit(ctx, tx, admin, models.AuditCampaignStop, strconv.FormatInt(id, 10)); err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return cmp, fmt.Errorf(errRollback, err)
+		}
+		return cmp, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return cmp, fmt.Errorf("failed to commit campaign transaction: %w", err)
+	}
+	return cmp, nil
+}
+
+func (p *PostgresDB) CampaignsGet(ctx context.Context, c *models.Config, limit int64, offset int64) (
+	models.Campaigns, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	querySQL := "SELECT " + campaignColumns + " FROM campaigns c ORDER BY id DESC LIMIT $1 OFFSET $2"
+
+	rows, err := db.Query(ctx, querySQL, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DB: %w", err)
+	}
+	defer rows.Close()
+
+	campaigns, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Campaign])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan campaigns: %w", err)
+	}
+	return campaigns, nil
+}
+
+// applyReferral rewards both parties of the referral of a user whose first
+// order is being credited.
+func applyReferral(ctx context.Context, tx pgx.Tx, c *models.Config, order *models.Order) error {
+	var (
+		id       int64
+		referrer string
+	)
+	querySQL := `UPDATE referrals SET rewarded = true, referrer_bonus = $2, referee_bonus = $3, rewarded_at = now()
+		WHERE referee=$1 AND NOT rewarded
+		RETURNING id, referrer`
+
+	err := tx.QueryRow(ctx, querySQL, order.UserID, c.ReferrerBonus, c.RefereeBonus).Scan(&id, &referrer)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("failed to update referral of user %s: %w", order.UserID, err)
+	}
+
+	bonuses := []struct {
+		userid string
+		reason string
+		amount float32
+	}{
+		{userid: order.UserID, reason: models.ReferralReferee, amount: c.RefereeBonus},
+		{userid: referrer, reason: models.ReferralReferrer, amount: c.ReferrerBonus},
+	}
+	for _, b := range bonuses {
+		if b.amount <= 0 {
+			continue
+		}
+		querySQL = "UPDATE users SET accrual = accrual + $1 WHERE userid=$2"
+		if _, err := tx.Exec(ctx, querySQL, b.amount, b.userid); err != nil {
+			return fmt.Errorf("failed to add referral bonus for user %s: %w", b.userid, err)
+		}
+		entry := models.LedgerEntry{
+			UserID: b.userid,
+			Type:   models.LedgerReferral,
+			Number: order.Number,
+			Reason: b.reason,
+			Amount: b.amount,
+			RefID:  id,
+		}
+		if b.userid == referrer {
+			// the referee's order is none of the referrer's business
+			entry.Number = ""
+		}
+		if err := insertLedger(ctx, tx, entry); err != nil {
+			return err
+		}
+		if err := insertLot(ctx, tx, b.userid, entry.Number, b.amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *PostgresDB) ReferralStatsGet(ctx context.Context, c *models.Config, userid string) (
+	models.ReferralStats, error) {
+	db := p.pool
+	stats := models.ReferralStats{}
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	querySQL := `SELECT u.invite_code, COUNT(r.id), COUNT(r.id) FILTER (WHERE r.rewarded),
+		COALESCE(SUM(r.referrer_bonus), 0)
+		FROM users u LEFT JOIN referrals r ON r.referrer = u.userid
+		WHERE u.userid=$1 GROUP BY u.invite_code`
+
+	err := db.QueryRow(ctx, querySQL, userid).Scan(&stats.InviteCode, &stats.Invited, &stats.Rewarded, &stats.Earned)
+	if err != nil {
+		return stats, fmt.Errorf("failed to query referral stats of user %s: %w", userid, err)
+	}
+	return stats, nil
+}
+
+// Transfer moves points between two users if check, called with the user
+// rows locked, accepts the sender's withdrawal stats. Both user rows are
+// locked in login order, so concurrent transfers in opposite directions
+// cannot deadlock. A locked recipient is reported exactly as an unknown one,
+// so that transfers cannot probe account state. Points held by withdrawals
+// under review cannot be sent, and the points keep the expiry of the sender
+// lots they are taken from.
+func (p *PostgresDB) Transfer(ctx context.Context, c *models.Config, t models.Transfer,
+	check func(models.WithdrawalStats) error) (models.Transfer, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return t, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	userids := []string{t.From, t.To}
+	slices.Sort(userids)
+	for _, userid := range userids {
+		var locked bool
+		querySQL := "SELECT locked FROM users WHERE userid=$1 FOR UPDATE"
+		err := tx.QueryRow(ctx, querySQL, userid).Scan(&locked)
+		if errors.Is(err, pgx.ErrNoRows) || locked {
+			err = models.ErrNotFound
+		}
+		if err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				return t, fmt.Errorf(errRollback, err)
+			}
+			if errors.Is(err, models.ErrNotFound) {
+				return t, fmt.Errorf("user %s: %w", userid, err)
+			}
+			return t, fmt.Errorf("failed to query user table in DB: %w", err)
+		}
+	}
+
+	st, accrual, held, err := outflowStats(ctx, tx, t.From)
+	if err == nil {
+		err = check(st)
+	}
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return t, fmt.Errorf(errRollback, err)
+		}
+		return t, err
+	}
+
+	if accrual-held < t.Amount {
+		if err := tx.Rollback(ctx); err != nil {
+			return t, fmt.Errorf(errRollback, err)
+		}
+		return t, fmt.Errorf("balance of user %s: %w", t.From, models.ErrInsufficientFunds)
+	}
+
+	if c.TransferDailyLimit > 0 {
+		var sent float32
+		querySQL := `SELECT COALESCE(SUM(amount), 0) FROM transfers
+			WHERE sender=$1 AND created_at >= date_trunc('day', now())`
+		if err := tx.QueryRow(ctx, querySQL, t.From).Scan(&sent); err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				return t, fmt.Errorf(errRollback, err)
+			}
+			return t, fmt.Errorf("failed to query transfers of user %s: %w", t.From, err)
+		}
+		if sent+t.Amount > c.TransferDailyLimit {
+			if err := tx.Rollback(ctx); err != nil {
+				return t, fmt.Errorf(errRollback, err)
+			}
+			return t, fmt.Errorf("daily transfers of user %s: %w", t.From, models.ErrLimitExceeded)
+		}
+	}
+
+	querySQL := `INSERT INTO transfers (sender, recipient, amount, created_at) VALUES($1, $2, $3, now())
+		RETURNING id, created_at`
+	if err := tx.QueryRow(ctx, querySQL, t.From, t.To, t.Amount).Scan(&t.ID, &t.Created); err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return t, fmt.Errorf(errRollback, err)
+		}
+		return t, fmt.Errorf("failed to insert transfer into Postgres DB: %w", err)
+	}
+
+	entries := []models.LedgerEntry{
+		{UserID: t.From, Type: models.LedgerTransfer, Reason: models.TransferSent, Amount: -t.Amount, RefID: t.ID},
+		{UserID: t.To, Type: models.LedgerTransfer, Reason: models.TransferReceived, Amount: t.Amount, RefID: t.ID},
+	}
+	for _, e := range entries {
+		querySQL = "UPDATE users SET accrual = accrual + $1 WHERE userid=$2"
+		if _, err := tx.Exec(ctx, querySQL, e.Amount, e.UserID); err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				return t, fmt.Errorf(errRollback, err)
+			}
+			return t, fmt.Errorf("failed to update balance of user %s: %w", e.UserID, err)
+		}
+		if err := insertLedger(ctx, tx, e); err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				return t, fmt.Errorf(errRollback, err)
+			}
+			return t, err
+		}
+	}
+	debits, err := consumeLots(ctx, tx, t.From, t.Amount)
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return t, fmt.Errorf(errRollback, err)
+		}
+		return t, err
+	}
+	// points of legacy balances without lots are credited as new
+	untraced := t.Amount
+	for _, d := range debits {
+		untraced -= d.Amount
+		err = insertLotAt(ctx, tx, t.To, "", d.Amount, d.Earned)
+		if err != nil {
+			break
+		}
+	}
+	if err == nil && untraced > lotRoundingError {
+		err = insertLot(ctx, tx, t.To, "", untraced)
+	}
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return t, fmt.Errorf(errRollback, err)
+		}
+		return t, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return t, fmt.Errorf("failed to commit transfer transaction: %w", err)
+	}
+	return t, nil
+}
+
+// UserPasswordSet replaces the password hash of the user, starting the
+// withdrawal cooldown.
+func (p *PostgresDB) UserPasswordSet(ctx context.Context, c *models.Config, userid string, password string) error {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	querySQL := "UPDATE users SET password = $1, password_changed_at = now() WHERE userid=$2"
+
+	tag, err := db.Exec(ctx, querySQL, password, userid)
+	if err != nil {
+		return fmt.Errorf("failed to update password of user %s in Postgres DB: %w", userid, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("user %s: %w", userid, models.ErrNotFound)
+	}
+	return nil
+}
+
+// UserLoginRecord remembers the IP address of a successful login. A login
+// from an address not seen before starts the withdrawal cooldown, unless it
+// is the first login of the user.
+func (p *PostgresDB) UserLoginRecord(ctx context.Context, c *models.Config, userid string, ip string) error {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	var inserted bool
+	querySQL := `INSERT INTO user_logins (userid, ip, first_seen, last_seen) VALUES($1, $2, now(), now())
+		ON CONFLICT (userid, ip) DO UPDATE SET last_seen = now()
+		RETURNING xmax = 0`
+
+	if err := tx.QueryRow(ctx, querySQL, userid, ip).Scan(&inserted); err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return fmt.Errorf(errRollback, err)
+		}
+		return fmt.Errorf("failed to record login of user %s: %w", userid, err)
+	}
+
+	if inserted {
+		querySQL = `UPDATE users SET new_ip_at = now()
+			WHERE userid=$1 AND EXISTS (SELECT 1 FROM user_logins WHERE userid=$1 AND ip <> $2)`
+		if _, err := tx.Exec(ctx, querySQL, userid, ip); err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				return fmt.Errorf(errRollback, err)
+			}
+			return fmt.Errorf("failed to update user %s: %w", userid, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit login transaction for user %s: %w", userid, err)
+	}
+	return nil
+}
+
+// RiskSignalsGet collects the recent activity of the user scored by the
+// risk engine.
+func (p *PostgresDB) RiskSignalsGet(ctx context.Context, c *models.Config, userid string, kind string) (
+	models.RiskSignals, error) {
+	db := p.pool
+	s := models.RiskSignals{Kind: kind}
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	var age float64
+	querySQL := `SELECT EXTRACT(EPOCH FROM now() - u.created_at),
+		(SELECT COUNT(*) FROM orders WHERE userid=$1 AND uploaded_at > now() - interval '1 hour'),
+		(SELECT COUNT(*) FROM orders WHERE userid=$1 AND uploaded_at > now() - interval '1 day'),
+		(SELECT COUNT(*) FROM order_conflicts WHERE userid=$1 AND created_at > now() - interval '1 day'),
+		(SELECT COUNT(*) FROM orders WHERE userid=$1 AND status='INVALID'),
+		(SELECT COUNT(*) FROM withdrawals WHERE userid=$1 AND processed_at > now() - interval '1 hour')
+		FROM users u WHERE u.userid=$1`
+
+	err := db.QueryRow(ctx, querySQL, userid).Scan(&age, &s.UploadsHour, &s.UploadsDay, &s.ConflictsDay,
+		&s.Invalid, &s.WithdrawalsHour)
+	if err != nil {
+		return s, fmt.Errorf("failed to query risk signals of user %s: %w", userid, err)
+	}
+	s.AccountAge = time.Duration(age * float64(time.Second))
+	return s, nil
+}
+
+// OrderConflictsAdd records uploads of orders registered by other users.
+func (p *PostgresDB) OrderConflictsAdd(ctx context.Context, c *models.Config, userid string, oids []string) error {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	querySQL := `INSERT INTO order_conflicts (userid, number, created_at)
+		SELECT $1, number, now() FROM unnest($2::varchar[]) AS number`
+
+	if _, err := db.Exec(ctx, querySQL, userid, oids); err != nil {
+		return fmt.Errorf("failed to insert order conflicts of user %s: %w", userid, err)
+	}
+	return nil
+}
+
+// RiskDecisionAdd records a risk decision. A withdrawal under review holds
+// its amount, so it must not exceed the points that are not held yet, and
+// an order can only be held once at a time.
+func (p *PostgresDB) RiskDecisionAdd(ctx context.Context, c *models.Config, d models.RiskDecision) (
+	models.RiskDecision, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return d, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	if d.Action == models.RiskReview {
+		d.Status = models.RiskOpen
+	}
+	if d.Kind == models.RiskWithdrawal && d.Status == models.RiskOpen {
+		var (
+			available float32
+			held      bool
+		)
+		querySQL := `SELECT accrual - (SELECT COALESCE(SUM(amount), 0) FROM risk_decisions
+			WHERE userid=$1 AND kind='withdrawal' AND status='open'),
+			EXISTS (SELECT 1 FROM risk_decisions WHERE kind='withdrawal' AND subject=$2 AND status='open')
+			FROM users WHERE userid=$1 FOR UPDATE`
+		if err := tx.QueryRow(ctx, querySQL, d.UserID, d.Subject).Scan(&available, &held); err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				return d, fmt.Errorf(errRollback, err)
+			}
+			return d, fmt.Errorf("failed to query balance of user %s: %w", d.UserID, err)
+		}
+		if held {
+			if err := tx.Rollback(ctx); err != nil {
+				return d, fmt.Errorf(errRollback, err)
+			}
+			return d, fmt.Errorf("withdrawal for order %s: %w", d.Subject, models.ErrAlreadyHeld)
+		}
+		if available < d.Amount {
+			if err := tx.Rollback(ctx); err != nil {
+				return d, fmt.Errorf(errRollback, err)
+			}
+			return d, fmt.Errorf("balance of user %s: %w", d.UserID, models.ErrInsufficientFunds)
+		}
+	}
+
+	querySQL := `INSERT INTO risk_decisions (userid, kind, subject, amount, score, action, reasons, status, created_at)
+		VALUES($1, $2, $3, $4, $5, $6, $7, $8, now())
+		RETURNING id, created_at`
+
+	err = tx.QueryRow(ctx, querySQL, d.UserID, d.Kind, d.Subject, d.Amount, d.Score, d.Action, d.Reasons,
+		d.Status).Scan(&d.ID, &d.Created)
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return d, fmt.Errorf(errRollback, err)
+		}
+		return d, fmt.Errorf("failed to insert risk decision for user %s: %w", d.UserID, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return d, fmt.Errorf("failed to commit risk decision transaction for user %s: %w", d.UserID, err)
+	}
+	return d, nil
+}
+
+func (p *PostgresDB) RiskDecisionsGet(ctx context.Context, c *models.Config, action string, status string, limit int64,
+	offset int64) (
+	models.RiskDecisions, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	querySQL := `SELECT * FROM risk_decisions WHERE ($1 = '' OR action=$1) AND ($2 = '' OR status=$2)
+		ORDER BY id DESC LIMIT $3 OFFSET $4`
+
+	rows, err := db.Query(ctx, querySQL, action, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query DB: %w", err)
+	}
+	defer rows.Close()
+
+	decisions, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.RiskDecision])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan risk decisions: %w", err)
+	}
+	return decisions, nil
+}
+
+// RiskDecide closes an open decision. Approving a withdrawal executes it
+// once check, called with the user row locked, accepts the held amount and
+// the user's withdrawal stats. The points held by other open decisions
+// cannot be withdrawn. Approving the upload of a single order registers it
+// unless it has been registered since.
+func (p *PostgresDB) RiskDecide(ctx context.Context, c *models.Config, admin string, id int64, approve bool,
+	check func(float32, models.WithdrawalStats) error) (models.RiskDecision, error) {
+	db := p.pool
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return models.RiskDecision{}, fmt.Errorf("failed to start transaction: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, "SELECT * FROM risk_decisions WHERE id=$1 FOR UPDATE", id)
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return models.RiskDecision{}, fmt.Errorf(errRollback, err)
+		}
+		return models.RiskDecision{}, fmt.Errorf("failed to query risk decision %d: %w", id, err)
+	}
+	d, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.RiskDecision])
+	if err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return d, fmt.Errorf(errRollback, err)
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			return d, fmt.Errorf("risk decision %d: %w", id, models.ErrNotFound)
+		}
+		return d, fmt.Errorf("failed to scan risk decision %d: %w", id, err)
+	}
+	if d.Status != models.RiskOpen {
+		if err := tx.Rollback(ctx); err != nil {
+			return d, fmt.Errorf(errRollback, err)
+		}
+		return d, fmt.Errorf("risk decision %d: %w", id, models.ErrAlreadyDecided)
+	}
+
+	d.Status, d.ReviewedBy = models.RiskRejected, admin
+	action := models.AuditRiskReject
+	if approve {
+		d.Status, action = models.RiskApproved, models.AuditRiskApprove
+	}
+
+	querySQL := `UPDATE risk_decisions SET status=$1, reviewed_by=$2, reviewed_at=now() WHERE id=$3
+		RETURNING reviewed_at`
+	if err := tx.QueryRow(ctx, querySQL, d.Status, admin, id).Scan(&d.Reviewed); err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return d, fmt.Errorf(errRollback, err)
+		}
+		return d, fmt.Errorf("failed to update risk decision %d: %w", id, err)
+	}
+
+	if approve && d.Kind == models.RiskWithdrawal {
+		// the decision is no longer open, held only counts the other ones
+		st, accrual, held, err := outflowStats(ctx, tx, d.UserID)
+		if err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				return d, fmt.Errorf(errRollback, err)
+			}
+			return d, err
+		}
+		if err := check(d.Amount, st); err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				return d, fmt.Errorf(errRollback, err)
+			}
+			return d, err
+		}
+		if accrual-held < d.Amount {
+			if err := tx.Rollback(ctx); err != nil {
+				return d, fmt.Errorf(errRollback, err)
+			}
+			return d, fmt.Errorf("balance of user %s: %w", d.UserID, models.ErrInsufficientFunds)
+		}
+		w := models.Withdrawal{UserID: d.UserID, Number: d.Subject, Sum: d.Amount}
+		if err := insertWithdrawal(ctx, tx, w); err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				return d, fmt.Errorf(errRollback, err)
+			}
+			return d, err
+		}
+	}
+
+	if approve && d.Kind == models.RiskOrderUpload && d.Subject != "" {
+		querySQL = `INSERT INTO orders (userid, number, status, accrual, uploaded_at) VALUES($1, $2, 'NEW', 0, now())
+			ON CONFLICT (number) DO NOTHING`
+		if _, err := tx.Exec(ctx, querySQL, d.UserID, d.Subject); err != nil {
+			if err := tx.Rollback(ctx); err != nil {
+				return d, fmt.Errorf(errRollback, err)
+			}
+			return d, fmt.Errorf("failed to register held order %s: %w", d.Subject, err)
+		}
+	}
+
+	if err := insertAudit(ctx, tx, admin, action, strconv.FormatInt(id, 10)); err != nil {
+		if err := tx.Rollback(ctx); err != nil {
+			return d, fmt.Errorf(errRollback, err)
+		}
+		return d, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return d, fmt.Errorf("failed to commit risk decision transaction: %w", err)
+	}
+	return d, nil
+}
+
+func (p *PostgresDB) OrdersCountByStatus(ctx context.Context, c *models.Config) (map[string]int64, error) {
+	db := p.pool
+
+	ctx, cancel := context.WithTimeout(ctx, c.ContextTimeout)
+	defer cancel()
+
+	rows, err := db.Query(ctx, "SELECT status, COUNT(*) FROM orders GROUP BY status")
+	if err != nil {
+		return nil, fmt.Errorf("failed to count orders by status: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var status string
+		var n int64
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, fmt.Errorf("failed to scan order count: %w", err)
+		}
+		counts[status] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to count orders by status: %w", err)
+	}
+	return counts, nil
+}
+
+// Ping checks that a pooled connection reaches the DB within HealthTimeout.
+func (p *PostgresDB) Ping(ctx context.Context, c *models.Config) error {
+	ctx, cancel := context.WithTimeout(ctx, c.HealthTimeout)
+	defer cancel()
+
+	if err := p.pool.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to ping the DB: %w", err)
+	}
+	return nil
+}
+
+// MigrationStatus returns the schema version recorded by golang-migrate and
+// the latest migration embedded in the binary.
+func (p *PostgresDB) MigrationStatus(ctx context.Context, c *models.Config) (models.MigrationStatus, error) {
+	db := p.pool
+	var ms models.MigrationStatus
+	ctx, cancel := context.WithTimeout(ctx, c.HealthTimeout)
+	defer cancel()
+
+	latest, err := latestMigration()
+	if err != nil {
+		return ms, err
+	}
+	ms.Latest = latest
+
+	var version int64
+	row := db.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1")
+	if err := row.Scan(&version, &ms.Dirty); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ms, nil
+		}
+		return ms, fmt.Errorf("failed to get schema version: %w", err)
+	}
+	ms.Version = uint(version)
+	return ms, nil
+}
+
+func latestMigration() (uint, error) {
+	d, err := iofs.New(migrationsDir, "migrations")
+	if err != nil {
+		return 0, fmt.Errorf("failed to return an iofs driver: %w", err)
+	}
+
+	version, err := d.First()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read embedded migrations: %w", err)
+	}
+	for {
+		next, err := d.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to read embedded migrations: %w", err)
+		}
+		version = next
+	}
+}
+
+// Stat returns the connection pool statistics.
+func (p *PostgresDB) Stat() *pgxpool.Stat {
+	return p.pool.Stat()
+}
+
 func (p *PostgresDB) Close() {
 	p.pool.Close()
 }
@@ -1,11 +1,14 @@
 package storage
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -214,7 +217,7 @@ func TestUserAdd(t *testing.T) {
 		i, tc := i, tc
 
 		t.Run(fmt.Sprintf("test #%d: %s", i, tc.Name), func(t *testing.T) {
-			actualErr := db.UserAdd(&cfg, tc.User)
+			actualErr := db.UserAdd(context.Background(), &cfg, tc.User)
 			if err := checkErrors(actualErr, tc.ExpectedErr); err != nil {
 				t.Error(err)
 				return
@@ -259,7 +262,7 @@ func TestOrderAdd(t *testing.T) {
 		i, tc := i, tc
 
 		t.Run(fmt.Sprintf("test #%d: %s", i, tc.Name), func(t *testing.T) {
-			actualErr := db.OrderAdd(&cfg, tc.UserID, tc.OrderID)
+			actualErr := db.OrderAdd(context.Background(), &cfg, tc.UserID, tc.OrderID)
 			if err := checkErrors(actualErr, tc.ExpectedErr); err != nil {
 				t.Error(err)
 				return
@@ -268,6 +271,98 @@ func TestOrderAdd(t *testing.T) {
 	}
 }
 
+func TestUpdateOrderFetchedTwice(t *testing.T) {
+	dsn := getDSN()
+	if err := runMigrations(dsn); err != nil {
+		t.Errorf("failed to run migrations using dsn %s: %v", dsn, err)
+		return
+	}
+
+	cfg := models.Config{
+		ContextTimeout: 10 * time.Second,
+	}
+
+	db, err := NewPostgresDB(dsn)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	if err := db.UserAdd(ctx, &cfg, models.User{UserID: "fetchuser", Password: "testpassword"}); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := db.OrderAdd(ctx, &cfg, "fetchuser", "12345678903"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// two workers store the same accrual service result concurrently
+	var (
+		wg      sync.WaitGroup
+		changed atomic.Int32
+	)
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			order := models.Order{Number: "12345678903", Status: "PROCESSED", Accrual: 500}
+			ok, err := db.UpdateOrder(ctx, &cfg, &order)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if ok {
+				changed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := changed.Load(); n != 1 {
+		t.Errorf("expected the order to change once, got %d", n)
+	}
+	user, err := db.UserGet(ctx, &cfg, "fetchuser")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if user.Accrual != 500 {
+		t.Errorf("expected the user to be credited once, got a balance of %v", user.Accrual)
+	}
+}
+
+func TestMigrationStatus(t *testing.T) {
+	dsn := getDSN()
+
+	cfg := models.Config{
+		HealthTimeout: 10 * time.Second,
+	}
+
+	db, err := NewPostgresDB(dsn)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer db.Close()
+
+	if err := db.Ping(context.Background(), &cfg); err != nil {
+		t.Error(err)
+		return
+	}
+
+	ms, err := db.MigrationStatus(context.Background(), &cfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if ms.Dirty || ms.Latest == 0 || ms.Version != ms.Latest {
+		t.Errorf("expected clean schema at the latest version, got %+v", ms)
+	}
+}
+
 func checkErrors(actual error, expected error) error {
 	if actual == nil && expected == nil {
 		return nil